@@ -0,0 +1,221 @@
+package abnf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+type FindingKind int
+
+const (
+	// FindingUndefined reports a reference to a rule that is not defined.
+	FindingUndefined FindingKind = iota + 1
+	// FindingUnreachable reports a rule that cannot be reached from the start
+	// rule.
+	FindingUnreachable
+	// FindingDuplicate reports a rule defined with "=" more than once.
+	FindingDuplicate
+	// FindingShadowedCore reports a rule replacing one of the core rules of
+	// RFC 5234, which parser.MakeRules silently overrides.
+	FindingShadowedCore
+	// FindingIncremental reports a rule defined with "=/" before, or without,
+	// its base rule.
+	FindingIncremental
+	// FindingLeftRecursion reports rules calling themselves before consuming
+	// any input.
+	FindingLeftRecursion
+)
+
+var findingKindNames = map[FindingKind]string{
+	FindingUndefined:     "undefined",
+	FindingUnreachable:   "unreachable",
+	FindingDuplicate:     "duplicate",
+	FindingShadowedCore:  "shadowed-core",
+	FindingIncremental:   "incremental",
+	FindingLeftRecursion: "left-recursion",
+}
+
+func (f FindingKind) String() string { return findingKindNames[f] }
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota + 1
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding is an issue found by Analyze. Span locates it in the grammar source,
+// and is empty for issues that cannot be tied to a position, such as an
+// undefined start rule.
+type Finding struct {
+	Kind     FindingKind
+	Severity Severity
+	Rule     string
+	Span     parser.Span
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Severity, f.Message, f.Kind)
+}
+
+// Analyze reports issues in list that would only show up when parsing, or
+// not at all: undefined references, rules unreachable from start, duplicate
+// definitions, incremental alternatives without a base rule, shadowed core
+// rules and left recursion. When start is empty, list.StartRule is used.
+// Findings are sorted by position.
+func Analyze(list *RuleList, start string) []Finding {
+	var findings []Finding
+	report := func(kind FindingKind, severity Severity, rule string, span parser.Span, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Kind:     kind,
+			Severity: severity,
+			Rule:     rule,
+			Span:     span,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	defined := map[string]Rule{}
+	refs := map[string][]RuleName{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		_, ok := defined[name]
+		switch {
+		case r.DefinedAs.Value == "=/" && !ok:
+			report(FindingIncremental, SeverityError, r.Name.Name, r.Name.Span,
+				"incremental alternative for %s appears before its base rule", r.Name.Name)
+		case r.DefinedAs.Value != "=/" && ok:
+			report(FindingDuplicate, SeverityError, r.Name.Name, r.Name.Span,
+				"rule %s is defined more than once", r.Name.Name)
+		}
+		if !ok {
+			defined[name] = r
+			if _, core := parser.CoreConsumers[name]; core {
+				report(FindingShadowedCore, SeverityWarning, r.Name.Name, r.Name.Span,
+					"rule %s shadows the core rule %s", r.Name.Name, strings.ToUpper(name))
+			}
+		}
+		Inspect(r.Elements, func(n interface{}) bool {
+			if ref, ok := n.(RuleName); ok {
+				refs[name] = append(refs[name], ref)
+			}
+			return true
+		})
+	}
+
+	checked := map[string]bool{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+		for _, ref := range refs[name] {
+			lower := strings.ToLower(ref.Name)
+			if _, ok := defined[lower]; ok {
+				continue
+			}
+			if _, ok := parser.CoreConsumers[lower]; ok {
+				continue
+			}
+			report(FindingUndefined, SeverityError, r.Name.Name, ref.Span,
+				"rule %s references undefined rule %s", r.Name.Name, ref.Name)
+		}
+	}
+
+	if start == "" {
+		var err error
+		if start, err = list.StartRule(); err != nil {
+			report(FindingUndefined, SeverityError, "", parser.Span{}, "%s", err)
+		}
+	}
+	if start != "" {
+		if _, ok := defined[strings.ToLower(start)]; !ok {
+			report(FindingUndefined, SeverityError, start, parser.Span{}, "start rule %s is not defined", start)
+		} else {
+			reached := map[string]bool{}
+			queue := []string{strings.ToLower(start)}
+			for len(queue) > 0 {
+				name := queue[0]
+				queue = queue[1:]
+				if reached[name] {
+					continue
+				}
+				reached[name] = true
+				for _, ref := range refs[name] {
+					queue = append(queue, strings.ToLower(ref.Name))
+				}
+			}
+			for _, r := range list.Rules {
+				name := strings.ToLower(r.Name.Name)
+				if !reached[name] && defined[name].Name.Span == r.Name.Span {
+					report(FindingUnreachable, SeverityWarning, r.Name.Name, r.Name.Span,
+						"rule %s is not reachable from %s", r.Name.Name, start)
+				}
+			}
+		}
+	}
+
+	for _, rec := range FindLeftRecursion(list) {
+		report(FindingLeftRecursion, SeverityError, rec.Rules[0], rec.Span,
+			"%s", rec)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Span.Start < findings[j].Span.Start
+	})
+	return findings
+}
+
+// Inspect traverses the grammar nodes below node in depth-first order,
+// calling fn for each of them, including node itself. Children are skipped
+// when fn returns false.
+func Inspect(node interface{}, fn func(node interface{}) bool) {
+	if !fn(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *RuleList:
+		for _, r := range n.Rules {
+			Inspect(r, fn)
+		}
+	case Rule:
+		Inspect(n.Name, fn)
+		Inspect(n.Elements, fn)
+	case Elements:
+		Inspect(n.Alternation, fn)
+	case Alternation:
+		for _, c := range n.Elements {
+			Inspect(c, fn)
+		}
+	case Concatenation:
+		for _, r := range n.Elements {
+			Inspect(r, fn)
+		}
+	case Repetition:
+		Inspect(n.Element, fn)
+	case Element:
+		Inspect(n.Inner, fn)
+	case Group:
+		Inspect(n.Elements, fn)
+	case Option:
+		Inspect(n.Elements, fn)
+	case Shaped:
+		Inspect(n.Elements, fn)
+	case Trie:
+		for _, l := range n.Literals {
+			Inspect(l, fn)
+		}
+	}
+}
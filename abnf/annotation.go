@@ -0,0 +1,199 @@
+package abnf
+
+import (
+	"fmt"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// Annotation is a pragma written in a comment, such as "; @type URI". Its
+// arguments are separated by whitespace, and may be quoted using Go syntax.
+//
+// Annotations are attached to the rule they appear in, or to the next rule
+// when written in the comment lines right above it. The following
+// annotations are understood:
+//
+//   - @suppress, @inline and @token set the shape of the rule;
+//   - @start marks the rule as the one inputs are parsed from;
+//   - @entry marks the rule as one inputs may also be parsed from;
+//   - @type Name names the Go type generated for the rule;
+//   - @field Name NewName renames a field of the types generated for the
+//     rule, or the type generated for one of its alternatives;
+//   - @label "description" replaces errors of the rule by "Expected
+//     description";
+//   - @case-insensitive makes literal strings of the rule match regardless
+//     of case, as RFC 5234 defines them; they are otherwise matched
+//     case-sensitively;
+//   - @fold-left marks a rule whose results are a head followed by tails to
+//     combine it with, as written by EliminateLeftRecursion.
+//
+// Other annotations are kept as they are, for use by other tools.
+type Annotation struct {
+	Name string
+	Args []string
+	Span parser.Span
+}
+
+func (a Annotation) String() string {
+	str := strings.Builder{}
+	str.WriteRune('@')
+	str.WriteString(a.Name)
+	for _, arg := range a.Args {
+		str.WriteRune(' ')
+		if strings.IndexFunc(arg, unicode.IsSpace) >= 0 || strings.HasPrefix(arg, `"`) || arg == "" {
+			arg = strconv.Quote(arg)
+		}
+		str.WriteString(arg)
+	}
+	return str.String()
+}
+
+const (
+	AnnotationStart           = "start"
+	AnnotationEntry           = "entry"
+	AnnotationType            = "type"
+	AnnotationField           = "field"
+	AnnotationLabel           = "label"
+	AnnotationCaseInsensitive = "case-insensitive"
+	AnnotationFoldLeft        = "fold-left"
+)
+
+// parseAnnotation returns the annotation held by comment, if any.
+func parseAnnotation(comment Comment) (Annotation, bool, error) {
+	text := strings.TrimSpace(comment.Value)
+	if !strings.HasPrefix(text, "@") {
+		return Annotation{}, false, nil
+	}
+	result := Annotation{Span: comment.Span}
+	rest := text[1:]
+	for i := 0; rest != ""; i++ {
+		var field string
+		if rest[0] == '"' {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return result, false, fmt.Errorf("invalid annotation %q: unterminated string", text)
+			}
+			quoted := rest[:end+1]
+			var err error
+			if field, err = strconv.Unquote(quoted); err != nil {
+				return result, false, fmt.Errorf("invalid annotation %q: %w", text, err)
+			}
+			rest = rest[len(quoted):]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			field, rest = rest[:end], rest[end:]
+		}
+		if i == 0 {
+			result.Name = field
+		} else {
+			result.Args = append(result.Args, field)
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	if result.Name == "" {
+		return result, false, fmt.Errorf("invalid annotation %q: missing name", text)
+	}
+	return result, true, nil
+}
+
+// annotate attaches the annotations found in comments to rule, validating
+// the ones it understands.
+func annotate(rule *Rule, comments []Comment) error {
+	for _, c := range comments {
+		a, ok, err := parseAnnotation(c)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := validateAnnotation(rule, a); err != nil {
+			return fmt.Errorf("rule %s: @%s: %w", rule.Name.Name, a.Name, err)
+		}
+		rule.Annotations = append(rule.Annotations, a)
+	}
+	return nil
+}
+
+func validateAnnotation(rule *Rule, a Annotation) error {
+	args := 0
+	switch a.Name {
+	case AnnotationStart, AnnotationEntry, AnnotationCaseInsensitive, AnnotationFoldLeft:
+	case AnnotationType:
+		args = 1
+		if len(a.Args) == 1 && !token.IsIdentifier(a.Args[0]) {
+			return fmt.Errorf("%q is not a valid Go identifier", a.Args[0])
+		}
+	case AnnotationField:
+		args = 2
+		for _, arg := range a.Args {
+			if !token.IsIdentifier(arg) {
+				return fmt.Errorf("%q is not a valid Go identifier", arg)
+			}
+		}
+	case AnnotationLabel:
+		args = 1
+	default:
+		shape, ok := parser.ShapeByName(a.Name)
+		if !ok || shape == parser.ShapeDefault {
+			return nil
+		}
+		if rule.Shape != parser.ShapeDefault && rule.Shape != shape {
+			return fmt.Errorf("conflicts with @%s", rule.Shape)
+		}
+		rule.Shape = shape
+	}
+	if len(a.Args) != args {
+		return fmt.Errorf("expected %d arguments, found %d", args, len(a.Args))
+	}
+	return nil
+}
+
+// Annotation returns the first annotation of r named name.
+func (r Rule) Annotation(name string) (Annotation, bool) {
+	for _, a := range r.Annotations {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Annotation{}, false
+}
+
+// HasAnnotation reports whether r holds an annotation named name.
+func (r Rule) HasAnnotation(name string) bool {
+	_, ok := r.Annotation(name)
+	return ok
+}
+
+// StartRule returns the name of the rule annotated with @start, or of the
+// first rule when none is.
+func (l *RuleList) StartRule() (string, error) {
+	var found []string
+	for _, r := range l.Rules {
+		if r.HasAnnotation(AnnotationStart) {
+			found = append(found, r.Name.Name)
+		}
+	}
+	switch {
+	case len(found) > 1:
+		return "", fmt.Errorf("more than one rule annotated with @start: %s", strings.Join(found, ", "))
+	case len(found) == 1:
+		return found[0], nil
+	case len(l.Rules) > 0:
+		return l.Rules[0].Name.Name, nil
+	}
+	return "", fmt.Errorf("empty rule list")
+}
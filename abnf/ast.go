@@ -0,0 +1,650 @@
+package abnf
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// GenerateAST returns the source of a Go package named pkg holding the rules
+// written by Generate, along with a type for each rule in list and an
+// ASTReducers map building values of those types from parse trees.
+//
+// Types follow the shape of the rules: concatenations become structs with a
+// field per element carrying a value, alternations become interfaces
+// implemented by a type per alternative, repetitions become slices and
+// options become pointers. Rules matching only terminals, and rules shaped as
+// tokens, become strings holding the text they matched, while suppressed
+// rules and fixed literals carry no value at all. @type renames the type of a
+// rule, and @field one of its fields or of the types of its alternatives.
+// Types clashing with the other identifiers of the package, such as Rules or
+// the Parse function of an entry rule, are reported and must be renamed.
+func GenerateAST(pkg string, list *RuleList) (string, error) {
+	order, alternatives, metas, err := mergeRules(list)
+	if err != nil {
+		return "", err
+	}
+	reserved, err := generatedNames(list, order)
+	if err != nil {
+		return "", err
+	}
+	g := &astGen{
+		rules:    map[string]*astRule{},
+		declared: map[string]bool{},
+		reserved: reserved,
+		variants: map[string][]string{},
+		asserted: map[string]bool{},
+	}
+	g.reserved["ASTReducers"] = "the ASTReducers map"
+	g.reserved["astAlternations"] = "the alternations of the grammar"
+	g.reserved["astReduction"] = "the reduction runtime"
+	for _, name := range order {
+		g.rules[name] = &astRule{
+			name:     name,
+			typeName: goName(name),
+			meta:     metas[name],
+			body:     alternatives[name],
+			text:     metas[name].shape == parser.ShapeToken,
+			fields:   map[string]string{},
+			used:     map[string]bool{},
+		}
+	}
+	for _, r := range list.Rules {
+		rule := g.rules[strings.ToLower(r.Name.Name)]
+		for _, a := range r.Annotations {
+			switch a.Name {
+			case AnnotationType:
+				rule.typeName = a.Args[0]
+			case AnnotationField:
+				rule.fields[a.Args[0]] = a.Args[1]
+			}
+		}
+	}
+
+	// Rules are strings when they only refer to rules that are.
+	for changed := true; changed; {
+		changed = false
+		for _, name := range order {
+			r := g.rules[name]
+			if !r.text && r.meta.shape != parser.ShapeSuppress && g.textual(r.body, true) {
+				r.text = true
+				changed = true
+			}
+		}
+	}
+
+	for _, r := range g.rules {
+		_, alt := unwrap(r.body).(Alternation)
+		r.iface = alt && !r.text
+	}
+
+	for _, name := range order {
+		if err := g.rule(g.rules[name]); err != nil {
+			return "", fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	rules, err := GenerateE(list)
+	if err != nil {
+		return "", err
+	}
+	sb := strings.Builder{}
+	sb.WriteString("// Code generated by goparse. DO NOT EDIT.\n\npackage " + pkg + "\n\n")
+	sb.WriteString("import (\n\"fmt\"\n\"sync\"\n\np \"github.com/heyvito/goparse/parser\"\n)\n\n")
+	sb.WriteString(rules)
+	sb.WriteString("\n\n")
+	sb.WriteString(g.output(order))
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+type astRule struct {
+	name     string
+	typeName string
+	meta     *ruleMeta
+	body     Alternation
+	text     bool
+	iface    bool
+	// fields holds the names given to fields by @field, and used those that
+	// were applied.
+	fields map[string]string
+	used   map[string]bool
+}
+
+// astValue describes the value built for part of a rule.
+type astValue struct {
+	typ   string
+	iface bool
+	// build returns an expression building the value from the atom held by
+	// the variable named by its argument.
+	build func(atom string) string
+}
+
+type astGen struct {
+	rules map[string]*astRule
+	decls []string
+	// declNames holds the name of the type declared by each of decls.
+	declNames []string
+	funcs     strings.Builder
+	declared  map[string]bool
+	// reserved holds the other package-level identifiers of the generated
+	// code, which types cannot be named after.
+	reserved map[string]string
+	// variants holds the types implementing each interface, some of which
+	// may be interfaces themselves.
+	variants map[string][]string
+	alts     []string
+	// asserted holds the types values are asserted to after being reduced.
+	asserted map[string]bool
+	current  *astRule
+	count    int
+}
+
+func (g *astGen) declare(name, decl string) error {
+	if what, ok := g.reserved[name]; ok {
+		return fmt.Errorf("type %s clashes with %s; rename it with @%s or @%s", name, what, AnnotationType, AnnotationField)
+	}
+	if g.declared[name] {
+		return fmt.Errorf("type %s is declared more than once; rename it with @%s or @%s", name, AnnotationType, AnnotationField)
+	}
+	g.declared[name] = true
+	g.decls = append(g.decls, decl)
+	g.declNames = append(g.declNames, name)
+	return nil
+}
+
+// method declares a method of astReduction building typ with body, and
+// returns a build function calling it.
+func (g *astGen) method(typ, body string) func(string) string {
+	g.count++
+	name := fmt.Sprintf("build%s_%d", g.current.typeName, g.count)
+	g.funcs.WriteString(fmt.Sprintf("func (r *astReduction) %s(a p.Atom) %s {\n%s\n}\n\n", name, typ, body))
+	return func(atom string) string { return fmt.Sprintf("r.%s(%s)", name, atom) }
+}
+
+func (g *astGen) rule(r *astRule) error {
+	if r.meta.shape == parser.ShapeSuppress {
+		return nil
+	}
+	g.current, g.count = r, 0
+	first := len(g.decls)
+	var build func(string) string
+	if r.text {
+		if err := g.declare(r.typeName, fmt.Sprintf("// %s is the text matched by %s.\ntype %s string", r.typeName, r.name, r.typeName)); err != nil {
+			return err
+		}
+		build = func(string) string { return fmt.Sprintf("%s(ctx.Text())", r.typeName) }
+	} else {
+		if r.meta.shape == parser.ShapeInline {
+			return fmt.Errorf("inlined rules must only match terminals")
+		}
+		v, err := g.named(r.body, r.typeName, fmt.Sprintf("// %s is the value of %s.\n", r.typeName, r.name))
+		if err != nil {
+			return err
+		}
+		build = v.build
+		// The type of the rule comes before the types it is made of.
+		for i := first; i < len(g.decls); i++ {
+			if g.declNames[i] == r.typeName {
+				decl := g.decls[i]
+				copy(g.decls[first+1:i+1], g.decls[first:i])
+				g.decls[first] = decl
+				copy(g.declNames[first+1:i+1], g.declNames[first:i])
+				g.declNames[first] = r.typeName
+				break
+			}
+		}
+	}
+	for from := range r.fields {
+		if !r.used[from] {
+			return fmt.Errorf("@%s: no field named %s", AnnotationField, from)
+		}
+	}
+	g.funcs.WriteString(fmt.Sprintf("func (r *astReduction) build%s(ctx *p.ReducerContext, a p.Atom) %s {\nreturn %s\n}\n\n", r.typeName, r.typeName, build("a")))
+	return nil
+}
+
+// assert returns a build function reducing an atom into typ.
+func (g *astGen) assert(typ string) func(string) string {
+	if !g.asserted[typ] {
+		g.asserted[typ] = true
+		g.funcs.WriteString(fmt.Sprintf("func (r *astReduction) as%s(a p.Atom) %s {\nv, _ := r.reduce(a).(%s)\nreturn v\n}\n\n", typ, typ, typ))
+	}
+	return func(atom string) string { return fmt.Sprintf("r.as%s(%s)", typ, atom) }
+}
+
+// inlined reports whether node stands for results spliced into the lists
+// holding them.
+func (g *astGen) inlined(node interface{}) bool {
+	switch n := unwrap(node).(type) {
+	case RuleName:
+		r, ok := g.rules[strings.ToLower(n.Name)]
+		return ok && r.meta.shape == parser.ShapeInline
+	case Shaped:
+		return n.Shape == parser.ShapeInline
+	}
+	return false
+}
+
+// unwrap returns the node standing for node, skipping groups and elements
+// made of a single node.
+func unwrap(node interface{}) interface{} {
+	for {
+		switch n := node.(type) {
+		case Elements:
+			node = n.Alternation
+		case Group:
+			node = n.Elements
+		case Element:
+			node = n.Inner
+		case Alternation:
+			if len(n.Elements) != 1 {
+				return n
+			}
+			node = n.Elements[0]
+		case Concatenation:
+			if len(n.Elements) != 1 {
+				return n
+			}
+			node = n.Elements[0]
+		case Repetition:
+			if n.Meta != nil {
+				return n
+			}
+			node = n.Element
+		default:
+			return n
+		}
+	}
+}
+
+// ruleOf returns the rule referred to by node, if it is a reference to a rule
+// of the grammar.
+func (g *astGen) ruleOf(node interface{}) (*astRule, bool) {
+	if ref, ok := unwrap(node).(RuleName); ok {
+		r, ok := g.rules[strings.ToLower(ref.Name)]
+		return r, ok
+	}
+	return nil, false
+}
+
+// textual reports whether node only matches terminals, or also rules whose
+// values are strings when rules is set.
+func (g *astGen) textual(node interface{}, rules bool) bool {
+	switch n := unwrap(node).(type) {
+	case RuleName:
+		r, ok := g.rules[strings.ToLower(n.Name)]
+		return !ok || (rules && r.text) || r.meta.shape == parser.ShapeSuppress
+	case Alternation:
+		for _, c := range n.Elements {
+			if !g.textual(c, rules) {
+				return false
+			}
+		}
+	case Concatenation:
+		for _, c := range n.Elements {
+			if !g.textual(c, rules) {
+				return false
+			}
+		}
+	case Repetition:
+		return g.textual(n.Element, rules)
+	case Option:
+		return g.textual(n.Elements, rules)
+	case Shaped:
+		return g.textual(n.Elements, rules)
+	}
+	return true
+}
+
+// fixedCore holds the core rules always matching the same text.
+var fixedCore = map[string]bool{"cr": true, "lf": true, "crlf": true, "dquote": true, "htab": true, "sp": true}
+
+// valueless reports whether node carries no value, either because it always
+// matches the same text or because its results are suppressed.
+func (g *astGen) valueless(node interface{}) bool {
+	switch n := unwrap(node).(type) {
+	case RuleName:
+		if r, ok := g.rules[strings.ToLower(n.Name)]; ok {
+			return r.meta.shape == parser.ShapeSuppress
+		}
+		return fixedCore[strings.ToLower(n.Name)]
+	case Concatenation:
+		for _, c := range n.Elements {
+			if !g.valueless(c) {
+				return false
+			}
+		}
+		return true
+	case Repetition:
+		return g.suppressed(n.Element)
+	case CharVal, Literal:
+		return true
+	case HexVal:
+		return n.Mode != NumericModeRange
+	case DecVal:
+		return n.Mode != NumericModeRange
+	case BinVal:
+		return n.Mode != NumericModeRange
+	case Shaped:
+		return n.Shape == parser.ShapeSuppress
+	}
+	return false
+}
+
+// suppressed reports whether node produces no atom at all.
+func (g *astGen) suppressed(node interface{}) bool {
+	switch n := unwrap(node).(type) {
+	case RuleName:
+		r, ok := g.rules[strings.ToLower(n.Name)]
+		return ok && r.meta.shape == parser.ShapeSuppress
+	case Shaped:
+		return n.Shape == parser.ShapeSuppress
+	}
+	return false
+}
+
+// value describes the value of node. Structs and interfaces it requires are
+// declared as name.
+func (g *astGen) value(node interface{}, name string) (astValue, error) {
+	node = unwrap(node)
+	if r, ok := g.ruleOf(node); ok && r.meta.shape != parser.ShapeSuppress {
+		if !r.text && r.meta.shape == parser.ShapeInline {
+			return astValue{}, fmt.Errorf("cannot refer to inlined rule %s", r.name)
+		}
+		return astValue{typ: r.typeName, iface: r.iface, build: g.assert(r.typeName)}, nil
+	}
+	if g.textual(node, false) {
+		return astValue{typ: "string", build: func(atom string) string { return fmt.Sprintf("p.Text(%s)", atom) }}, nil
+	}
+
+	switch n := node.(type) {
+	case Repetition:
+		elem, err := g.value(n.Element, name)
+		if err != nil {
+			return astValue{}, err
+		}
+		typ := "[]" + elem.typ
+		return astValue{typ: typ, build: g.method(typ, fmt.Sprintf(
+			"var v %s\nfor _, c := range a.Children() {\nv = append(v, %s)\n}\nreturn v", typ, elem.build("c")))}, nil
+	case Option:
+		inner, err := g.value(n.Elements, name)
+		if err != nil {
+			return astValue{}, err
+		}
+		typ, ret := "*"+inner.typ, "&v"
+		if inner.iface || strings.HasPrefix(inner.typ, "[]") {
+			typ, ret = inner.typ, "v"
+		}
+		return astValue{typ: typ, iface: inner.iface, build: g.method(typ, fmt.Sprintf(
+			"o, _ := a.(p.OptionVal)\nif !o.Valid {\nreturn nil\n}\nv := %s\nreturn %s", inner.build("o.Children()[0]"), ret))}, nil
+	case Concatenation:
+		return g.structure(n.Elements, name, "", false, false)
+	case Alternation:
+		if err := g.declare(name, fmt.Sprintf("type %s interface {\nis%s()\n}", name, name)); err != nil {
+			return astValue{}, err
+		}
+		g.variants[name] = []string{}
+		return g.alternation(n, name)
+	}
+	return astValue{}, fmt.Errorf("cannot derive a type for %s", Format(node))
+}
+
+// named describes the value of node as a type declared as name, preceded by
+// doc.
+func (g *astGen) named(node interface{}, name, doc string) (astValue, error) {
+	node = unwrap(node)
+	if _, ok := g.ruleOf(node); !ok {
+		if g.textual(node, false) {
+			if err := g.declare(name, fmt.Sprintf("%stype %s string", doc, name)); err != nil {
+				return astValue{}, err
+			}
+			return astValue{typ: name, build: func(atom string) string { return fmt.Sprintf("%s(p.Text(%s))", name, atom) }}, nil
+		}
+		switch n := node.(type) {
+		case Concatenation:
+			return g.structure(n.Elements, name, doc, true, false)
+		case Alternation:
+			if err := g.declare(name, fmt.Sprintf("%stype %s interface {\nis%s()\n}", doc, name, name)); err != nil {
+				return astValue{}, err
+			}
+			g.variants[name] = []string{}
+			return g.alternation(n, name)
+		}
+		if rep, ok := node.(Repetition); ok {
+			elem, err := g.value(rep.Element, name+"Item")
+			if err != nil {
+				return astValue{}, err
+			}
+			if err := g.declare(name, fmt.Sprintf("%stype %s []%s", doc, name, elem.typ)); err != nil {
+				return astValue{}, err
+			}
+			return astValue{typ: name, build: g.method(name, fmt.Sprintf(
+				"var v %s\nfor _, c := range a.Children() {\nv = append(v, %s)\n}\nreturn v", name, elem.build("c")))}, nil
+		}
+	}
+	return g.structure([]Repetition{{Element: Element{Inner: node.(Node)}}}, name, doc, true, true)
+}
+
+// structure describes the value of a concatenation of elements as a struct
+// declared as name, or as the value of its only field unless the struct is
+// required. single is set when elements stand for a single node, whose atom
+// is not held by a list.
+func (g *astGen) structure(elements []Repetition, name, doc string, required, single bool) (astValue, error) {
+	type field struct {
+		name  string
+		value astValue
+		slot  int
+	}
+	var fields []field
+	seen := map[string]int{}
+	slot := 0
+	for i, el := range elements {
+		if g.suppressed(el) {
+			continue
+		}
+		if g.inlined(el) {
+			return astValue{}, fmt.Errorf("cannot derive a type for %s, as it is inlined", Format(el))
+		}
+		slot++
+		if g.valueless(el) {
+			continue
+		}
+		fname := fmt.Sprintf("Item%d", i+1)
+		if r, ok := g.ruleOf(el); ok {
+			fname = goName(r.name)
+		} else if el.Meta != nil {
+			if r, ok := g.ruleOf(el.Element); ok {
+				fname = goName(r.name)
+			}
+		} else if opt, ok := unwrap(el).(Option); ok {
+			if r, ok := g.ruleOf(opt.Elements); ok {
+				fname = goName(r.name)
+			}
+		}
+		if seen[fname]++; seen[fname] > 1 {
+			fname = fmt.Sprintf("%s%d", fname, seen[fname])
+		}
+		if to, ok := g.current.fields[fname]; ok {
+			g.current.used[fname] = true
+			fname = to
+		}
+		v, err := g.value(el, name+fname)
+		if err != nil {
+			return astValue{}, err
+		}
+		fields = append(fields, field{fname, v, slot - 1})
+	}
+
+	atom := func(f field) string {
+		if single {
+			return "a"
+		}
+		return fmt.Sprintf("l[%d]", f.slot)
+	}
+	if !required && len(fields) == 1 {
+		f := fields[0]
+		return astValue{typ: f.value.typ, iface: f.value.iface, build: g.method(f.value.typ, fmt.Sprintf(
+			"l := a.Children()\nif len(l) != %d {\nr.mismatch(a)\nvar v %s\nreturn v\n}\nreturn %s", slot, f.value.typ, f.value.build(atom(f))))}, nil
+	}
+
+	decl := strings.Builder{}
+	decl.WriteString(fmt.Sprintf("%stype %s struct {\n", doc, name))
+	body := strings.Builder{}
+	if !single {
+		body.WriteString(fmt.Sprintf("l := a.Children()\nif len(l) != %d {\nr.mismatch(a)\nreturn %s{}\n}\n", slot, name))
+	}
+	body.WriteString(fmt.Sprintf("return %s{\n", name))
+	for _, f := range fields {
+		decl.WriteString(fmt.Sprintf("%s %s\n", f.name, f.value.typ))
+		body.WriteString(fmt.Sprintf("%s: %s,\n", f.name, f.value.build(atom(f))))
+	}
+	decl.WriteString("}")
+	body.WriteString("}")
+	if err := g.declare(name, decl.String()); err != nil {
+		return astValue{}, err
+	}
+	return astValue{typ: name, build: g.method(name, body.String())}, nil
+}
+
+// alternation describes the value of alt as the interface name, declaring a
+// type for each alternative that is not a reference to a rule. Alternatives
+// made of a group of alternatives implement name too.
+func (g *astGen) alternation(alt Alternation, name string) (astValue, error) {
+	sb := strings.Builder{}
+	sb.WriteString("p.Alt(")
+	for _, c := range alt.Elements {
+		writeElement(c, &sb, g.current.meta.fold)
+		sb.WriteString(",")
+	}
+	sb.WriteString(")")
+	g.alts = append(g.alts, sb.String())
+	index := len(g.alts) - 1
+
+	body := strings.Builder{}
+	body.WriteString(fmt.Sprintf("switch p.Alternative(parser, astAlternations[%d], a) {\n", index))
+	for i, c := range alt.Elements {
+		if g.suppressed(c) {
+			return astValue{}, fmt.Errorf("alternative %s carries no atom", Format(c))
+		}
+		var build func(string) string
+		if r, ok := g.ruleOf(c); ok {
+			if _, err := g.value(c, name); err != nil {
+				return astValue{}, err
+			}
+			g.variants[name] = append(g.variants[name], r.typeName)
+			build = g.assert(name)
+		} else if inner, ok := unwrap(c).(Alternation); ok && !g.textual(inner, false) {
+			v, err := g.alternation(inner, name)
+			if err != nil {
+				return astValue{}, err
+			}
+			build = v.build
+		} else {
+			variant := fmt.Sprintf("%s%d", name, i+1)
+			if to, ok := g.current.fields[variant]; ok {
+				g.current.used[variant] = true
+				variant = to
+			}
+			v, err := g.named(c, variant, "")
+			if err != nil {
+				return astValue{}, err
+			}
+			g.variants[name] = append(g.variants[name], variant)
+			build = v.build
+		}
+		body.WriteString(fmt.Sprintf("case %d:\nreturn %s\n", i, build("a")))
+	}
+	body.WriteString("}\nr.mismatch(a)\nreturn nil")
+	return astValue{typ: name, iface: true, build: g.method(name, body.String())}, nil
+}
+
+// implementations returns the types implementing the interface name, other
+// than interfaces.
+func (g *astGen) implementations(name string, seen map[string]bool) []string {
+	var result []string
+	for _, v := range g.variants[name] {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		if _, ok := g.variants[v]; ok {
+			result = append(result, g.implementations(v, seen)...)
+		} else {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (g *astGen) output(order []string) string {
+	sb := strings.Builder{}
+	for _, d := range g.decls {
+		sb.WriteString(d)
+		sb.WriteString("\n\n")
+	}
+
+	var ifaces []string
+	for name := range g.variants {
+		ifaces = append(ifaces, name)
+	}
+	sort.Strings(ifaces)
+	for _, name := range ifaces {
+		for _, v := range g.implementations(name, map[string]bool{}) {
+			sb.WriteString(fmt.Sprintf("func (%s) is%s() {}\n", v, name))
+		}
+		sb.WriteRune('\n')
+	}
+
+	sb.WriteString("// ASTReducers builds the types above from the trees of their rules.\n")
+	sb.WriteString("var ASTReducers = map[string]p.ReducerE{\n")
+	for _, name := range order {
+		r := g.rules[name]
+		if r.meta.shape == parser.ShapeSuppress {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%q: func(ctx *p.ReducerContext) (interface{}, error) {\n", name))
+		sb.WriteString("r := &astReduction{ctx: ctx}\na, _ := ctx.Value.(p.Atom)\n")
+		sb.WriteString(fmt.Sprintf("v := r.build%s(ctx, a)\nreturn v, r.err\n},\n", r.typeName))
+	}
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("var astAlternations = []*p.AlternationConsumer{\n")
+	for _, a := range g.alts {
+		sb.WriteString(a)
+		sb.WriteString(",\n")
+	}
+	sb.WriteString("}\n\n")
+	sb.WriteString(astRuntime)
+	sb.WriteString(g.funcs.String())
+	return sb.String()
+}
+
+const astRuntime = `// astReduction holds the state of a reducer of ASTReducers.
+type astReduction struct {
+	ctx *p.ReducerContext
+	err error
+}
+
+func (r *astReduction) reduce(a p.Atom) interface{} {
+	v, err := r.ctx.ReduceE(a)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *astReduction) mismatch(a p.Atom) {
+	if a == nil {
+		r.err = fmt.Errorf("missing value")
+	} else if r.err == nil {
+		r.err = fmt.Errorf("unexpected %s at position %d", a.Kind(), a.Span().Start)
+	}
+}
+
+`
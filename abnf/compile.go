@@ -0,0 +1,268 @@
+package abnf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// Compile turns a RuleList into a rule map usable by parser.KickoffParser,
+// without going through code generation. Rules defined with "=/" are merged
+// into the alternatives of their base rule, and annotations of either apply
+// to the whole rule.
+func Compile(list *RuleList) (map[string]parser.Consumer, error) {
+	order, alternatives, metas, err := mergeRules(list)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string]parser.Consumer{}
+	for _, name := range order {
+		meta := metas[name]
+		con, err := compileElement(alternatives[name], meta.fold)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		if meta.label != "" {
+			con = parser.Label(meta.label, con)
+		}
+		rules[name] = parser.WithShape(meta.shape, con)
+	}
+	return parser.MakeRules(rules), nil
+}
+
+// mergeRules returns the names of the rules in list, in the order they are
+// defined, along with their alternatives and annotations. Incremental
+// alternatives are merged into their base rule.
+func mergeRules(list *RuleList) ([]string, map[string]Alternation, map[string]*ruleMeta, error) {
+	alternatives := map[string]Alternation{}
+	metas := map[string]*ruleMeta{}
+	var order []string
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		alt, ok := alternatives[name]
+		if metas[name] == nil {
+			metas[name] = &ruleMeta{}
+		}
+		if err := metas[name].merge(r); err != nil {
+			return nil, nil, nil, fmt.Errorf("rule %s: %w", r.Name.Name, err)
+		}
+		if r.DefinedAs.Value == "=/" {
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("rule %s: incremental alternative defined before its base rule", r.Name.Name)
+			}
+			alt.Elements = append(alt.Elements, r.Elements.Alternation.Elements...)
+			alternatives[name] = alt
+			continue
+		}
+		if ok {
+			return nil, nil, nil, fmt.Errorf("rule %s: defined more than once", r.Name.Name)
+		}
+		alternatives[name] = r.Elements.Alternation
+		order = append(order, name)
+	}
+	return order, alternatives, metas, nil
+}
+
+// ruleMeta holds the annotations affecting how a rule is compiled or
+// generated.
+type ruleMeta struct {
+	shape parser.Shape
+	label string
+	fold  bool
+}
+
+func (m *ruleMeta) merge(r Rule) error {
+	if r.Shape != parser.ShapeDefault {
+		if m.shape != parser.ShapeDefault && m.shape != r.Shape {
+			return fmt.Errorf("conflicting shapes %s and %s", m.shape, r.Shape)
+		}
+		m.shape = r.Shape
+	}
+	if a, ok := r.Annotation(AnnotationLabel); ok {
+		if m.label != "" && m.label != a.Args[0] {
+			return fmt.Errorf("conflicting labels %q and %q", m.label, a.Args[0])
+		}
+		m.label = a.Args[0]
+	}
+	m.fold = m.fold || r.HasAnnotation(AnnotationCaseInsensitive)
+	return nil
+}
+
+// CompileElement returns the consumer matching a single grammar node, following
+// the same translation used by WriteElement.
+func CompileElement(element interface{}) (parser.Consumer, error) {
+	return compileElement(element, false)
+}
+
+// compileElement compiles element, matching literal strings regardless of
+// case when fold is set.
+func compileElement(element interface{}, fold bool) (parser.Consumer, error) {
+	switch el := element.(type) {
+	case Elements:
+		return compileElement(el.Alternation, fold)
+	case Alternation:
+		if len(el.Elements) == 1 {
+			return compileElement(el.Elements[0], fold)
+		}
+		if ranges, ok := classRanges(el, fold); ok {
+			return compileCharClass(ranges), nil
+		}
+		cons, err := compileAll(len(el.Elements), fold, func(i int) interface{} { return el.Elements[i] })
+		if err != nil {
+			return nil, err
+		}
+		return parser.Alt(cons...), nil
+	case Concatenation:
+		if len(el.Elements) == 1 {
+			return compileElement(el.Elements[0], fold)
+		}
+		cons, err := compileAll(len(el.Elements), fold, func(i int) interface{} { return el.Elements[i] })
+		if err != nil {
+			return nil, err
+		}
+		return parser.Cat(cons...), nil
+	case Repetition:
+		con, err := compileElement(el.Element, fold)
+		if err != nil || el.Meta == nil {
+			return con, err
+		}
+		if el.Meta.Min == 0 && el.Meta.Max == 0 {
+			return parser.Star(con), nil
+		} else if el.Meta.Min == 1 && el.Meta.Max == 0 {
+			return parser.Plus(con), nil
+		} else if el.Meta.Max != 0 && el.Meta.Min > el.Meta.Max {
+			return nil, fmt.Errorf("repetition %d*%d cannot match, as its minimum exceeds its maximum", el.Meta.Min, el.Meta.Max)
+		}
+		return parser.Repeat(el.Meta.Min, el.Meta.Max, con), nil
+	case Group:
+		return compileElement(el.Elements, fold)
+	case Element:
+		return compileElement(el.Inner, fold)
+	case RuleName:
+		if con, ok := parser.CoreConsumers[strings.ToLower(el.Name)]; ok {
+			return con, nil
+		}
+		return parser.Ref(el.Name), nil
+	case Option:
+		con, err := compileElement(el.Elements, fold)
+		if err != nil {
+			return nil, err
+		}
+		return parser.Opt(con), nil
+	case CharVal:
+		if fold && hasLetters(el.Value) {
+			return parser.StrFold(el.Value), nil
+		}
+		if len(el.Value) == 1 {
+			return parser.Lit(rune(el.Value[0])), nil
+		}
+		return parser.Str(el.Value), nil
+	case HexVal:
+		return compileNumeric(el.Numeric)
+	case DecVal:
+		return compileNumeric(el.Numeric)
+	case BinVal:
+		return compileNumeric(el.Numeric)
+	case ProseVal:
+		return nil, fmt.Errorf("prose value <%s> cannot be compiled", el.Value)
+	case CharSet:
+		if len(el.Ranges) > 1 {
+			return compileCharClass(el.Ranges), nil
+		}
+		r := el.Ranges[0]
+		if r.From == r.To {
+			return parser.Lit(rune(r.From)), nil
+		}
+		return parser.HexRange(rune(r.From), rune(r.To)), nil
+	case Literal:
+		return compileLiteral(el), nil
+	case Trie:
+		cons := make([]parser.Consumer, len(el.Literals))
+		for i, l := range el.Literals {
+			cons[i] = compileLiteral(l)
+		}
+		return parser.Trie(cons...), nil
+	case Shaped:
+		var con parser.Consumer
+		var err error
+		if el.Shape == parser.ShapeInline {
+			// Alternatives are kept as concatenations, for their results to
+			// be spliced into the enclosing one.
+			cons := make([]parser.Consumer, len(el.Elements.Elements))
+			for i, c := range el.Elements.Elements {
+				elements, err := compileAll(len(c.Elements), el.Fold, func(i int) interface{} { return c.Elements[i] })
+				if err != nil {
+					return nil, err
+				}
+				cons[i] = parser.Cat(elements...)
+			}
+			con = cons[0]
+			if len(cons) > 1 {
+				con = parser.Alt(cons...)
+			}
+		} else if con, err = compileElement(el.Elements, el.Fold); err != nil {
+			return nil, err
+		}
+		if el.Label != "" {
+			con = parser.Label(el.Label, con)
+		}
+		return parser.WithShape(el.Shape, con), nil
+	}
+	return nil, fmt.Errorf("cannot compile %T", element)
+}
+
+func compileCharClass(ranges []Range) parser.Consumer {
+	class := make([]parser.CharRange, len(ranges))
+	for i, r := range ranges {
+		class[i] = parser.CharRange{From: rune(r.From), To: rune(r.To)}
+	}
+	return parser.CharClass(class...)
+}
+
+func compileLiteral(l Literal) parser.Consumer {
+	switch {
+	case l.Fold:
+		return parser.LiteralFold(l.Value)
+	case len([]rune(l.Value)) == 1:
+		return parser.Lit([]rune(l.Value)[0])
+	}
+	return parser.Literal(l.Value)
+}
+
+func hasLetters(s string) bool {
+	return strings.ToLower(s) != strings.ToUpper(s)
+}
+
+func compileAll(n int, fold bool, at func(i int) interface{}) ([]parser.Consumer, error) {
+	cons := make([]parser.Consumer, n)
+	for i := range cons {
+		con, err := compileElement(at(i), fold)
+		if err != nil {
+			return nil, err
+		}
+		cons[i] = con
+	}
+	return cons, nil
+}
+
+func compileNumeric(n Numeric) (parser.Consumer, error) {
+	switch n.Mode {
+	case NumericModeSingle:
+		return parser.Lit(rune(n.Single)), nil
+	case NumericModeRange:
+		return parser.HexRange(rune(n.Range.From), rune(n.Range.To)), nil
+	case NumericModeSequence:
+		// The ABNF reducer does not collect the values of sequences yet.
+		if len(n.Sequence) == 0 {
+			return nil, fmt.Errorf("numeric sequence without values cannot be compiled")
+		}
+		cons := make([]parser.Consumer, len(n.Sequence))
+		for i, v := range n.Sequence {
+			cons[i] = parser.Lit(rune(v))
+		}
+		return parser.Cat(cons...), nil
+	}
+	return nil, fmt.Errorf("invalid numeric mode %d", n.Mode)
+}
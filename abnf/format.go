@@ -0,0 +1,164 @@
+package abnf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format returns the ABNF text of node, which may be a RuleList, a Rule or any
+// of their elements. Rules are terminated by CRLF, and preceded by their
+// comments, so that the text of a RuleList can be parsed back.
+func Format(node interface{}) string {
+	sb := strings.Builder{}
+	writeABNF(node, &sb)
+	return sb.String()
+}
+
+func writeABNF(node interface{}, sb *strings.Builder) {
+	switch n := node.(type) {
+	case *RuleList:
+		for _, r := range n.Rules {
+			writeABNF(r, sb)
+		}
+	case RuleList:
+		writeABNF(&n, sb)
+	case Rule:
+		for _, c := range n.Comments {
+			sb.WriteRune(';')
+			sb.WriteString(c.Value)
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(n.Name.Name)
+		definedAs := n.DefinedAs.Value
+		if definedAs == "" {
+			definedAs = "="
+		}
+		sb.WriteString(" " + definedAs + " ")
+		writeABNF(n.Elements, sb)
+		sb.WriteString("\r\n")
+	case Elements:
+		writeABNF(n.Alternation, sb)
+	case Alternation:
+		for i, c := range n.Elements {
+			if i > 0 {
+				sb.WriteString(" / ")
+			}
+			writeABNF(c, sb)
+		}
+	case Concatenation:
+		for i, r := range n.Elements {
+			if i > 0 {
+				sb.WriteRune(' ')
+			}
+			writeABNF(r, sb)
+		}
+	case Repetition:
+		if m := n.Meta; m != nil {
+			switch {
+			case m.Min == m.Max && m.Min != 0:
+				sb.WriteString(fmt.Sprint(m.Min))
+			default:
+				if m.Min != 0 {
+					sb.WriteString(fmt.Sprint(m.Min))
+				}
+				sb.WriteRune('*')
+				if m.Max != 0 {
+					sb.WriteString(fmt.Sprint(m.Max))
+				}
+			}
+		}
+		writeABNF(n.Element, sb)
+	case Element:
+		writeABNF(n.Inner, sb)
+	case Group:
+		sb.WriteString("( ")
+		writeABNF(n.Elements, sb)
+		sb.WriteString(" )")
+	case Option:
+		sb.WriteString("[ ")
+		writeABNF(n.Elements, sb)
+		sb.WriteString(" ]")
+	case RuleName:
+		sb.WriteString(n.Name)
+	case CharVal:
+		sb.WriteString(`"` + n.Value + `"`)
+	case HexVal:
+		writeNumeric(n.Numeric, "x", "%02X", sb)
+	case DecVal:
+		writeNumeric(n.Numeric, "d", "%d", sb)
+	case BinVal:
+		writeNumeric(n.Numeric, "b", "%b", sb)
+	case ProseVal:
+		sb.WriteString("<" + n.Value + ">")
+	case CharSet:
+		if len(n.Ranges) > 1 {
+			sb.WriteString("( ")
+		}
+		for i, r := range n.Ranges {
+			if i > 0 {
+				sb.WriteString(" / ")
+			}
+			if r.From == r.To {
+				writeNumeric(Numeric{Mode: NumericModeSingle, Single: r.From}, "x", "%02X", sb)
+			} else {
+				writeNumeric(Numeric{Mode: NumericModeRange, Range: r}, "x", "%02X", sb)
+			}
+		}
+		if len(n.Ranges) > 1 {
+			sb.WriteString(" )")
+		}
+	case Literal:
+		if strings.IndexFunc(n.Value, func(r rune) bool { return r < 0x20 || r > 0x7E || r == '"' }) < 0 {
+			sb.WriteString(`"` + n.Value + `"`)
+			return
+		}
+		seq := Numeric{Mode: NumericModeSequence}
+		for _, r := range n.Value {
+			seq.Sequence = append(seq.Sequence, int(r))
+		}
+		if len(seq.Sequence) == 1 {
+			seq = Numeric{Mode: NumericModeSingle, Single: seq.Sequence[0]}
+		}
+		writeNumeric(seq, "x", "%02X", sb)
+	case Trie:
+		sb.WriteString("( ")
+		for i, l := range n.Literals {
+			if i > 0 {
+				sb.WriteString(" / ")
+			}
+			writeABNF(l, sb)
+		}
+		sb.WriteString(" )")
+	case Shaped:
+		// Hoisting a prefix may leave an empty alternative behind.
+		sb.WriteString("( ")
+		for i, c := range n.Elements.Elements {
+			if i > 0 {
+				sb.WriteString(" / ")
+			}
+			if len(c.Elements) == 0 {
+				sb.WriteString(`""`)
+			}
+			writeABNF(c, sb)
+		}
+		sb.WriteString(" )")
+	}
+}
+
+func writeNumeric(n Numeric, base, verb string, sb *strings.Builder) {
+	sb.WriteRune('%')
+	sb.WriteString(base)
+	switch n.Mode {
+	case NumericModeSingle:
+		sb.WriteString(fmt.Sprintf(verb, n.Single))
+	case NumericModeRange:
+		sb.WriteString(fmt.Sprintf(verb+"-"+verb, n.Range.From, n.Range.To))
+	case NumericModeSequence:
+		for i, v := range n.Sequence {
+			if i > 0 {
+				sb.WriteRune('.')
+			}
+			sb.WriteString(fmt.Sprintf(verb, v))
+		}
+	}
+}
@@ -7,19 +7,196 @@ import (
 	"github.com/heyvito/goparse/parser"
 )
 
+// Generate returns Go code declaring the rules in list. Annotations are
+// honoured the same way as by Compile, and written as comments above their
+// rules. When a rule is annotated with @start, a StartRule constant holds its
+// name.
+//
+// A Rule constant is declared with the name of each rule, such as
+// RuleDefinedAs for defined-as, along with a Reducers interface holding a
+// method for each rule that is reduced, and a ReducerMap function turning
+// its implementations into reducer maps. Rules returns the rule map, and
+// Parse and Match functions, such as ParseRulelist, are declared for the
+// start rule and the rules annotated with @entry. The code refers to the
+// parser package as p, and to the sync package.
+//
+// Rule names are case-insensitive, and written in lower case. Generate
+// returns an empty string when list cannot be generated.
+//
+// Deprecated: use GenerateE, which reports why list cannot be generated.
 func Generate(list *RuleList) string {
+	src, _ := GenerateE(list)
+	return src
+}
+
+// GenerateE is like Generate, but returns an error when list cannot be
+// generated. Like Compile, it merges incremental alternatives into their base
+// rule, and reports rules defined more than once or whose annotations
+// conflict, along with grammars whose start rule cannot be told.
+func GenerateE(list *RuleList) (string, error) {
+	order, alternatives, metas, err := mergeRules(list)
+	if err != nil {
+		return "", err
+	}
+	if err := checkGoNames(order); err != nil {
+		return "", err
+	}
+	annotations := map[string][]Annotation{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		annotations[name] = append(annotations[name], r.Annotations...)
+	}
+
 	sb := strings.Builder{}
 	sb.WriteString("var parser = map[string]p.Consumer{\n")
-	for _, r := range list.Rules {
+	for _, name := range order {
+		meta := metas[name]
+		for _, a := range annotations[name] {
+			sb.WriteString("// ")
+			sb.WriteString(a.String())
+			sb.WriteRune('\n')
+		}
 		sb.WriteRune('"')
-		sb.WriteString(r.Name.Name)
+		sb.WriteString(name)
 		sb.WriteString(`": `)
-		WriteElement(r.Elements, &sb)
+		if meta.shape != parser.ShapeDefault {
+			sb.WriteString("p.")
+			sb.WriteString(shapeFuncs[meta.shape])
+			sb.WriteRune('(')
+		}
+		if meta.label != "" {
+			sb.WriteString(fmt.Sprintf("p.Label(%q, ", meta.label))
+		}
+		writeElement(alternatives[name], &sb, meta.fold)
+		if meta.label != "" {
+			sb.WriteRune(')')
+		}
+		if meta.shape != parser.ShapeDefault {
+			sb.WriteRune(')')
+		}
 		sb.WriteString(",\n")
 	}
 	sb.WriteRune('}')
 
-	return sb.String()
+	for _, r := range list.Rules {
+		if r.HasAnnotation(AnnotationStart) {
+			sb.WriteString(fmt.Sprintf("\n\nconst StartRule = %q", strings.ToLower(r.Name.Name)))
+			break
+		}
+	}
+
+	writeReducers(order, metas, &sb)
+	if err := writeEntryPoints(list, &sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// generatedNames returns the package-level identifiers declared by GenerateE
+// for list, whose rules are named by order, mapped to what they are, so that
+// other code generated along with them can avoid redeclaring them.
+func generatedNames(list *RuleList, order []string) (map[string]string, error) {
+	entries, err := entryRules(list)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{
+		"parser":       "the rule map",
+		"StartRule":    "the StartRule constant",
+		"Reducers":     "the Reducers interface",
+		"ReducerMap":   "the ReducerMap function",
+		"Rules":        "the Rules function",
+		"parserOnce":   "the shared parser",
+		"sharedParser": "the shared parser",
+		"rulesParser":  "the shared parser",
+	}
+	for _, name := range order {
+		names["Rule"+goName(name)] = fmt.Sprintf("the constant naming rule %s", name)
+	}
+	for _, name := range entries {
+		names["Parse"+goName(name)] = fmt.Sprintf("the entry point of rule %s", name)
+		names["Match"+goName(name)] = fmt.Sprintf("the entry point of rule %s", name)
+	}
+	return names, nil
+}
+
+// writeReducers writes the Rule constants, the Reducers interface and the
+// ReducerMap function for the rules named by order. Suppressed and inlined
+// rules are never reduced, and are left out of Reducers.
+func writeReducers(order []string, metas map[string]*ruleMeta, sb *strings.Builder) {
+	sb.WriteString("\n\n// Names of the rules of the grammar.\nconst (\n")
+	for _, name := range order {
+		sb.WriteString(fmt.Sprintf("Rule%s = %q\n", goName(name), name))
+	}
+	sb.WriteString(")\n\n")
+
+	var reduced []string
+	for _, name := range order {
+		if shape := metas[name].shape; shape != parser.ShapeSuppress && shape != parser.ShapeInline {
+			reduced = append(reduced, name)
+		}
+	}
+	sb.WriteString("// Reducers holds a method reducing each rule of the grammar.\ntype Reducers interface {\n")
+	for _, name := range reduced {
+		sb.WriteString(fmt.Sprintf("%s(ctx *p.ReducerContext) interface{}\n", goName(name)))
+	}
+	sb.WriteString("}\n\n")
+	sb.WriteString("// ReducerMap returns the methods of r keyed by the rules they reduce.\n")
+	sb.WriteString("func ReducerMap(r Reducers) map[string]p.Reducer {\nreturn map[string]p.Reducer{\n")
+	for _, name := range reduced {
+		sb.WriteString(fmt.Sprintf("Rule%s: r.%s,\n", goName(name), goName(name)))
+	}
+	sb.WriteString("}\n}")
+}
+
+// entryRules returns the start rule of list, followed by the rules annotated
+// with @entry, in lower case.
+func entryRules(list *RuleList) ([]string, error) {
+	start, err := list.StartRule()
+	if err != nil {
+		return nil, err
+	}
+	entries := []string{strings.ToLower(start)}
+	seen := map[string]bool{entries[0]: true}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		if r.HasAnnotation(AnnotationEntry) && !seen[name] {
+			entries = append(entries, name)
+			seen[name] = true
+		}
+	}
+	return entries, nil
+}
+
+// writeEntryPoints writes the Rules function of list, along with Parse and
+// Match functions for its start rule and the rules annotated with @entry.
+// The parser they share is only built once first used, rather than when the
+// generated package is initialised.
+func writeEntryPoints(list *RuleList, sb *strings.Builder) error {
+	entries, err := entryRules(list)
+	if err != nil {
+		return err
+	}
+	sb.WriteString("\n\n// Rules returns the rules of the grammar along with the core rules, for use\n")
+	sb.WriteString("// with p.New or by other grammars.\nfunc Rules() map[string]p.Consumer {\nreturn p.MakeRules(parser)\n}\n\n")
+	sb.WriteString("var (\nparserOnce sync.Once\nsharedParser *p.Parser\n)\n\n")
+	sb.WriteString("// rulesParser returns the parser used by the Parse and Match functions.\n")
+	sb.WriteString("func rulesParser() *p.Parser {\nparserOnce.Do(func() {\nsharedParser = p.New(Rules())\n})\nreturn sharedParser\n}\n")
+
+	for _, name := range entries {
+		fn := goName(name)
+		sb.WriteString(fmt.Sprintf("\n// Parse%s parses input as %s, the whole of which must match.\n", fn, name))
+		sb.WriteString(fmt.Sprintf("func Parse%s(input string) (p.Atom, error) {\nreturn rulesParser().Parse(Rule%s, input)\n}\n", fn, fn))
+		sb.WriteString(fmt.Sprintf("\n// Match%s reports whether the whole of input matches %s.\n", fn, name))
+		sb.WriteString(fmt.Sprintf("func Match%s(input string) bool {\nreturn rulesParser().Match(Rule%s, input)\n}\n", fn, fn))
+	}
+	return nil
+}
+
+var shapeFuncs = map[parser.Shape]string{
+	parser.ShapeSuppress: "Suppress",
+	parser.ShapeInline:   "Inline",
+	parser.ShapeToken:    "Token",
 }
 
 func escapeLit(lit string) string {
@@ -36,35 +213,64 @@ func escapeString(str string) string {
 	return strings.ReplaceAll(str, "\"", "\\\"")
 }
 
+func writeCharClass(ranges []Range, sb *strings.Builder) {
+	sb.WriteString("p.CharClass(")
+	for _, r := range ranges {
+		sb.WriteString(fmt.Sprintf("p.CharRange{From: 0x%02x, To: 0x%02x},", r.From, r.To))
+	}
+	sb.WriteString(")")
+}
+
+func writeLiteral(l Literal, sb *strings.Builder) {
+	switch {
+	case l.Fold:
+		sb.WriteString(fmt.Sprintf("p.LiteralFold(%q)", l.Value))
+	case len([]rune(l.Value)) == 1:
+		sb.WriteString(fmt.Sprintf("p.Lit(%q)", []rune(l.Value)[0]))
+	default:
+		sb.WriteString(fmt.Sprintf("p.Literal(%q)", l.Value))
+	}
+}
+
 func WriteElement(element interface{}, sb *strings.Builder) {
+	writeElement(element, sb, false)
+}
+
+// writeElement writes element, matching literal strings regardless of case
+// when fold is set.
+func writeElement(element interface{}, sb *strings.Builder, fold bool) {
 	switch el := element.(type) {
 	case Elements:
-		WriteElement(el.Alternation, sb)
+		writeElement(el.Alternation, sb, fold)
 	case Alternation:
 		if len(el.Elements) == 1 {
-			WriteElement(el.Elements[0], sb)
+			writeElement(el.Elements[0], sb, fold)
+			return
+		}
+		if ranges, ok := classRanges(el, fold); ok {
+			writeCharClass(ranges, sb)
 			return
 		}
 		sb.WriteString("p.Alt(")
 		for _, v := range el.Elements {
-			WriteElement(v, sb)
+			writeElement(v, sb, fold)
 			sb.WriteString(",")
 		}
 		sb.WriteString(")")
 	case Concatenation:
 		if len(el.Elements) == 1 {
-			WriteElement(el.Elements[0], sb)
+			writeElement(el.Elements[0], sb, fold)
 			return
 		}
 		sb.WriteString("p.Cat(")
 		for _, v := range el.Elements {
-			WriteElement(v, sb)
+			writeElement(v, sb, fold)
 			sb.WriteString(",")
 		}
 		sb.WriteString(")")
 	case Repetition:
 		if el.Meta == nil {
-			WriteElement(el.Element, sb)
+			writeElement(el.Element, sb, fold)
 			return
 		}
 		if el.Meta.Min == 0 && el.Meta.Max == 0 {
@@ -75,12 +281,12 @@ func WriteElement(element interface{}, sb *strings.Builder) {
 			sb.WriteString(fmt.Sprintf("p.Repeat(%d, %d, ", el.Meta.Min, el.Meta.Max))
 		}
 
-		WriteElement(el.Element, sb)
+		writeElement(el.Element, sb, fold)
 		sb.WriteRune(')')
 	case Group:
-		WriteElement(el.Elements, sb)
+		writeElement(el.Elements, sb, fold)
 	case Element:
-		WriteElement(el.Inner, sb)
+		writeElement(el.Inner, sb, fold)
 	case RuleName:
 		if _, ok := parser.CoreConsumers[strings.ToLower(el.Name)]; ok {
 			sb.WriteString("p.")
@@ -88,13 +294,19 @@ func WriteElement(element interface{}, sb *strings.Builder) {
 			return
 		}
 		sb.WriteString("p.Ref(\"")
-		sb.WriteString(el.Name)
+		sb.WriteString(strings.ToLower(el.Name))
 		sb.WriteString("\")")
 	case Option:
 		sb.WriteString("p.Opt(")
-		WriteElement(el.Elements, sb)
+		writeElement(el.Elements, sb, fold)
 		sb.WriteString(")")
 	case CharVal:
+		if fold && hasLetters(el.Value) {
+			sb.WriteString("p.StrFold(\"")
+			sb.WriteString(escapeString(el.Value))
+			sb.WriteString("\")")
+			return
+		}
 		if len(el.Value) == 1 {
 			sb.WriteString("p.Lit('")
 			sb.WriteString(escapeLit(el.Value))
@@ -143,6 +355,55 @@ func WriteElement(element interface{}, sb *strings.Builder) {
 		default:
 			panic("Unimplemented")
 		}
+	case CharSet:
+		if len(el.Ranges) > 1 {
+			writeCharClass(el.Ranges, sb)
+		} else if r := el.Ranges[0]; r.From == r.To {
+			sb.WriteString(fmt.Sprintf("p.Lit(%q)", rune(r.From)))
+		} else {
+			sb.WriteString(fmt.Sprintf("p.HexRange(0x%02x, 0x%02x)", r.From, r.To))
+		}
+	case Literal:
+		writeLiteral(el, sb)
+	case Trie:
+		sb.WriteString("p.Trie(")
+		for _, l := range el.Literals {
+			writeLiteral(l, sb)
+			sb.WriteString(",")
+		}
+		sb.WriteString(")")
+	case Shaped:
+		sb.WriteString("p.")
+		sb.WriteString(shapeFuncs[el.Shape])
+		sb.WriteRune('(')
+		if el.Label != "" {
+			sb.WriteString(fmt.Sprintf("p.Label(%q, ", el.Label))
+		}
+		if el.Shape == parser.ShapeInline {
+			if len(el.Elements.Elements) > 1 {
+				sb.WriteString("p.Alt(")
+			}
+			for _, c := range el.Elements.Elements {
+				sb.WriteString("p.Cat(")
+				for _, v := range c.Elements {
+					writeElement(v, sb, el.Fold)
+					sb.WriteString(",")
+				}
+				sb.WriteString(")")
+				if len(el.Elements.Elements) > 1 {
+					sb.WriteString(",")
+				}
+			}
+			if len(el.Elements.Elements) > 1 {
+				sb.WriteString(")")
+			}
+		} else {
+			writeElement(el.Elements, sb, el.Fold)
+		}
+		if el.Label != "" {
+			sb.WriteRune(')')
+		}
+		sb.WriteRune(')')
 	default:
 		fmt.Printf("Unimplemented: %T\n", el)
 	}
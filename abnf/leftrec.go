@@ -0,0 +1,445 @@
+package abnf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// LeftRecursion is a group of rules that may call themselves before
+// consuming any input, which recursive descent parsers cannot handle.
+type LeftRecursion struct {
+	// Rules holds the rules involved, in definition order.
+	Rules []string
+	// Cycle holds a path of calls from the first rule back to itself, such as
+	// expr, term, expr.
+	Cycle []string
+	// Span locates the name of the first rule.
+	Span parser.Span
+}
+
+func (l LeftRecursion) String() string {
+	return "left recursion: " + strings.Join(l.Cycle, " -> ")
+}
+
+// FindLeftRecursion reports the left recursion in list, either direct or
+// through other rules, including recursion hidden behind elements that may
+// match the empty string, such as options.
+func FindLeftRecursion(list *RuleList) []LeftRecursion {
+	g := newLeftGraph(list)
+	var result []LeftRecursion
+	for _, scc := range g.recursive() {
+		rec := LeftRecursion{Span: g.spans[scc[0]]}
+		for _, name := range scc {
+			rec.Rules = append(rec.Rules, g.names[name])
+		}
+		for _, name := range g.cycle(scc) {
+			rec.Cycle = append(rec.Cycle, g.names[name])
+		}
+		result = append(result, rec)
+	}
+	return result
+}
+
+// EliminateLeftRecursion returns a copy of list in which left recursive rules
+// are rewritten into an equivalent form using repetitions, like
+//
+//	expr = expr "+" term / term
+//
+// becoming
+//
+//	expr = term *( "+" term )
+//
+// Indirect recursion is removed by substituting the rules involved into each
+// other, and recursion hidden behind optional elements by splitting them into
+// their empty and non-empty cases. Rules defined with "=/" are merged into
+// the rules they extend when those are rewritten.
+//
+// When hints is set, rewritten rules are annotated with @fold-left, hinting
+// that their results are a head followed by the tails it should be combined
+// with, as done by parser.ReducerContext.FoldLeft.
+func EliminateLeftRecursion(list *RuleList, hints bool) (*RuleList, error) {
+	g := newLeftGraph(list)
+	rewritten := map[string]bool{}
+	for _, scc := range g.recursive() {
+		for i, name := range scc {
+			targets := map[string]bool{}
+			for _, n := range scc[:i+1] {
+				targets[n] = true
+			}
+			var alternatives []Concatenation
+			for _, c := range g.bodies[name].Elements {
+				expanded, err := g.expand(c, targets, name)
+				if err != nil {
+					return nil, fmt.Errorf("rule %s: %w", g.names[name], err)
+				}
+				alternatives = append(alternatives, expanded...)
+			}
+			body, err := g.eliminateDirect(name, alternatives)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", g.names[name], err)
+			}
+			if Format(body) != Format(g.bodies[name]) {
+				g.bodies[name] = body
+				rewritten[name] = true
+			}
+		}
+	}
+
+	result := &RuleList{Comments: list.Comments}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		if !rewritten[name] {
+			result.Rules = append(result.Rules, r)
+			continue
+		}
+		if r.DefinedAs.Value == "=/" {
+			continue
+		}
+		r.DefinedAs = DefinedAs{Value: "="}
+		r.Elements = Elements{Alternation: g.bodies[name]}
+		if hints && !r.HasAnnotation(AnnotationFoldLeft) {
+			r.Comments = append(append([]Comment(nil), r.Comments...), Comment{Value: " @" + AnnotationFoldLeft})
+			r.Annotations = append(append([]Annotation(nil), r.Annotations...), Annotation{Name: AnnotationFoldLeft})
+		}
+		result.Rules = append(result.Rules, r)
+	}
+	return result, nil
+}
+
+// leftGraph holds which rules each rule may call before consuming input.
+type leftGraph struct {
+	order    []string
+	names    map[string]string
+	spans    map[string]parser.Span
+	bodies   map[string]Alternation
+	nullable map[string]bool
+}
+
+func newLeftGraph(list *RuleList) *leftGraph {
+	g := &leftGraph{
+		names:    map[string]string{},
+		spans:    map[string]parser.Span{},
+		bodies:   map[string]Alternation{},
+		nullable: map[string]bool{"lwsp": true},
+	}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		if _, core := parser.CoreConsumers[name]; core {
+			continue
+		}
+		body, ok := g.bodies[name]
+		if !ok {
+			g.order = append(g.order, name)
+			g.names[name] = r.Name.Name
+			g.spans[name] = r.Name.Span
+		}
+		body.Elements = append(body.Elements, r.Elements.Alternation.Elements...)
+		g.bodies[name] = body
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, name := range g.order {
+			if !g.nullable[name] && g.isNullable(g.bodies[name]) {
+				g.nullable[name] = true
+				changed = true
+			}
+		}
+	}
+	return g
+}
+
+func (g *leftGraph) isNullable(node interface{}) bool {
+	switch n := node.(type) {
+	case Alternation:
+		for _, c := range n.Elements {
+			if g.isNullable(c) {
+				return true
+			}
+		}
+		return false
+	case Concatenation:
+		for _, r := range n.Elements {
+			if !g.isNullable(r) {
+				return false
+			}
+		}
+		return true
+	case Repetition:
+		return (n.Meta != nil && n.Meta.Min == 0) || g.isNullable(n.Element.Inner)
+	case Group:
+		return g.isNullable(n.Elements)
+	case Option:
+		return true
+	case RuleName:
+		return g.nullable[strings.ToLower(n.Name)]
+	case CharVal:
+		return n.Value == ""
+	}
+	return false
+}
+
+// corners returns the rules node may call before consuming any input.
+func (g *leftGraph) corners(node interface{}, found map[string]bool) map[string]bool {
+	if found == nil {
+		found = map[string]bool{}
+	}
+	switch n := node.(type) {
+	case Alternation:
+		for _, c := range n.Elements {
+			g.corners(c, found)
+		}
+	case Concatenation:
+		for _, r := range n.Elements {
+			g.corners(r, found)
+			if !g.isNullable(r) {
+				break
+			}
+		}
+	case Repetition:
+		g.corners(n.Element.Inner, found)
+	case Group:
+		g.corners(n.Elements, found)
+	case Option:
+		g.corners(n.Elements, found)
+	case RuleName:
+		if name := strings.ToLower(n.Name); g.bodies[name].Elements != nil {
+			found[name] = true
+		}
+	}
+	return found
+}
+
+func intersects(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// recursive returns the strongly connected components of the graph holding
+// a cycle, with their rules in definition order, using Tarjan's algorithm.
+func (g *leftGraph) recursive() [][]string {
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var sccs [][]string
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		low[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		for next := range g.corners(g.bodies[name], nil) {
+			if _, ok := index[next]; !ok {
+				visit(next)
+				if low[next] < low[name] {
+					low[name] = low[next]
+				}
+			} else if onStack[next] && index[next] < low[name] {
+				low[name] = index[next]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		members := map[string]bool{}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			members[top] = true
+			if top == name {
+				break
+			}
+		}
+		if len(members) == 1 && !g.corners(g.bodies[name], nil)[name] {
+			return
+		}
+		var scc []string
+		for _, n := range g.order {
+			if members[n] {
+				scc = append(scc, n)
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, name := range g.order {
+		if _, ok := index[name]; !ok {
+			visit(name)
+		}
+	}
+
+	// Report components in the order of their first rule.
+	position := map[string]int{}
+	for i, name := range g.order {
+		position[name] = i
+	}
+	for i := 1; i < len(sccs); i++ {
+		for j := i; j > 0 && position[sccs[j][0]] < position[sccs[j-1][0]]; j-- {
+			sccs[j], sccs[j-1] = sccs[j-1], sccs[j]
+		}
+	}
+	return sccs
+}
+
+// cycle returns the shortest path of calls from the first rule of scc back to
+// itself.
+func (g *leftGraph) cycle(scc []string) []string {
+	members := map[string]bool{}
+	for _, n := range scc {
+		members[n] = true
+	}
+	start := scc[0]
+	from := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, next := range g.order {
+			if !members[next] || !g.corners(g.bodies[name], nil)[next] {
+				continue
+			}
+			if next == start {
+				path := []string{start}
+				for n := name; n != start; n = from[n] {
+					path = append([]string{n}, path...)
+				}
+				return append([]string{start}, path...)
+			}
+			if _, seen := from[next]; !seen {
+				from[next] = name
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// expand rewrites c into alternatives whose left recursion into targets, if
+// any, is exposed as a leading reference. References to targets other than
+// self are replaced by the alternatives of the referenced rule.
+func (g *leftGraph) expand(c Concatenation, targets map[string]bool, self string) ([]Concatenation, error) {
+	if len(c.Elements) == 0 {
+		return []Concatenation{c}, nil
+	}
+	first := c.Elements[0]
+	rest := c.Elements[1:]
+	prepend := func(elements []Repetition) Concatenation {
+		return Concatenation{Elements: append(append([]Repetition(nil), elements...), rest...)}
+	}
+
+	if !intersects(g.corners(first, nil), targets) {
+		if !g.isNullable(first) || !intersects(g.corners(Concatenation{Elements: rest}, nil), targets) {
+			return []Concatenation{c}, nil
+		}
+		// The recursion is hidden behind first: split it into its non-empty
+		// and empty cases.
+		var result []Concatenation
+		nonEmpty, ok, err := g.nonEmpty(first)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, prepend([]Repetition{nonEmpty}))
+		}
+		tail, err := g.expand(Concatenation{Elements: rest}, targets, self)
+		return append(result, tail...), err
+	}
+
+	var alternatives []Concatenation
+	switch inner := first.Element.Inner.(type) {
+	case RuleName:
+		name := strings.ToLower(inner.Name)
+		if first.Meta != nil {
+			break
+		}
+		if name == self {
+			return []Concatenation{c}, nil
+		}
+		alternatives = g.bodies[name].Elements
+	case Group:
+		if first.Meta != nil {
+			break
+		}
+		alternatives = inner.Elements.Elements
+	case Option:
+		if first.Meta != nil {
+			break
+		}
+		alternatives = append(append([]Concatenation(nil), inner.Elements.Elements...), Concatenation{})
+	}
+	if alternatives == nil {
+		return nil, fmt.Errorf("cannot eliminate left recursion through %s", Format(first))
+	}
+	var result []Concatenation
+	for _, a := range alternatives {
+		expanded, err := g.expand(prepend(a.Elements), targets, self)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded...)
+	}
+	return result, nil
+}
+
+// nonEmpty returns r restricted to non-empty matches, or false when r only
+// matches the empty string.
+func (g *leftGraph) nonEmpty(r Repetition) (Repetition, bool, error) {
+	switch inner := r.Element.Inner.(type) {
+	case CharVal:
+		if inner.Value == "" {
+			return r, false, nil
+		}
+	case Option:
+		if r.Meta == nil && !g.isNullable(inner.Elements) {
+			return single(inner.Elements.Elements), true, nil
+		}
+	}
+	if r.Meta != nil && r.Meta.Min == 0 && !g.isNullable(r.Element.Inner) {
+		meta := *r.Meta
+		meta.Min = 1
+		return Repetition{Meta: &meta, Element: r.Element}, true, nil
+	}
+	return r, false, fmt.Errorf("cannot eliminate left recursion hidden behind %s, as it may match the empty string", Format(r))
+}
+
+// eliminateDirect turns the alternatives of a rule calling itself as their
+// first element into repetitions following the other alternatives.
+func (g *leftGraph) eliminateDirect(self string, alternatives []Concatenation) (Alternation, error) {
+	var heads, tails []Concatenation
+	for _, c := range alternatives {
+		if len(c.Elements) > 0 && c.Elements[0].Meta == nil {
+			if ref, ok := c.Elements[0].Element.Inner.(RuleName); ok && strings.ToLower(ref.Name) == self {
+				if len(c.Elements) > 1 {
+					tails = append(tails, Concatenation{Elements: c.Elements[1:]})
+				}
+				continue
+			}
+		}
+		heads = append(heads, c)
+	}
+	if len(tails) == 0 {
+		return Alternation{Elements: heads}, nil
+	}
+	if len(heads) == 0 {
+		return Alternation{}, fmt.Errorf("every alternative is left recursive")
+	}
+
+	head := single(heads)
+	tail := single(tails)
+	tail.Meta = &Repeat{}
+	return Alternation{Elements: []Concatenation{{Elements: []Repetition{head, tail}}}}, nil
+}
+
+// single returns alternatives as a single element, grouping them if needed.
+func single(alternatives []Concatenation) Repetition {
+	if len(alternatives) == 1 && len(alternatives[0].Elements) == 1 && alternatives[0].Elements[0].Meta == nil {
+		return alternatives[0].Elements[0]
+	}
+	return Repetition{Element: Element{Inner: Group{Elements: Alternation{Elements: alternatives}}}}
+}
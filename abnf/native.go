@@ -0,0 +1,755 @@
+package abnf
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// GenerateNative returns the source of a Go package named pkg holding a
+// standalone recursive-descent parser for the rules in list. Every rule
+// becomes a method, terminals are checked inline and references are plain
+// calls, so that the package does not depend on goparse at runtime. Parse
+// produces trees of Node values, mirroring node by node the atoms produced by
+// the rules returned by Compile.
+func GenerateNative(pkg string, list *RuleList) (string, error) {
+	order, alternatives, metas, err := mergeRules(list)
+	if err != nil {
+		return "", err
+	}
+	if err := checkGoNames(order); err != nil {
+		return "", err
+	}
+	g := &nativeGen{defined: map[string]bool{}, core: map[string]bool{}, bodies: map[string]string{}}
+	for _, name := range order {
+		g.defined[name] = true
+	}
+	for _, name := range order {
+		if err := g.rule(name, alternatives[name], metas[name]); err != nil {
+			return "", fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("// Code generated by goparse. DO NOT EDIT.\n\npackage " + pkg + "\n\n")
+	sb.WriteString(nativeRuntime)
+	var core []string
+	for name := range g.core {
+		core = append(core, name)
+	}
+	sort.Strings(core)
+	for _, name := range core {
+		sb.WriteString(nativeCore[name].source)
+	}
+	sb.WriteString(g.funcs.String())
+	sb.WriteString("\nvar rules = map[string]func(*parseState) (*Node, bool){\n")
+	for _, name := range core {
+		if !g.defined[name] {
+			sb.WriteString(fmt.Sprintf("%q: (*parseState).core%s,\n", name, goName(name)))
+		}
+	}
+	for _, name := range order {
+		sb.WriteString(fmt.Sprintf("%q: (*parseState).rule%s,\n", name, goName(name)))
+	}
+	sb.WriteString("}\n")
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+// goName returns the exported Go identifier for a rule name, such as CWsp for
+// c-wsp.
+func goName(rule string) string {
+	sb := strings.Builder{}
+	for _, part := range strings.Split(strings.ToLower(rule), "-") {
+		if part != "" {
+			sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return sb.String()
+}
+
+// checkGoNames reports rules whose names map to the same Go name, such as
+// rule-1 and rule1, as the code generated for them would not compile.
+func checkGoNames(names []string) error {
+	seen := map[string]string{}
+	for _, name := range names {
+		id := goName(name)
+		if other, ok := seen[id]; ok {
+			return fmt.Errorf("rules %s and %s both map to the Go name %s", other, name, id)
+		}
+		seen[id] = name
+	}
+	return nil
+}
+
+type nativeGen struct {
+	funcs   strings.Builder
+	defined map[string]bool
+	// core holds the core rules referenced, whose methods are emitted along
+	// with the runtime.
+	core map[string]bool
+	// current and count name the methods generated for parts of a rule.
+	current string
+	count   int
+	bodies  map[string]string
+}
+
+// method emits a method with the given body, returning its name. Methods
+// with the same body are only emitted once.
+func (g *nativeGen) method(body string) string {
+	if name, ok := g.bodies[body]; ok {
+		return name
+	}
+	g.count++
+	name := fmt.Sprintf("rule%s_%d", goName(g.current), g.count)
+	g.funcs.WriteString(fmt.Sprintf("\nfunc (p *parseState) %s() (*Node, bool) {\n%s\n}\n", name, body))
+	g.bodies[body] = name
+	return name
+}
+
+func (g *nativeGen) rule(name string, alt Alternation, meta *ruleMeta) error {
+	g.current, g.count = name, 0
+	inner, err := g.expr(alt, meta.fold)
+	if err != nil {
+		return err
+	}
+	g.funcs.WriteString(fmt.Sprintf("\n// rule%s matches %s.\nfunc (p *parseState) rule%s() (*Node, bool) {\n%s\n}\n",
+		goName(name), name, goName(name), shapedBody(inner, meta.shape, meta.label, name)))
+	return nil
+}
+
+// shapedBody returns the body of a method calling inner, and applying shape
+// and label to its result. When rule is set, the result is wrapped in a node
+// for that rule, unless shape removes it from the tree.
+func shapedBody(inner string, shape parser.Shape, label, rule string) string {
+	sb := strings.Builder{}
+	if label != "" || shape == parser.ShapeToken || (rule != "" && shape == parser.ShapeDefault) {
+		sb.WriteString("start := p.pos\n")
+	}
+	if shape == parser.ShapeSuppress {
+		sb.WriteString(fmt.Sprintf("if _, ok := p.%s(); !ok {\n", inner))
+	} else {
+		sb.WriteString(fmt.Sprintf("n, ok := p.%s()\nif !ok {\n", inner))
+	}
+	if label != "" {
+		sb.WriteString(fmt.Sprintf("p.failAt(start, %q)\n", label))
+	}
+	sb.WriteString("return nil, false\n}\n")
+	switch shape {
+	case parser.ShapeSuppress:
+		sb.WriteString("return nil, true")
+		return sb.String()
+	case parser.ShapeInline:
+		sb.WriteString("if n != nil && n.Kind == NodeList {\nn.splice = true\n}\nreturn n, true")
+		return sb.String()
+	case parser.ShapeToken:
+		sb.WriteString("n = p.token(start)\n")
+	}
+	if rule != "" {
+		sb.WriteString(fmt.Sprintf("return p.wrap(%q, start, n), true", rule))
+	} else {
+		sb.WriteString("return n, true")
+	}
+	return sb.String()
+}
+
+// expr emits the methods matching node, returning the name of the outermost
+// one. The translation follows compileElement.
+func (g *nativeGen) expr(node interface{}, fold bool) (string, error) {
+	switch el := node.(type) {
+	case Elements:
+		return g.expr(el.Alternation, fold)
+	case Alternation:
+		if len(el.Elements) == 1 {
+			return g.expr(el.Elements[0], fold)
+		}
+		if ranges, ok := classRanges(el, fold); ok {
+			return g.class(ranges), nil
+		}
+		nodes := make([]interface{}, len(el.Elements))
+		for i, c := range el.Elements {
+			nodes[i] = c
+		}
+		return g.alt(nodes, fold)
+	case Concatenation:
+		if len(el.Elements) == 1 {
+			return g.expr(el.Elements[0], fold)
+		}
+		return g.cat(el.Elements, fold)
+	case Repetition:
+		if el.Meta == nil {
+			return g.expr(el.Element, fold)
+		}
+		return g.repeat(el, fold)
+	case Group:
+		return g.expr(el.Elements, fold)
+	case Element:
+		return g.expr(el.Inner, fold)
+	case RuleName:
+		name := strings.ToLower(el.Name)
+		if _, ok := nativeCore[name]; ok {
+			g.useCore(name)
+			return "core" + goName(name), nil
+		}
+		if g.defined[name] {
+			return "rule" + goName(name), nil
+		}
+		return g.method(fmt.Sprintf("p.fail(%q)\nreturn nil, false", "rule "+name)), nil
+	case Option:
+		inner, err := g.expr(el.Elements, fold)
+		if err != nil {
+			return "", err
+		}
+		return g.method(fmt.Sprintf("start := p.pos\nn, ok := p.%s()\nreturn p.option(start, n, ok), true", inner)), nil
+	case CharVal:
+		if fold && hasLetters(el.Value) {
+			return g.literal([]rune(el.Value), true), nil
+		}
+		if len(el.Value) == 1 {
+			return g.class([]Range{{int(el.Value[0]), int(el.Value[0])}}), nil
+		}
+		return g.literal([]rune(el.Value), false), nil
+	case HexVal:
+		return g.numeric(el.Numeric)
+	case DecVal:
+		return g.numeric(el.Numeric)
+	case BinVal:
+		return g.numeric(el.Numeric)
+	case ProseVal:
+		return "", fmt.Errorf("prose value <%s> cannot be compiled", el.Value)
+	case CharSet:
+		return g.class(el.Ranges), nil
+	case Literal:
+		return g.literalOf(el), nil
+	case Trie:
+		nodes := make([]interface{}, len(el.Literals))
+		for i, l := range el.Literals {
+			nodes[i] = l
+		}
+		return g.alt(nodes, fold)
+	case Shaped:
+		var inner string
+		var err error
+		if el.Shape == parser.ShapeInline {
+			// Alternatives are kept as lists, for their results to be
+			// spliced into the enclosing one.
+			nodes := make([]interface{}, len(el.Elements.Elements))
+			for i, c := range el.Elements.Elements {
+				if nodes[i], err = g.cat(c.Elements, el.Fold); err != nil {
+					return "", err
+				}
+			}
+			inner = nodes[0].(string)
+			if len(nodes) > 1 {
+				inner, err = g.alt(nodes, el.Fold)
+			}
+		} else {
+			inner, err = g.expr(el.Elements, el.Fold)
+		}
+		if err != nil {
+			return "", err
+		}
+		return g.method(shapedBody(inner, el.Shape, el.Label, "")), nil
+	}
+	return "", fmt.Errorf("unsupported node %T", node)
+}
+
+// alt emits an alternation of nodes, or of the methods named by strings in
+// nodes. Like AlternationConsumer, it picks the first match weighing the most,
+// so alternatives are attempted by decreasing weight.
+func (g *nativeGen) alt(nodes []interface{}, fold bool) (string, error) {
+	type alternative struct {
+		method string
+		weight int
+	}
+	alts := make([]alternative, len(nodes))
+	for i, n := range nodes {
+		if method, ok := n.(string); ok {
+			// Lists produced by cat weigh as concatenations.
+			alts[i] = alternative{method, 1}
+			continue
+		}
+		method, err := g.expr(n, fold)
+		if err != nil {
+			return "", err
+		}
+		alts[i] = alternative{method, weight(n, fold)}
+	}
+	sort.SliceStable(alts, func(i, j int) bool { return alts[i].weight > alts[j].weight })
+
+	sb := strings.Builder{}
+	for _, a := range alts {
+		sb.WriteString(fmt.Sprintf("if n, ok := p.%s(); ok {\nreturn n, true\n}\n", a.method))
+	}
+	sb.WriteString("return nil, false")
+	return g.method(sb.String()), nil
+}
+
+// cat emits a concatenation of elements, always producing a list.
+func (g *nativeGen) cat(elements []Repetition, fold bool) (string, error) {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("start := p.pos\nchildren := make([]*Node, 0, %d)\n", len(elements)))
+	if len(elements) > 0 {
+		sb.WriteString("var n *Node\nvar ok bool\n")
+	}
+	for _, el := range elements {
+		inner, err := g.expr(el, fold)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("if n, ok = p.%s(); !ok {\np.pos = start\nreturn nil, false\n}\nchildren = appendNode(children, n)\n", inner))
+	}
+	sb.WriteString("return p.list(start, children), true")
+	return g.method(sb.String()), nil
+}
+
+// repeat emits a repetition, following RepetitionConsumer: the element is
+// matched as many times as possible, and the repetition then fails unless
+// that count is accepted. Only unbounded repetitions accepting no matches stop
+// at the end of the input before attempting another match.
+func (g *nativeGen) repeat(el Repetition, fold bool) (string, error) {
+	inner, err := g.expr(el.Element, fold)
+	if err != nil {
+		return "", err
+	}
+	min, max := el.Meta.Min, el.Meta.Max
+	counted := min > 0 || max > 0
+	sb := strings.Builder{}
+	sb.WriteString("start := p.pos\nvar children []*Node\n")
+	if counted {
+		sb.WriteString("count := 0\n")
+	}
+	sb.WriteString("for {\n")
+	if !counted {
+		sb.WriteString("if p.pos >= len(p.in) {\nbreak\n}\n")
+	}
+	sb.WriteString(fmt.Sprintf("pos := p.pos\nn, ok := p.%s()\nif !ok {\nbreak\n}\n", inner))
+	if counted {
+		sb.WriteString("count++\n")
+	}
+	sb.WriteString("children = appendNode(children, n)\nif p.pos == pos {\n")
+	if min > 1 {
+		// Further matches would not move either, and are taken as done.
+		sb.WriteString(fmt.Sprintf("if count < %d {\ncount = %d\n}\n", min, min))
+	}
+	sb.WriteString("break\n}\n}\n")
+	var reject string
+	switch {
+	case max > 0 && min > 0:
+		reject = fmt.Sprintf("count < %d || count > %d", min, max)
+	case max > 0:
+		reject = fmt.Sprintf("count > %d", max)
+	case min == 1:
+		reject = "count == 0"
+	case min > 1:
+		// As in RepeatMin, the element must match exactly min times.
+		reject = fmt.Sprintf("count != %d", min)
+	}
+	if reject != "" {
+		sb.WriteString(fmt.Sprintf("if %s {\np.pos = start\nreturn nil, false\n}\n", reject))
+	}
+	sb.WriteString("return p.list(start, children), true")
+	return g.method(sb.String()), nil
+}
+
+func (g *nativeGen) numeric(n Numeric) (string, error) {
+	switch n.Mode {
+	case NumericModeSingle:
+		return g.class([]Range{{n.Single, n.Single}}), nil
+	case NumericModeRange:
+		return g.class([]Range{n.Range}), nil
+	case NumericModeSequence:
+		runes := make([]rune, len(n.Sequence))
+		for i, v := range n.Sequence {
+			runes[i] = rune(v)
+		}
+		return g.literal(runes, false), nil
+	}
+	return "", fmt.Errorf("invalid numeric mode %d", n.Mode)
+}
+
+func (g *nativeGen) literalOf(l Literal) string {
+	if runes := []rune(l.Value); len(runes) == 1 && !l.Fold {
+		return g.class([]Range{{int(runes[0]), int(runes[0])}})
+	}
+	return g.literal([]rune(l.Value), l.Fold)
+}
+
+// class emits a match of a single rune within ranges.
+func (g *nativeGen) class(ranges []Range) string {
+	conds := make([]string, len(ranges))
+	expected := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.From == r.To {
+			conds[i] = fmt.Sprintf("r == %s", runeLit(rune(r.From)))
+		} else {
+			conds[i] = fmt.Sprintf("r >= %s && r <= %s", runeLit(rune(r.From)), runeLit(rune(r.To)))
+		}
+		expected[i] = Format(CharSet{Ranges: []Range{r}})
+	}
+	return g.method(fmt.Sprintf("if p.pos < len(p.in) {\nif r := p.in[p.pos]; %s {\nreturn p.leaf(NodeChar), true\n}\n}\np.fail(%q)\nreturn nil, false",
+		strings.Join(conds, " || "), strings.Join(expected, " / ")))
+}
+
+// literal emits a match of runes as a whole, producing a list of characters.
+func (g *nativeGen) literal(runes []rune, fold bool) string {
+	if len(runes) == 0 {
+		return g.method("return p.list(p.pos, nil), true")
+	}
+	conds := []string{fmt.Sprintf("p.pos+%d <= len(p.in)", len(runes))}
+	for i, r := range runes {
+		at := fmt.Sprintf("p.in[p.pos+%d]", i)
+		lower, upper := strings.ToLower(string(r)), strings.ToUpper(string(r))
+		if fold && r <= 0x7F && lower != upper {
+			conds = append(conds, fmt.Sprintf("(%s == %s || %s == %s)", at, runeLit([]rune(lower)[0]), at, runeLit([]rune(upper)[0])))
+		} else {
+			conds = append(conds, fmt.Sprintf("%s == %s", at, runeLit(r)))
+		}
+	}
+	return g.method(fmt.Sprintf("if %s {\nstart := p.pos\nchildren := make([]*Node, %d)\nfor i := range children {\nchildren[i] = p.leaf(NodeChar)\n}\nreturn p.list(start, children), true\n}\np.fail(%q)\nreturn nil, false",
+		strings.Join(conds, " && "), len(runes), fmt.Sprintf("%q", string(runes))))
+}
+
+func runeLit(r rune) string {
+	if r >= 0x20 && r < 0x7F && r != '\'' && r != '\\' {
+		return fmt.Sprintf("'%c'", r)
+	}
+	return fmt.Sprintf("0x%02x", r)
+}
+
+func (g *nativeGen) useCore(name string) {
+	if g.core[name] {
+		return
+	}
+	g.core[name] = true
+	for _, dep := range nativeCore[name].deps {
+		g.useCore(dep)
+	}
+}
+
+// nativeCore holds the methods matching core rules, as CoreConsumers do,
+// along with the core rules each of them relies on.
+var nativeCore = map[string]struct {
+	source string
+	deps   []string
+}{
+	"alpha":  {source: nativeTerminal("Alpha", "NodeAlpha", "r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z'")},
+	"bit":    {source: nativeTerminal("Bit", "NodeBit", "r == '0' || r == '1'")},
+	"char":   {source: nativeTerminal("Char", "NodeChar", "r == 0x01 || r >= 0x7f")},
+	"cr":     {source: nativeTerminal("Cr", "NodeCR", "r == 0x0d")},
+	"lf":     {source: nativeTerminal("Lf", "NodeLF", "r == 0x0a")},
+	"ctl":    {source: nativeTerminal("Ctl", "NodeCtl", "r <= 0x1f || r == 0x7f")},
+	"digit":  {source: nativeTerminal("Digit", "NodeDigit", "r >= '0' && r <= '9'")},
+	"dquote": {source: nativeTerminal("Dquote", "NodeDQuote", "r == '\"'")},
+	"htab":   {source: nativeTerminal("Htab", "NodeHTab", "r == 0x09")},
+	"sp":     {source: nativeTerminal("Sp", "NodeSP", "r == ' '")},
+	"vchar":  {source: nativeTerminal("Vchar", "NodeVChar", "r >= 0x21 && r <= 0x7e")},
+	"octet": {source: `
+func (p *parseState) coreOctet() (*Node, bool) {
+	if p.pos < len(p.in) {
+		return p.leaf(NodeOctet), true
+	}
+	p.fail("OCTET")
+	return nil, false
+}
+`},
+	"crlf": {deps: []string{"cr", "lf"}, source: `
+func (p *parseState) coreCrlf() (*Node, bool) {
+	start := p.pos
+	cr, ok := p.coreCr()
+	if !ok {
+		return nil, false
+	}
+	lf, ok := p.coreLf()
+	if !ok {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, []*Node{cr, lf}), true
+}
+`},
+	"hexdig": {deps: []string{"digit"}, source: `
+func (p *parseState) coreHexdig() (*Node, bool) {
+	if n, ok := p.coreDigit(); ok {
+		return n, true
+	}
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= 'A' && r <= 'F' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("HEXDIG")
+	return nil, false
+}
+`},
+	"wsp": {deps: []string{"sp", "htab"}, source: `
+func (p *parseState) coreWsp() (*Node, bool) {
+	if n, ok := p.coreSp(); ok {
+		return n, true
+	}
+	return p.coreHtab()
+}
+`},
+	"lwsp": {deps: []string{"wsp", "crlf"}, source: `
+func (p *parseState) coreLwsp() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for p.pos < len(p.in) {
+		if n, ok := p.coreWsp(); ok {
+			children = append(children, n)
+			continue
+		}
+		pos := p.pos
+		crlf, ok := p.coreCrlf()
+		if !ok {
+			break
+		}
+		wsp, ok := p.coreWsp()
+		if !ok {
+			p.pos = pos
+			break
+		}
+		children = append(children, p.list(pos, []*Node{crlf, wsp}))
+	}
+	return p.list(start, children), true
+}
+`},
+}
+
+// nativeTerminal returns a method matching a single rune satisfying cond.
+// Like the core consumers, it reads a NUL past the end of the input.
+func nativeTerminal(name, kind, cond string) string {
+	return fmt.Sprintf(`
+func (p *parseState) core%s() (*Node, bool) {
+	if r := p.peek(); %s {
+		return p.leaf(%s), true
+	}
+	p.fail(%q)
+	return nil, false
+}
+`, name, cond, kind, strings.ToUpper(name))
+}
+
+const nativeRuntime = `import (
+	"fmt"
+	"strings"
+)
+
+// NodeKind identifies what a Node stands for.
+type NodeKind int
+
+const (
+	NodeRule NodeKind = iota + 1
+	NodeList
+	NodeOption
+	NodeToken
+	NodeChar
+	NodeAlpha
+	NodeBit
+	NodeCR
+	NodeLF
+	NodeCtl
+	NodeDigit
+	NodeDQuote
+	NodeHTab
+	NodeOctet
+	NodeSP
+	NodeVChar
+)
+
+// Node is a node of the trees produced by Parse. Rules, lists and options
+// hold their children, while the other nodes hold the text they matched.
+type Node struct {
+	Kind NodeKind
+	// Rule names the rule matched by a NodeRule.
+	Rule string
+	// Text is the text matched by terminals and tokens.
+	Text string
+	// Valid reports whether a NodeOption matched.
+	Valid    bool
+	Children []*Node
+	// Start and End are the offsets, in runes, of the text matched.
+	Start, End int
+	// splice is set on lists produced by inlined rules, which are spliced
+	// into the lists holding them.
+	splice bool
+}
+
+// ParseError reports why an input could not be parsed.
+type ParseError struct {
+	Message  string
+	Position int
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("%s at position %d", e.Message, e.Position) }
+
+// Parse parses input as rule, requiring the whole input to be consumed.
+func Parse(rule, input string) (*Node, error) {
+	fn, ok := rules[strings.ToLower(rule)]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule %s", rule)
+	}
+	p := &parseState{in: []rune(input)}
+	n, ok := fn(p)
+	if !ok {
+		return nil, &ParseError{Message: "Expected " + strings.Join(p.expected, " or "), Position: p.furthest}
+	}
+	if p.pos != len(p.in) {
+		return nil, &ParseError{Message: "Expected end of input", Position: p.pos}
+	}
+	return n, nil
+}
+
+// PrintTree returns the tree rooted at n, one line per node, in the format
+// used by goparse.
+func PrintTree(n *Node) string {
+	if n == nil {
+		return "\n"
+	}
+	sb := strings.Builder{}
+	printNode(&sb, n, 0)
+	return sb.String()
+}
+
+func printNode(sb *strings.Builder, n *Node, depth int) {
+	label := n.label()
+	if n.Kind != NodeRule && len(n.Children) > 0 {
+		label += " "
+	}
+	sb.WriteString(strings.Repeat(" ", 2*depth) + "- " + label + "\n")
+	for _, c := range n.Children {
+		printNode(sb, c, depth+1)
+	}
+	if len(n.Children) > 0 {
+		sb.WriteString("\n")
+	}
+}
+
+func (n *Node) label() string {
+	switch n.Kind {
+	case NodeRule:
+		return "Rule " + n.Rule + ":"
+	case NodeList:
+		if len(n.Children) == 0 {
+			return "Empty List"
+		}
+		return "List:"
+	case NodeOption:
+		if !n.Valid {
+			return "Empty Opt"
+		}
+		return "Opt:"
+	case NodeToken:
+		return fmt.Sprintf("Token: %q", n.Text)
+	case NodeChar:
+		return "C: " + n.Text
+	case NodeAlpha:
+		return "A: " + n.Text
+	case NodeBit:
+		return "B: " + n.Text
+	case NodeCR:
+		return "CR"
+	case NodeLF:
+		return "LF"
+	case NodeCtl:
+		return fmt.Sprintf("T: 0x%02x", n.Text[0])
+	case NodeDigit:
+		return "D: " + n.Text
+	case NodeDQuote:
+		return "DQuote"
+	case NodeHTab:
+		return "HTab"
+	case NodeOctet:
+		return fmt.Sprintf("O: 0x%02x", []rune(n.Text)[0])
+	case NodeSP:
+		return "SP"
+	case NodeVChar:
+		return fmt.Sprintf("VChar: %q", n.Text)
+	}
+	return fmt.Sprintf("Node(%d)", n.Kind)
+}
+
+// parseState holds the state of a parse. The methods generated for rules are
+// named after them with a rule or core prefix, which the methods below must
+// not start with.
+type parseState struct {
+	in  []rune
+	pos int
+	// furthest is the furthest position where a match failed, and expected
+	// what was expected there.
+	furthest int
+	expected []string
+}
+
+func (p *parseState) peek() rune {
+	if p.pos >= len(p.in) {
+		return 0x00
+	}
+	return p.in[p.pos]
+}
+
+func (p *parseState) fail(expected string) { p.failAt(p.pos, expected) }
+
+func (p *parseState) failAt(pos int, expected string) {
+	if pos > p.furthest {
+		p.furthest, p.expected = pos, nil
+	}
+	if pos == p.furthest {
+		for _, e := range p.expected {
+			if e == expected {
+				return
+			}
+		}
+		p.expected = append(p.expected, expected)
+	}
+}
+
+func (p *parseState) leaf(kind NodeKind) *Node {
+	n := &Node{Kind: kind, Text: string(p.peek()), Start: p.pos, End: p.pos + 1}
+	p.pos++
+	return n
+}
+
+func (p *parseState) list(start int, children []*Node) *Node {
+	return &Node{Kind: NodeList, Children: children, Start: start, End: p.pos}
+}
+
+func (p *parseState) option(start int, n *Node, ok bool) *Node {
+	opt := &Node{Kind: NodeOption, Valid: ok, Start: start, End: p.pos}
+	if ok && n != nil {
+		opt.Children = []*Node{n}
+	}
+	return opt
+}
+
+func (p *parseState) token(start int) *Node {
+	return &Node{Kind: NodeToken, Text: string(p.in[start:p.pos]), Start: start, End: p.pos}
+}
+
+func (p *parseState) wrap(rule string, start int, n *Node) *Node {
+	r := &Node{Kind: NodeRule, Rule: rule, Start: start, End: p.pos}
+	if n != nil {
+		r.Children = []*Node{n}
+	}
+	return r
+}
+
+// appendNode appends n to list, skipping suppressed results and splicing
+// inlined ones.
+func appendNode(list []*Node, n *Node) []*Node {
+	switch {
+	case n == nil:
+		return list
+	case n.Kind == NodeList && n.splice:
+		return append(list, n.Children...)
+	}
+	return append(list, n)
+}
+`
@@ -0,0 +1,514 @@
+package abnf
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/heyvito/goparse/parser"
+)
+
+// CharSet matches a single code point within any of its ranges. Like Literal,
+// Trie and Shaped, it is never produced by the ABNF grammar, only by Optimize.
+type CharSet struct {
+	Ranges []Range
+}
+
+func (CharSet) Kind() NodeKind { return NodeKindCharSet }
+
+// Literal matches Value as a whole, regardless of the case of its ASCII
+// letters when Fold is set. It produces the same tree as the char-val it
+// replaces.
+type Literal struct {
+	Value string
+	Fold  bool
+}
+
+func (Literal) Kind() NodeKind { return NodeKindLiteral }
+
+// Trie matches one of its literals, like an alternation of them would.
+type Trie struct {
+	Literals []Literal
+}
+
+func (Trie) Kind() NodeKind { return NodeKindTrie }
+
+// Shaped applies a shape, and a label when not empty, to its elements, which
+// match literal strings regardless of case when Fold is set. The alternatives
+// of inlined elements are each matched as a concatenation, even when made of
+// a single element, so that their results are spliced into the enclosing
+// concatenation.
+type Shaped struct {
+	Shape    parser.Shape
+	Label    string
+	Fold     bool
+	Elements Alternation
+}
+
+func (Shaped) Kind() NodeKind { return NodeKindShaped }
+
+// Pass rewrites a grammar for it to be matched faster, without changing the
+// tree produced by any of its rules, except for the ones it inlines.
+type Pass func(list *RuleList) *RuleList
+
+// Passes holds every optimisation pass, in the order Optimize applies them by
+// default.
+var Passes = []Pass{InlineSuppressed, HoistPrefixes, MergeCharSets, BuildTries, FuseLiterals}
+
+// Optimize applies passes to a copy of list, or every pass in Passes when
+// none is given. The result is meant to be compiled or generated; other
+// tools, such as analysis, may not understand the nodes it introduces.
+func Optimize(list *RuleList, passes ...Pass) *RuleList {
+	if len(passes) == 0 {
+		passes = Passes
+	}
+	for _, pass := range passes {
+		list = pass(list)
+	}
+	return list
+}
+
+const (
+	// inlineLimit is the amount of nodes a suppressed rule may have to be
+	// inlined by InlineSuppressed.
+	inlineLimit = 16
+	// trieMinimum is the amount of literals an alternation must have to be
+	// turned into a Trie by BuildTries.
+	trieMinimum = 4
+)
+
+// InlineSuppressed replaces references to small, non-recursive rules
+// annotated with @suppress by their elements.
+func InlineSuppressed(list *RuleList) *RuleList {
+	metas := map[string]*ruleMeta{}
+	invalid := map[string]bool{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		if metas[name] == nil {
+			metas[name] = &ruleMeta{}
+		}
+		if err := metas[name].merge(r); err != nil {
+			invalid[name] = true
+		}
+	}
+	g := newLeftGraph(list)
+	inlinable := map[string]bool{}
+	for name, meta := range metas {
+		if meta.shape != parser.ShapeSuppress || invalid[name] || g.bodies[name].Elements == nil {
+			continue
+		}
+		size := 0
+		Inspect(g.bodies[name], func(interface{}) bool {
+			size++
+			return true
+		})
+		if size <= inlineLimit && !g.reaches(name, name) {
+			inlinable[name] = true
+		}
+	}
+
+	return rewriteRules(list, func(node interface{}, ctx rewriteContext) interface{} {
+		ref, ok := node.(RuleName)
+		name := strings.ToLower(ref.Name)
+		if !ok || !inlinable[name] {
+			return node
+		}
+		body := g.bodies[name]
+		meta := metas[name]
+		if ctx.weighted && weight(body, meta.fold) != 0 {
+			return node
+		}
+		return Shaped{Shape: parser.ShapeSuppress, Label: meta.label, Fold: meta.fold, Elements: body}
+	})
+}
+
+// reaches reports whether rule from references rule to, directly or not.
+func (g *leftGraph) reaches(from, to string) bool {
+	seen := map[string]bool{}
+	queue := []string{from}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		found := false
+		Inspect(g.bodies[name], func(n interface{}) bool {
+			if ref, ok := n.(RuleName); ok {
+				next := strings.ToLower(ref.Name)
+				found = found || next == to
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+			return true
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// HoistPrefixes factors the leading elements shared by consecutive
+// alternatives out of them, so that they are only matched once.
+func HoistPrefixes(list *RuleList) *RuleList {
+	return rewriteRules(list, func(node interface{}, ctx rewriteContext) interface{} {
+		if alt, ok := node.(Alternation); ok {
+			return hoist(alt, ctx.fold)
+		}
+		return node
+	})
+}
+
+func hoist(alt Alternation, fold bool) Alternation {
+	var result []Concatenation
+	for i := 0; i < len(alt.Elements); {
+		// Alternatives of a single element produce a single atom instead of a
+		// list, and cannot be merged with others.
+		j := i + 1
+		prefix := 0
+		if len(alt.Elements[i].Elements) > 1 {
+			first := Format(alt.Elements[i].Elements[0])
+			for j < len(alt.Elements) && len(alt.Elements[j].Elements) > 1 && Format(alt.Elements[j].Elements[0]) == first {
+				j++
+			}
+			prefix = commonPrefix(alt.Elements[i:j])
+		}
+		if j-i < 2 {
+			result = append(result, alt.Elements[i])
+			i++
+			continue
+		}
+
+		var rests []Concatenation
+		for _, c := range alt.Elements[i:j] {
+			rests = append(rests, Concatenation{Elements: c.Elements[prefix:]})
+		}
+		elements := append([]Repetition(nil), alt.Elements[i].Elements[:prefix]...)
+		elements = append(elements, Repetition{Element: Element{Inner: Shaped{
+			Shape:    parser.ShapeInline,
+			Fold:     fold,
+			Elements: hoist(Alternation{Elements: rests}, fold),
+		}}})
+		result = append(result, Concatenation{Elements: elements})
+		i = j
+	}
+	return Alternation{Elements: result}
+}
+
+func commonPrefix(alternatives []Concatenation) int {
+	n := len(alternatives[0].Elements)
+	for _, c := range alternatives[1:] {
+		i := 0
+		for i < n && i < len(c.Elements) && Format(c.Elements[i]) == Format(alternatives[0].Elements[i]) {
+			i++
+		}
+		n = i
+	}
+	return n
+}
+
+// MergeCharSets replaces alternations of single characters and ranges with
+// a CharSet.
+func MergeCharSets(list *RuleList) *RuleList {
+	return rewriteRules(list, func(node interface{}, ctx rewriteContext) interface{} {
+		alt, ok := node.(Alternation)
+		if !ok || len(alt.Elements) < 2 {
+			return node
+		}
+		ranges, ok := classRanges(alt, ctx.fold)
+		if !ok {
+			return node
+		}
+		set := CharSet{Ranges: mergeRanges(ranges)}
+		if ctx.weighted && weight(set, ctx.fold) != 1 {
+			return node
+		}
+		return alternationOf(set)
+	})
+}
+
+// classRanges returns the ranges matched by alt when all of its alternatives
+// match a single character, in which case it compiles to a CharClass.
+func classRanges(alt Alternation, fold bool) ([]Range, bool) {
+	var ranges []Range
+	for _, c := range alt.Elements {
+		inner, ok := soleNode(c)
+		if !ok {
+			return nil, false
+		}
+		r, ok := charRanges(inner, fold)
+		if !ok {
+			return nil, false
+		}
+		ranges = append(ranges, r...)
+	}
+	return ranges, true
+}
+
+// charRanges returns the code points matched by node, when it matches a
+// single one producing a Char.
+func charRanges(node Node, fold bool) ([]Range, bool) {
+	switch n := node.(type) {
+	case CharVal:
+		runes := []rune(n.Value)
+		if len(runes) != 1 || (fold && hasLetters(n.Value)) {
+			return nil, false
+		}
+		return []Range{{int(runes[0]), int(runes[0])}}, true
+	case HexVal:
+		return numericRanges(n.Numeric)
+	case DecVal:
+		return numericRanges(n.Numeric)
+	case BinVal:
+		return numericRanges(n.Numeric)
+	case CharSet:
+		return n.Ranges, true
+	}
+	return nil, false
+}
+
+func numericRanges(n Numeric) ([]Range, bool) {
+	switch n.Mode {
+	case NumericModeSingle:
+		return []Range{{n.Single, n.Single}}, true
+	case NumericModeRange:
+		return []Range{n.Range}, true
+	}
+	return nil, false
+}
+
+func mergeRanges(ranges []Range) []Range {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+	var result []Range
+	for _, r := range ranges {
+		if n := len(result); n > 0 && r.From <= result[n-1].To+1 {
+			if r.To > result[n-1].To {
+				result[n-1].To = r.To
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// BuildTries replaces large alternations of literal strings with a Trie.
+func BuildTries(list *RuleList) *RuleList {
+	return rewriteRules(list, func(node interface{}, ctx rewriteContext) interface{} {
+		alt, ok := node.(Alternation)
+		if !ok || len(alt.Elements) < trieMinimum {
+			return node
+		}
+		trie := Trie{}
+		for _, c := range alt.Elements {
+			inner, ok := soleNode(c)
+			if !ok {
+				return node
+			}
+			lit, ok := literalOf(inner, ctx.fold)
+			if !ok || lit.Value == "" {
+				return node
+			}
+			trie.Literals = append(trie.Literals, lit)
+		}
+		return alternationOf(trie)
+	})
+}
+
+// literalOf returns node as a Literal, when it matches a fixed string.
+func literalOf(node Node, fold bool) (Literal, bool) {
+	var n Numeric
+	switch v := node.(type) {
+	case Literal:
+		return v, true
+	case CharVal:
+		return Literal{Value: v.Value, Fold: fold && hasLetters(v.Value)}, true
+	case HexVal:
+		n = v.Numeric
+	case DecVal:
+		n = v.Numeric
+	case BinVal:
+		n = v.Numeric
+	default:
+		return Literal{}, false
+	}
+	switch n.Mode {
+	case NumericModeSingle:
+		return Literal{Value: string(rune(n.Single))}, true
+	case NumericModeSequence:
+		runes := make([]rune, len(n.Sequence))
+		for i, v := range n.Sequence {
+			runes[i] = rune(v)
+		}
+		return Literal{Value: string(runes)}, true
+	}
+	return Literal{}, false
+}
+
+// FuseLiterals replaces char-vals and numeric sequences of more than one
+// character with a Literal, matched at once instead of character by
+// character.
+func FuseLiterals(list *RuleList) *RuleList {
+	return rewriteRules(list, func(node interface{}, ctx rewriteContext) interface{} {
+		switch n := node.(type) {
+		case CharVal:
+			if len([]rune(n.Value)) < 2 && !(ctx.fold && hasLetters(n.Value)) {
+				return node
+			}
+		case HexVal, DecVal, BinVal:
+		default:
+			return node
+		}
+		lit, ok := literalOf(node.(Node), ctx.fold)
+		if !ok || len([]rune(lit.Value)) < 2 && !lit.Fold {
+			return node
+		}
+		return lit
+	})
+}
+
+// soleNode returns the node of a concatenation made of a single element
+// without repetition.
+func soleNode(c Concatenation) (Node, bool) {
+	if len(c.Elements) != 1 || c.Elements[0].Meta != nil {
+		return nil, false
+	}
+	return c.Elements[0].Element.Inner, true
+}
+
+func alternationOf(node Node) Alternation {
+	return Alternation{Elements: []Concatenation{{Elements: []Repetition{{Element: Element{Inner: node}}}}}}
+}
+
+// weight returns the weight of the consumer node compiles to, which
+// alternations use to pick among the alternatives matching.
+func weight(node interface{}, fold bool) int {
+	switch n := node.(type) {
+	case Elements:
+		return weight(n.Alternation, fold)
+	case Alternation:
+		if len(n.Elements) == 1 {
+			return weight(n.Elements[0], fold)
+		}
+		return 1
+	case Concatenation:
+		if len(n.Elements) == 1 {
+			return weight(n.Elements[0], fold)
+		}
+		return 1
+	case Repetition:
+		if n.Meta != nil {
+			return 0
+		}
+		return weight(n.Element.Inner, fold)
+	case Group:
+		return weight(n.Elements, fold)
+	case RuleName:
+		if con, ok := parser.CoreConsumers[strings.ToLower(n.Name)]; ok {
+			return con.Weight()
+		}
+		return 0
+	case CharVal:
+		if len([]rune(n.Value)) == 1 && !(fold && hasLetters(n.Value)) {
+			return 0
+		}
+		return 1
+	case HexVal:
+		return weightNumeric(n.Numeric)
+	case DecVal:
+		return weightNumeric(n.Numeric)
+	case BinVal:
+		return weightNumeric(n.Numeric)
+	case CharSet:
+		if len(n.Ranges) > 1 {
+			return 1
+		}
+	case Literal:
+		if len([]rune(n.Value)) > 1 || n.Fold {
+			return 1
+		}
+	case Trie:
+		return 1
+	case Shaped:
+		if n.Shape == parser.ShapeInline {
+			return 1
+		}
+		return weight(n.Elements, n.Fold)
+	}
+	return 0
+}
+
+func weightNumeric(n Numeric) int {
+	if n.Mode == NumericModeSequence {
+		return 1
+	}
+	return 0
+}
+
+type rewriteContext struct {
+	fold bool
+	// weighted is set when the weight of the node matters, as it is picked
+	// among other alternatives by their weight.
+	weighted bool
+}
+
+// rewriteRules returns a copy of list in which the elements of every rule
+// went through fn, from the innermost ones out. fn is given Alternation,
+// Concatenation and Node values, and must return a value of the same kind.
+func rewriteRules(list *RuleList, fn func(node interface{}, ctx rewriteContext) interface{}) *RuleList {
+	folds := map[string]bool{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		folds[name] = folds[name] || r.HasAnnotation(AnnotationCaseInsensitive)
+	}
+	result := &RuleList{Comments: list.Comments, Rules: make([]Rule, len(list.Rules))}
+	for i, r := range list.Rules {
+		ctx := rewriteContext{fold: folds[strings.ToLower(r.Name.Name)]}
+		r.Elements.Alternation = rewrite(r.Elements.Alternation, ctx, fn).(Alternation)
+		result.Rules[i] = r
+	}
+	return result
+}
+
+func rewrite(node interface{}, ctx rewriteContext, fn func(node interface{}, ctx rewriteContext) interface{}) interface{} {
+	switch n := node.(type) {
+	case Alternation:
+		inner := ctx
+		inner.weighted = ctx.weighted || len(n.Elements) > 1
+		elements := make([]Concatenation, len(n.Elements))
+		for i, c := range n.Elements {
+			elements[i] = rewrite(c, inner, fn).(Concatenation)
+		}
+		n.Elements = elements
+		return fn(n, ctx)
+	case Concatenation:
+		inner := ctx
+		inner.weighted = ctx.weighted && len(n.Elements) == 1
+		elements := make([]Repetition, len(n.Elements))
+		for i, r := range n.Elements {
+			inner := inner
+			inner.weighted = inner.weighted && r.Meta == nil
+			r.Element.Inner = rewrite(r.Element.Inner, inner, fn).(Node)
+			elements[i] = r
+		}
+		n.Elements = elements
+		return fn(n, ctx)
+	case Group:
+		n.Elements = rewrite(n.Elements, ctx, fn).(Alternation)
+		if len(n.Elements.Elements) == 1 {
+			// A group holding a single node, as left by other rewrites,
+			// compiles to that node.
+			if inner, ok := soleNode(n.Elements.Elements[0]); ok {
+				return fn(inner, ctx)
+			}
+		}
+		return fn(n, ctx)
+	case Option:
+		n.Elements = rewrite(n.Elements, rewriteContext{fold: ctx.fold}, fn).(Alternation)
+		return fn(n, ctx)
+	case Shaped:
+		inner := rewriteContext{fold: n.Fold, weighted: ctx.weighted && n.Shape != parser.ShapeInline}
+		n.Elements = rewrite(n.Elements, inner, fn).(Alternation)
+		return fn(n, ctx)
+	}
+	return fn(node, ctx)
+}
@@ -1,38 +1,92 @@
 package abnf
 
-import p "github.com/heyvito/goparse/parser"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 
-var Reducer = map[string]p.Reducer{
-	"rulelist": func(ctx *p.ReducerContext) interface{} {
-		var result []Rule
+	p "github.com/heyvito/goparse/parser"
+)
+
+// Reducer reduces ABNF parse trees into a *RuleList, panicking on malformed
+// trees. Use ReducerE with p.ReduceIntoE to get errors instead.
+var Reducer = p.AsReducers(ReducerE)
+
+// ReducerE reduces ABNF parse trees into a *RuleList, reporting malformed
+// trees through errors.
+var ReducerE = map[string]p.ReducerE{
+	"rulelist": func(ctx *p.ReducerContext) (interface{}, error) {
+		list := &RuleList{}
+		// Comments on the lines right above a rule are attached to it
+		var comments []Comment
 		for _, i := range ctx.AtomList() {
 			// Here we may receive a single "RefResult", representing a
 			// "rule", or an AtomList, representing comments and linebreaks
 			if r, ok := i.(p.RefResult); ok {
-				result = append(result, ctx.Reduce(r).(Rule))
+				var rule Rule
+				if err := reduceAs(ctx, r, &rule); err != nil {
+					return nil, err
+				}
+				list.Comments = append(list.Comments, rule.Comments...)
+				rule.Comments = append(comments, rule.Comments...)
+				if err := annotate(&rule, rule.Comments); err != nil {
+					return nil, err
+				}
+				list.Rules = append(list.Rules, rule)
+				comments = nil
+				continue
 			}
+			found, err := reduceComments(ctx, i)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				comments = nil
+			}
+			comments = append(comments, found...)
+			list.Comments = append(list.Comments, found...)
 		}
-		return &RuleList{Rules: result}
+		return list, nil
 	},
-	"rule": func(ctx *p.ReducerContext) interface{} {
-		return Rule{
-			Name:      ctx.Reduce(ctx.FindWithin("rulename")).(RuleName),
-			DefinedAs: ctx.Reduce(ctx.FindWithin("defined-as")).(DefinedAs),
-			Elements:  ctx.Reduce(ctx.FindWithin("elements")).(Elements),
+	"rule": func(ctx *p.ReducerContext) (interface{}, error) {
+		rule := Rule{Span: ctx.Span()}
+		if err := reduceAs(ctx, ctx.FindWithin("rulename"), &rule.Name); err != nil {
+			return nil, err
+		}
+		if err := reduceAs(ctx, ctx.FindWithin("defined-as"), &rule.DefinedAs); err != nil {
+			return nil, err
 		}
+		if err := reduceAs(ctx, ctx.FindWithin("elements"), &rule.Elements); err != nil {
+			return nil, err
+		}
+		comments, err := reduceComments(ctx, ctx.Atom())
+		if err != nil {
+			return nil, err
+		}
+		rule.Comments = comments
+		return rule, nil
 	},
-	"rulename": func(ctx *p.ReducerContext) interface{} {
-		_, v := ctx.ListAsString()
-		return RuleName{Name: v}
+	"rulename": func(ctx *p.ReducerContext) (interface{}, error) {
+		return RuleName{Name: ctx.Text(), Span: ctx.Span()}, nil
 	},
-	"defined-as": func(ctx *p.ReducerContext) interface{} {
-		v := ctx.ListAsList().Nth(1)
-		return DefinedAs{Value: v.Value().(string)}
+	"defined-as": func(ctx *p.ReducerContext) (interface{}, error) {
+		list := ctx.ListAsList()
+		if list == nil || list.Len() != 3 {
+			return nil, fmt.Errorf("invalid defined-as format")
+		}
+		return DefinedAs{Value: p.Text(list.Nth(1))}, nil
 	},
-	"elements": func(ctx *p.ReducerContext) interface{} {
-		return Elements{Alternation: ctx.Reduce(ctx.Value.(p.AtomList).First()).(Alternation)}
+	"elements": func(ctx *p.ReducerContext) (interface{}, error) {
+		list := ctx.ListAsList()
+		if list == nil || list.Len() == 0 {
+			return nil, fmt.Errorf("invalid elements format")
+		}
+		var elements Elements
+		err := reduceAs(ctx, list.First(), &elements.Alternation)
+		return elements, err
 	},
-	"alternation": func(ctx *p.ReducerContext) interface{} {
+	"alternation": func(ctx *p.ReducerContext) (interface{}, error) {
 		var opts []interface{}
 		for _, v := range ctx.AtomList() {
 			if red := ctx.Reduce(v); !ctx.IsNil(red) {
@@ -48,137 +102,230 @@ var Reducer = map[string]p.Reducer{
 			}
 		}
 
-		return Alternation{Elements: cats}
+		return Alternation{Elements: cats}, nil
 	},
-	"concatenation": func(ctx *p.ReducerContext) interface{} {
+	"concatenation": func(ctx *p.ReducerContext) (interface{}, error) {
 		// Concatenation contains a single repetition, followed by a
 		// list of repetitions. Here we may attempt to fix that.
 		var opts []Repetition
+		var err error
 		for _, v := range ctx.AtomList() {
 			ctx.Iterate(ctx.Flatten(ctx.Reduce(v)), func(i interface{}) {
-				opts = append(opts, i.(Repetition))
+				r, ok := i.(Repetition)
+				if !ok && err == nil {
+					err = fmt.Errorf("cannot use %T as a repetition", i)
+				}
+				opts = append(opts, r)
 			})
 		}
 
-		return Concatenation{Elements: opts}
+		return Concatenation{Elements: opts}, err
 	},
-	"repeat": func(ctx *p.ReducerContext) interface{} {
-		list := ctx.ListAsList()
+	"repeat": func(ctx *p.ReducerContext) (interface{}, error) {
+		// Either a single number, or two optional numbers surrounding
+		// a `*`.
+		text := ctx.Text()
+		minText, maxText := text, text
+		if i := strings.Index(text, "*"); i >= 0 {
+			minText, maxText = text[:i], text[i+1:]
+		}
+
 		var min, max int
-		switch list.Len() {
-		case 1:
-			_, v := ctx.ListAsList().ReduceAsInt()
-			min = v
-			max = v
-		case 3:
-			_, min = list.Nth(0).(p.AtomList).ReduceAsInt()
-			_, max = list.Nth(2).(p.AtomList).ReduceAsInt()
+		var err error
+		if minText != "" {
+			if min, err = strconv.Atoi(minText); err != nil {
+				return nil, fmt.Errorf("invalid repetition minimum: %w", err)
+			}
+		}
+		if maxText != "" {
+			if max, err = strconv.Atoi(maxText); err != nil {
+				return nil, fmt.Errorf("invalid repetition maximum: %w", err)
+			}
 		}
 
 		return Repeat{
 			Min: min,
 			Max: max,
-		}
+		}, nil
 	},
-	"repetition": func(ctx *p.ReducerContext) interface{} {
+	"repetition": func(ctx *p.ReducerContext) (interface{}, error) {
 		list := ctx.ListAsList()
-		if list.Len() != 2 {
-			panic("BUG: Invalid repetition format")
+		if list == nil || list.Len() != 2 {
+			return nil, fmt.Errorf("invalid repetition format")
 		}
 
-		v := ctx.Reduce(list.Nth(1)).(Element)
-		rp := list.First().(p.OptionVal)
+		var v Element
+		if err := reduceAs(ctx, list.Nth(1), &v); err != nil {
+			return nil, err
+		}
+		rp, ok := list.First().(p.OptionVal)
+		if !ok {
+			return nil, fmt.Errorf("invalid repetition format")
+		}
 		if !rp.Valid {
 			return Repetition{
 				Meta:    nil,
 				Element: v,
-			}
+			}, nil
+		}
+		var meta Repeat
+		if err := reduceAs(ctx, rp.Children()[0], &meta); err != nil {
+			return nil, err
 		}
-		meta := ctx.Reduce(rp.Value().(p.Atom)).(Repeat)
 		return Repetition{
 			Meta:    &meta,
 			Element: v,
+		}, nil
+	},
+	"element": func(ctx *p.ReducerContext) (interface{}, error) {
+		a, ok := ctx.Value.(p.Atom)
+		if !ok {
+			return nil, fmt.Errorf("invalid element format")
+		}
+		reduced, err := ctx.ReduceE(a)
+		if err != nil {
+			return nil, err
 		}
+		node, ok := reduced.(Node)
+		if !ok {
+			return nil, fmt.Errorf("cannot use %T as an element", reduced)
+		}
+		return Element{Inner: node}, nil
 	},
-	"element": func(ctx *p.ReducerContext) interface{} {
-		reduced := ctx.Reduce(ctx.Value.(p.Atom))
-		return Element{Inner: reduced.(Node)}
+	"group": func(ctx *p.ReducerContext) (interface{}, error) {
+		list := ctx.ListAsList()
+		if list == nil || list.Len() != 5 {
+			return nil, fmt.Errorf("invalid group format")
+		}
+		var group Group
+		err := reduceAs(ctx, list.Nth(2), &group.Elements)
+		return group, err
 	},
-	"group": func(ctx *p.ReducerContext) interface{} {
+	"option": func(ctx *p.ReducerContext) (interface{}, error) {
 		list := ctx.ListAsList()
-		if list.Len() != 5 {
-			panic("BUG: Invalid group format")
+		if list == nil || list.Len() != 5 {
+			return nil, fmt.Errorf("invalid option format")
 		}
-		return Group{Elements: ctx.Reduce(list.Nth(2)).(Alternation)}
+		var option Option
+		err := reduceAs(ctx, list.Nth(2), &option.Elements)
+		return option, err
 	},
-	"option": func(ctx *p.ReducerContext) interface{} {
+	"char-val": func(ctx *p.ReducerContext) (interface{}, error) {
 		list := ctx.ListAsList()
-		if list.Len() != 5 {
-			panic("BUG: Invalid option format")
+		if list == nil || list.Len() != 3 {
+			return nil, fmt.Errorf("invalid char-val format")
 		}
-		return Option{
-			Elements: ctx.Reduce(list.Nth(2)).(Alternation),
+		return CharVal{Value: p.Text(list.Nth(1))}, nil
+	},
+	"num-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		list := ctx.ListAsList()
+		if list == nil || list.Len() != 2 {
+			return nil, fmt.Errorf("invalid num-val format")
 		}
+		return ctx.ReduceE(list.Nth(1))
 	},
-	"char-val": func(ctx *p.ReducerContext) interface{} {
-		return CharVal{Value: ctx.ListAsList().Nth(1).(p.AtomList).ReduceAsString()}
+	"prose-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		text := ctx.Text()
+		return ProseVal{Value: text[1 : len(text)-1]}, nil
 	},
-	"num-val": func(ctx *p.ReducerContext) interface{} {
-		return ctx.Reduce(ctx.ListAsList().Nth(1))
+	"hex-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		n, err := reduceNumeric(ctx, p.AtomList.ReduceAsHexE)
+		return HexVal{n}, err
 	},
-	"prose-val": p.ReduceAsString,
-	"hex-val": func(ctx *p.ReducerContext) interface{} {
-		opt := ctx.ListAsList().Nth(2).(p.OptionVal)
-		_, single := ctx.ListAsList().Nth(1).(p.AtomList).ReduceAsHex()
+	"dec-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		n, err := reduceNumeric(ctx, p.AtomList.ReduceAsIntE)
+		return DecVal{n}, err
+	},
+	"comment": func(ctx *p.ReducerContext) (interface{}, error) {
+		text := ctx.Text()
+		return Comment{Value: strings.TrimSuffix(text[1:], "\r\n"), Span: ctx.Span()}, nil
+	},
+	"c-wsp": func(ctx *p.ReducerContext) (interface{}, error) {
+		return nil, nil
+	},
+	"c-nl": func(ctx *p.ReducerContext) (interface{}, error) {
+		return nil, nil
+	},
+}
 
-		result := Numeric{
-			Mode:   NumericModeSingle,
-			Single: single,
-		}
-		if opt.Valid {
-			optVal := opt.Value().(p.AtomList)
-			if optVal.Nth(0).(p.Char).Value() == "-" {
-				result.Mode = NumericModeRange
-				_, to := optVal.Nth(1).(p.AtomList).ReduceAsHex()
-				result.Range = Range{
-					From: single,
-					To:   to,
-				}
-			} else {
-				result.Mode = NumericModeSequence
-			}
+// reduceNumeric handles dec-val and hex-val, which share the same shape: a
+// prefix, a first value and an optional range or sequence. Values are read
+// through digits.
+func reduceNumeric(ctx *p.ReducerContext, digits func(p.AtomList) (bool, int, error)) (Numeric, error) {
+	invalid := fmt.Errorf("invalid numeric value format")
+	value := func(a p.Atom) (int, error) {
+		list, ok := a.(p.AtomList)
+		if !ok {
+			return 0, invalid
 		}
+		_, v, err := digits(list)
+		return v, err
+	}
 
-		return HexVal{result}
-	},
-	"dec-val": func(ctx *p.ReducerContext) interface{} {
-		opt := ctx.ListAsList().Nth(2).(p.OptionVal)
-		_, single := ctx.ListAsList().Nth(1).(p.AtomList).ReduceAsInt()
+	list := ctx.ListAsList()
+	if list == nil || list.Len() != 3 {
+		return Numeric{}, invalid
+	}
+	single, err := value(list.Nth(1))
+	if err != nil {
+		return Numeric{}, err
+	}
+	result := Numeric{
+		Mode:   NumericModeSingle,
+		Single: single,
+	}
+	opt, ok := list.Nth(2).(p.OptionVal)
+	if !ok {
+		return Numeric{}, invalid
+	}
+	if !opt.Valid {
+		return result, nil
+	}
 
-		result := Numeric{
-			Mode:   NumericModeSingle,
-			Single: single,
-		}
-		if opt.Valid {
-			optVal := opt.Value().(p.AtomList)
-			if optVal.Nth(0).(p.Char).Value() == "-" {
-				result.Mode = NumericModeRange
-				_, to := optVal.Nth(1).(p.AtomList).ReduceAsInt()
-				result.Range = Range{
-					From: single,
-					To:   to,
-				}
-			} else {
-				result.Mode = NumericModeSequence
-			}
+	optVal, ok := opt.Value().(p.AtomList)
+	if !ok || optVal.Len() == 0 {
+		return Numeric{}, invalid
+	}
+	if c, ok := optVal.Nth(0).(p.Char); ok && c.Value() == "-" {
+		to, err := value(optVal.Nth(1))
+		if err != nil {
+			return Numeric{}, err
+		}
+		result.Mode = NumericModeRange
+		result.Range = Range{
+			From: single,
+			To:   to,
 		}
+	} else {
+		result.Mode = NumericModeSequence
+	}
+	return result, nil
+}
 
-		return DecVal{result}
-	},
-	"c-wsp": func(ctx *p.ReducerContext) interface{} {
-		return nil
-	},
-	"c-nl": func(ctx *p.ReducerContext) interface{} {
-		return nil
-	},
+// reduceAs reduces a into the value pointed to by dst, failing if the reducer
+// of a returns something else.
+func reduceAs(ctx *p.ReducerContext, a p.Atom, dst interface{}) error {
+	v, err := ctx.ReduceE(a)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(dst).Elem()
+	if v == nil || !reflect.TypeOf(v).AssignableTo(target.Type()) {
+		return fmt.Errorf("expected %s, found %T", target.Type(), v)
+	}
+	target.Set(reflect.ValueOf(v))
+	return nil
+}
+
+// reduceComments returns the comments found within a, in order.
+func reduceComments(ctx *p.ReducerContext, a p.Atom) ([]Comment, error) {
+	var result []Comment
+	for _, c := range p.FindAll(a, "comment") {
+		var comment Comment
+		if err := reduceAs(ctx, c, &comment); err != nil {
+			return nil, err
+		}
+		result = append(result, comment)
+	}
+	return result, nil
 }
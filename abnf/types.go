@@ -1,5 +1,7 @@
 package abnf
 
+import "github.com/heyvito/goparse/parser"
+
 type NodeKind int
 
 const (
@@ -23,6 +25,10 @@ const (
 	NodeKindDecVal
 	NodeKindHexVal
 	NodeKindProseVal
+	NodeKindCharSet
+	NodeKindLiteral
+	NodeKindTrie
+	NodeKindShaped
 )
 
 type Node interface {
@@ -45,6 +51,10 @@ type ProseVal struct {
 	Value string
 }
 
+func (p ProseVal) Kind() NodeKind {
+	return NodeKindProseVal
+}
+
 type Numeric struct {
 	Mode     NumericMode
 	Single   int
@@ -116,8 +126,11 @@ type Alternation struct {
 	Elements []Concatenation
 }
 
+// Comment holds the text following the ";" of a comment, up to the end of
+// its line.
 type Comment struct {
 	Value string
+	Span  parser.Span
 }
 
 type CNl struct {
@@ -138,6 +151,7 @@ type DefinedAs struct {
 
 type RuleName struct {
 	Name string
+	Span parser.Span
 }
 
 func (r RuleName) Kind() NodeKind {
@@ -148,8 +162,21 @@ type Rule struct {
 	Name      RuleName
 	DefinedAs DefinedAs
 	Elements  Elements
+	Span      parser.Span
+	// Comments holds the comments right above the rule and within it, and
+	// Annotations the ones holding annotations.
+	Comments    []Comment
+	Annotations []Annotation
+	// Shape is set through the @suppress, @inline or @token annotations.
+	Shape parser.Shape
 }
 
 type RuleList struct {
 	Rules []Rule
+	// Comments holds every comment in the grammar, in order.
+	Comments []Comment
 }
+
+// TriviaRules lists the rules of the ABNF grammar holding layout and
+// comments, to be used with parser.WithTrivia.
+var TriviaRules = []string{"c-wsp", "c-nl"}
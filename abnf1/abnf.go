@@ -43,5 +43,9 @@ func ParseABNFNaive(data string) (*abnf.RuleList, error) {
 	if err != nil {
 		return nil, err
 	}
-	return p.ReduceInto(tree, abnf.Reducer).(*abnf.RuleList), nil
+	list, err := p.ReduceIntoE(tree, abnf.ReducerE)
+	if err != nil {
+		return nil, err
+	}
+	return list.(*abnf.RuleList), nil
 }
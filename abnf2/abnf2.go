@@ -23,12 +23,12 @@ var parser = p.MakeRules(map[string]p.Consumer{
 	"element":       p.Alt(p.Ref("rulename"), p.Ref("group"), p.Ref("option"), p.Ref("char-val"), p.Ref("num-val"), p.Ref("prose-val")),
 	"group":         p.Cat(p.Lit('('), p.Star(p.Ref("c-wsp")), p.Ref("alternation"), p.Star(p.Ref("c-wsp")), p.Lit(')')),
 	"option":        p.Cat(p.Lit('['), p.Star(p.Ref("c-wsp")), p.Ref("alternation"), p.Star(p.Ref("c-wsp")), p.Lit(']')),
-	"char-val":      p.Cat(p.DQUOTE, p.Star(p.Alt(p.HexRange(0x20, 0x21), p.HexRange(0x23, 0x7e))), p.DQUOTE),
+	"char-val":      p.Cat(p.DQUOTE, p.Star(p.CharClass(p.CharRange{From: 0x20, To: 0x21}, p.CharRange{From: 0x23, To: 0x7e})), p.DQUOTE),
 	"num-val":       p.Cat(p.Lit('%'), p.Alt(p.Ref("bin-val"), p.Ref("dec-val"), p.Ref("hex-val"))),
 	"bin-val":       p.Cat(p.Lit('b'), p.Plus(p.BIT), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.BIT))), p.Cat(p.Lit('-'), p.Plus(p.BIT))))),
 	"dec-val":       p.Cat(p.Lit('d'), p.Plus(p.DIGIT), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.DIGIT))), p.Cat(p.Lit('-'), p.Plus(p.DIGIT))))),
 	"hex-val":       p.Cat(p.Lit('x'), p.Plus(p.HEXDIG), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.HEXDIG))), p.Cat(p.Lit('-'), p.Plus(p.HEXDIG))))),
-	"prose-val":     p.Cat(p.Lit('<'), p.Star(p.Alt(p.HexRange(0x20, 0x3d), p.HexRange(0x3f, 0x7e))), p.Lit('>')),
+	"prose-val":     p.Cat(p.Lit('<'), p.Star(p.CharClass(p.CharRange{From: 0x20, To: 0x3d}, p.CharRange{From: 0x3f, To: 0x7e})), p.Lit('>')),
 })
 
 func Parse(data string) (*abnf.RuleList, error) {
@@ -37,5 +37,9 @@ func Parse(data string) (*abnf.RuleList, error) {
 	if err != nil {
 		return nil, err
 	}
-	return p.ReduceInto(tree, abnf.Reducer).(*abnf.RuleList), nil
+	list, err := p.ReduceIntoE(tree, abnf.ReducerE)
+	if err != nil {
+		return nil, err
+	}
+	return list.(*abnf.RuleList), nil
 }
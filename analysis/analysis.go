@@ -0,0 +1,352 @@
+// Package analysis computes properties of ABNF grammars used to reason about,
+// lint and optimise them: which rules and expressions match the empty string
+// (nullable), which code points they may start with (FIRST) and which code
+// points may come right after them (FOLLOW).
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/heyvito/goparse/abnf"
+	"github.com/heyvito/goparse/parser"
+)
+
+// Info holds the properties of a rule or expression.
+type Info struct {
+	Nullable bool
+	First    Set
+	// Follow holds the code points that may follow the expression within the
+	// rules referencing it, and EOF when it may end the input.
+	Follow Set
+}
+
+// Grammar holds the properties computed for every rule of a RuleList.
+//
+// Rules are analysed as abnf.Compile would compile them: alternatives defined
+// with "=/" are merged into their base rule, literal strings only match
+// regardless of case under @case-insensitive, and references to core rules
+// always use the definitions of RFC 5234. References to undefined rules never
+// match, and prose values are assumed to match any non-empty input.
+type Grammar struct {
+	start string
+	names []string
+	rules map[string]*rule
+}
+
+type rule struct {
+	name     string
+	span     parser.Span
+	body     abnf.Alternation
+	fold     bool
+	core     bool
+	nullable bool
+	first    Set
+	follow   Set
+}
+
+var coreRules = map[string]rule{
+	"alpha":  {first: NewSet(Range{0x41, 0x5A}, Range{0x61, 0x7A})},
+	"bit":    {first: NewSet(Range{0x30, 0x31})},
+	"char":   {first: NewSet(Range{0x01, 0x7F})},
+	"cr":     {first: Runes(0x0D)},
+	"crlf":   {first: Runes(0x0D)},
+	"ctl":    {first: NewSet(Range{0x00, 0x1F}, Range{0x7F, 0x7F})},
+	"digit":  {first: NewSet(Range{0x30, 0x39})},
+	"dquote": {first: Runes(0x22)},
+	"hexdig": {first: NewSet(Range{0x30, 0x39}, Range{0x41, 0x46})},
+	"htab":   {first: Runes(0x09)},
+	"lf":     {first: Runes(0x0A)},
+	"lwsp":   {first: Runes(0x09, 0x0D, 0x20), nullable: true},
+	"octet":  {first: NewSet(Range{0x00, 0xFF})},
+	"sp":     {first: Runes(0x20)},
+	"vchar":  {first: NewSet(Range{0x21, 0x7E})},
+	"wsp":    {first: Runes(0x09, 0x20)},
+}
+
+// New analyses list. FOLLOW sets are computed from start, or from
+// list.StartRule when start is empty.
+func New(list *abnf.RuleList, start string) (*Grammar, error) {
+	g := &Grammar{rules: map[string]*rule{}}
+	for name, r := range coreRules {
+		r := r
+		r.name, r.core = strings.ToUpper(name), true
+		g.rules[name] = &r
+	}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		existing, ok := g.rules[name]
+		if ok && existing.core {
+			// Shadowed core rules are never referenced, as in abnf.Compile.
+			continue
+		}
+		if r.DefinedAs.Value == "=/" {
+			if !ok {
+				return nil, fmt.Errorf("rule %s: incremental alternative defined before its base rule", r.Name.Name)
+			}
+			existing.body.Elements = append(existing.body.Elements, r.Elements.Alternation.Elements...)
+			existing.fold = existing.fold || r.HasAnnotation(abnf.AnnotationCaseInsensitive)
+			continue
+		}
+		if ok {
+			return nil, fmt.Errorf("rule %s: defined more than once", r.Name.Name)
+		}
+		g.rules[name] = &rule{
+			name: r.Name.Name,
+			span: r.Name.Span,
+			body: r.Elements.Alternation,
+			fold: r.HasAnnotation(abnf.AnnotationCaseInsensitive),
+		}
+		g.names = append(g.names, r.Name.Name)
+	}
+
+	if start == "" {
+		var err error
+		if start, err = list.StartRule(); err != nil {
+			return nil, err
+		}
+	}
+	if r := g.rule(start); r == nil || r.core {
+		return nil, fmt.Errorf("start rule %s is not defined", start)
+	}
+	g.start = start
+
+	g.fixpoint(func(r *rule) bool {
+		if !r.nullable && g.nullable(r.body, r.fold) {
+			r.nullable = true
+			return true
+		}
+		return false
+	})
+	g.fixpoint(func(r *rule) bool {
+		first := r.first.Union(g.first(r.body, r.fold))
+		if first.Equal(r.first) {
+			return false
+		}
+		r.first = first
+		return true
+	})
+	g.rule(start).follow = EOF
+	g.fixpoint(func(r *rule) bool {
+		changed := false
+		g.walk(r.body, r.fold, r.follow, func(node interface{}, info Info) bool {
+			if ref, ok := node.(abnf.RuleName); ok {
+				if target := g.rule(ref.Name); target != nil {
+					follow := target.follow.Union(info.Follow)
+					changed = changed || !follow.Equal(target.follow)
+					target.follow = follow
+				}
+			}
+			return true
+		})
+		return changed
+	})
+	return g, nil
+}
+
+// fixpoint calls update for every grammar rule until it reports no change.
+func (g *Grammar) fixpoint(update func(r *rule) bool) {
+	for changed := true; changed; {
+		changed = false
+		for _, r := range g.rules {
+			if !r.core && update(r) {
+				changed = true
+			}
+		}
+	}
+}
+
+func (g *Grammar) rule(name string) *rule { return g.rules[strings.ToLower(name)] }
+
+// Rules returns the names of the rules of the grammar, in definition order.
+func (g *Grammar) Rules() []string { return append([]string(nil), g.names...) }
+
+// Start returns the rule FOLLOW sets were computed from.
+func (g *Grammar) Start() string { return g.start }
+
+// Rule returns the properties of the named rule, which may also be a core
+// rule, and whether it exists.
+func (g *Grammar) Rule(name string) (Info, bool) {
+	r := g.rule(name)
+	if r == nil {
+		return Info{}, false
+	}
+	return Info{Nullable: r.nullable, First: r.first, Follow: r.follow}, true
+}
+
+func (g *Grammar) Nullable(name string) bool {
+	info, _ := g.Rule(name)
+	return info.Nullable
+}
+
+func (g *Grammar) First(name string) Set {
+	info, _ := g.Rule(name)
+	return info.First
+}
+
+func (g *Grammar) Follow(name string) Set {
+	info, _ := g.Rule(name)
+	return info.Follow
+}
+
+// NullableOf reports whether node, an expression of the grammar, matches the
+// empty string.
+func (g *Grammar) NullableOf(node interface{}) bool { return g.nullable(node, false) }
+
+// FirstOf returns the code points node, an expression of the grammar, may
+// start with. Literal strings are taken as case-sensitive; use Inspect for
+// expressions of @case-insensitive rules.
+func (g *Grammar) FirstOf(node interface{}) Set { return g.first(node, false) }
+
+// Inspect traverses the expressions of the named rule like abnf.Inspect,
+// passing the properties of each of them to fn. FOLLOW sets of expressions
+// are relative to the rule they appear in, so the FOLLOW set of an
+// expression ending the rule is the one of the rule itself.
+func (g *Grammar) Inspect(name string, fn func(node interface{}, info Info) bool) {
+	if r := g.rule(name); r != nil && !r.core {
+		g.walk(r.body, r.fold, r.follow, fn)
+	}
+}
+
+func (g *Grammar) nullable(node interface{}, fold bool) bool {
+	switch n := node.(type) {
+	case abnf.Elements:
+		return g.nullable(n.Alternation, fold)
+	case abnf.Alternation:
+		for _, c := range n.Elements {
+			if g.nullable(c, fold) {
+				return true
+			}
+		}
+		return false
+	case abnf.Concatenation:
+		for _, r := range n.Elements {
+			if !g.nullable(r, fold) {
+				return false
+			}
+		}
+		return true
+	case abnf.Repetition:
+		return (n.Meta != nil && n.Meta.Min == 0) || g.nullable(n.Element, fold)
+	case abnf.Element:
+		return g.nullable(n.Inner, fold)
+	case abnf.Group:
+		return g.nullable(n.Elements, fold)
+	case abnf.Option:
+		return true
+	case abnf.RuleName:
+		r := g.rule(n.Name)
+		return r != nil && r.nullable
+	case abnf.CharVal:
+		return n.Value == ""
+	}
+	return false
+}
+
+func (g *Grammar) first(node interface{}, fold bool) Set {
+	switch n := node.(type) {
+	case abnf.Elements:
+		return g.first(n.Alternation, fold)
+	case abnf.Alternation:
+		s := Set{}
+		for _, c := range n.Elements {
+			s = s.Union(g.first(c, fold))
+		}
+		return s
+	case abnf.Concatenation:
+		s := Set{}
+		for _, r := range n.Elements {
+			s = s.Union(g.first(r, fold))
+			if !g.nullable(r, fold) {
+				break
+			}
+		}
+		return s
+	case abnf.Repetition:
+		return g.first(n.Element, fold)
+	case abnf.Element:
+		return g.first(n.Inner, fold)
+	case abnf.Group:
+		return g.first(n.Elements, fold)
+	case abnf.Option:
+		return g.first(n.Elements, fold)
+	case abnf.RuleName:
+		if r := g.rule(n.Name); r != nil {
+			return r.first
+		}
+	case abnf.CharVal:
+		if n.Value == "" {
+			return Set{}
+		}
+		r := []rune(n.Value)[0]
+		if fold {
+			return Runes(unicode.ToLower(r), unicode.ToUpper(r))
+		}
+		return Runes(r)
+	case abnf.HexVal:
+		return firstNumeric(n.Numeric)
+	case abnf.DecVal:
+		return firstNumeric(n.Numeric)
+	case abnf.BinVal:
+		return firstNumeric(n.Numeric)
+	case abnf.ProseVal:
+		return Any
+	}
+	return Set{}
+}
+
+func firstNumeric(n abnf.Numeric) Set {
+	switch n.Mode {
+	case abnf.NumericModeSingle:
+		return Runes(rune(n.Single))
+	case abnf.NumericModeRange:
+		return NewSet(Range{rune(n.Range.From), rune(n.Range.To)})
+	case abnf.NumericModeSequence:
+		if len(n.Sequence) > 0 {
+			return Runes(rune(n.Sequence[0]))
+		}
+	}
+	return Set{}
+}
+
+// walk traverses node, followed by follow, calling fn for it and each of its
+// children.
+func (g *Grammar) walk(node interface{}, fold bool, follow Set, fn func(node interface{}, info Info) bool) {
+	info := Info{Nullable: g.nullable(node, fold), First: g.first(node, fold), Follow: follow}
+	if !fn(node, info) {
+		return
+	}
+	switch n := node.(type) {
+	case abnf.Elements:
+		g.walk(n.Alternation, fold, follow, fn)
+	case abnf.Alternation:
+		for _, c := range n.Elements {
+			g.walk(c, fold, follow, fn)
+		}
+	case abnf.Concatenation:
+		follows := make([]Set, len(n.Elements))
+		for i := len(n.Elements) - 1; i >= 0; i-- {
+			follows[i] = follow
+			if g.nullable(n.Elements[i], fold) {
+				follow = follow.Union(g.first(n.Elements[i], fold))
+			} else {
+				follow = g.first(n.Elements[i], fold)
+			}
+		}
+		for i, r := range n.Elements {
+			g.walk(r, fold, follows[i], fn)
+		}
+	case abnf.Repetition:
+		if n.Meta != nil && (n.Meta.Max == 0 || n.Meta.Max > 1) {
+			follow = follow.Union(g.first(n.Element, fold))
+		}
+		g.walk(n.Element, fold, follow, fn)
+	case abnf.Element:
+		g.walk(n.Inner, fold, follow, fn)
+	case abnf.Group:
+		g.walk(n.Elements, fold, follow, fn)
+	case abnf.Option:
+		g.walk(n.Elements, fold, follow, fn)
+	}
+}
@@ -0,0 +1,392 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/heyvito/goparse/abnf"
+	"github.com/heyvito/goparse/parser"
+)
+
+type ConflictKind int
+
+const (
+	// ConflictFirst reports alternatives whose FIRST sets overlap, so that
+	// the next code point is not enough to pick one of them (an LL(1)
+	// conflict).
+	ConflictFirst ConflictKind = iota + 1
+	// ConflictFollow reports an alternative matching the empty string while
+	// another one may start with a code point that can follow the
+	// alternation.
+	ConflictFollow
+	// ConflictShadowed reports an alternative that is never chosen under
+	// ordered choice, as an earlier one always matches a prefix of its input.
+	ConflictShadowed
+	// ConflictGreedy reports a repetition whose body may start with a code
+	// point that can follow it, so that matching as many times as possible
+	// may consume input the rest of the rule needs.
+	ConflictGreedy
+)
+
+var conflictKindNames = map[ConflictKind]string{
+	ConflictFirst:    "first-first",
+	ConflictFollow:   "first-follow",
+	ConflictShadowed: "shadowed",
+	ConflictGreedy:   "greedy-repetition",
+}
+
+func (k ConflictKind) String() string { return conflictKindNames[k] }
+
+// Conflict is an ambiguity found by Conflicts. Span locates the name of the
+// rule it was found in, and Overlap holds the code points involved.
+type Conflict struct {
+	Kind       ConflictKind
+	Rule       string
+	Span       parser.Span
+	Overlap    Set
+	Message    string
+	Suggestion string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s [%s]; %s", c.Message, c.Kind, c.Suggestion)
+}
+
+// Conflicts reports the alternations and repetitions of the grammar whose
+// outcome depends on how alternatives are picked: alternatives sharing FIRST
+// code points, alternatives hidden by earlier ones under ordered choice, and
+// repetitions overlapping what follows them. Each conflict suggests a
+// reordering or factoring resolving it. Conflicts are sorted by position.
+func (g *Grammar) Conflicts() []Conflict {
+	var conflicts []Conflict
+	for _, name := range g.names {
+		r := g.rule(name)
+		report := func(kind ConflictKind, overlap Set, suggestion, format string, args ...interface{}) {
+			conflicts = append(conflicts, Conflict{
+				Kind:       kind,
+				Rule:       r.name,
+				Span:       r.span,
+				Overlap:    overlap,
+				Message:    fmt.Sprintf("rule %s: ", r.name) + fmt.Sprintf(format, args...),
+				Suggestion: suggestion,
+			})
+		}
+		g.walk(r.body, r.fold, r.follow, func(node interface{}, info Info) bool {
+			switch n := node.(type) {
+			case abnf.Alternation:
+				g.alternationConflicts(n, r.fold, info.Follow, report)
+			case abnf.Repetition:
+				if n.Meta == nil || (n.Meta.Max != 0 && n.Meta.Max <= n.Meta.Min) {
+					break
+				}
+				overlap := g.first(n.Element, r.fold).Intersect(info.Follow)
+				if overlap.IsEmpty() {
+					break
+				}
+				report(ConflictGreedy, overlap,
+					"bound the repetition, or change what follows it so it starts differently",
+					"repetition %s may consume %s, which may also follow it", abnf.Format(n), overlap)
+			}
+			return true
+		})
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Span.Start < conflicts[j].Span.Start
+	})
+	return conflicts
+}
+
+type reportFunc func(kind ConflictKind, overlap Set, suggestion, format string, args ...interface{})
+
+func (g *Grammar) alternationConflicts(alt abnf.Alternation, fold bool, follow Set, report reportFunc) {
+	if len(alt.Elements) < 2 {
+		return
+	}
+	shadowed := map[int]bool{}
+	for i, a := range alt.Elements {
+		if !g.nullable(a, fold) {
+			continue
+		}
+		if i < len(alt.Elements)-1 {
+			for j := i + 1; j < len(alt.Elements); j++ {
+				shadowed[j] = true
+			}
+			report(ConflictShadowed, Set{},
+				fmt.Sprintf("place %s last, or make it non-empty and wrap the alternation in [ ]", abnf.Format(a)),
+				"alternative %s matches the empty string, so the ones after it are never chosen under ordered choice",
+				abnf.Format(a))
+		}
+		for j, b := range alt.Elements {
+			if j == i {
+				continue
+			}
+			if overlap := g.first(b, fold).Intersect(follow); !overlap.IsEmpty() {
+				report(ConflictFollow, overlap,
+					fmt.Sprintf("make %s non-empty, or change what follows the alternation", abnf.Format(a)),
+					"alternative %s matches the empty string, and %s may also follow the alternation",
+					abnf.Format(a), overlap)
+			}
+		}
+		break
+	}
+
+	for j, b := range alt.Elements {
+		if shadowed[j] {
+			continue
+		}
+		prefix := g.prefix(b, fold, map[string]bool{})
+		for _, a := range alt.Elements[:j] {
+			seq, ok := g.sequence(a, fold, map[string]bool{})
+			if !ok || !covers(seq, prefix) {
+				continue
+			}
+			shadowed[j] = true
+			suggestion := fmt.Sprintf("place %s before %s", abnf.Format(b), abnf.Format(a))
+			if f, ok := factor(a, b); ok {
+				suggestion += ", or factor them as " + f
+			}
+			report(ConflictShadowed, Set{}, suggestion,
+				"alternative %s is never chosen under ordered choice, as %s always matches a prefix of it",
+				abnf.Format(b), abnf.Format(a))
+			break
+		}
+	}
+
+	for i, a := range alt.Elements {
+		for j := i + 1; j < len(alt.Elements); j++ {
+			if shadowed[i] || shadowed[j] {
+				continue
+			}
+			b := alt.Elements[j]
+			overlap := g.first(a, fold).Intersect(g.first(b, fold))
+			if overlap.IsEmpty() {
+				continue
+			}
+			suggestion := "make the alternatives start with distinct code points, or place the one matching longer input first"
+			if f, ok := factor(a, b); ok {
+				suggestion = "factor them as " + f
+			}
+			report(ConflictFirst, overlap, suggestion,
+				"alternatives %s and %s may both start with %s", abnf.Format(a), abnf.Format(b), overlap)
+		}
+	}
+}
+
+// covers reports whether every input matching prefix starts with a string
+// matching seq.
+func covers(seq, prefix []Set) bool {
+	if len(seq) == 0 || len(prefix) < len(seq) {
+		return false
+	}
+	for i, s := range seq {
+		if !prefix[i].Without(s).IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// sequence returns, when node only matches strings of a given length, the
+// code points it matches at each position.
+func (g *Grammar) sequence(node interface{}, fold bool, seen map[string]bool) ([]Set, bool) {
+	switch n := node.(type) {
+	case abnf.Elements:
+		return g.sequence(n.Alternation, fold, seen)
+	case abnf.Alternation:
+		if len(n.Elements) == 1 {
+			return g.sequence(n.Elements[0], fold, seen)
+		}
+		union := Set{}
+		for _, c := range n.Elements {
+			seq, ok := g.sequence(c, fold, seen)
+			if !ok || len(seq) != 1 {
+				return nil, false
+			}
+			union = union.Union(seq[0])
+		}
+		return []Set{union}, true
+	case abnf.Concatenation:
+		var result []Set
+		for _, r := range n.Elements {
+			seq, ok := g.sequence(r, fold, seen)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, seq...)
+		}
+		return result, true
+	case abnf.Repetition:
+		seq, ok := g.sequence(n.Element, fold, seen)
+		if !ok || n.Meta == nil {
+			return seq, ok
+		}
+		if n.Meta.Max == 0 || n.Meta.Min != n.Meta.Max {
+			return nil, false
+		}
+		var result []Set
+		for i := 0; i < n.Meta.Min; i++ {
+			result = append(result, seq...)
+		}
+		return result, true
+	case abnf.Element:
+		return g.sequence(n.Inner, fold, seen)
+	case abnf.Group:
+		return g.sequence(n.Elements, fold, seen)
+	case abnf.RuleName:
+		name := strings.ToLower(n.Name)
+		r := g.rule(name)
+		switch {
+		case r == nil || seen[name] || name == "lwsp":
+			return nil, false
+		case name == "crlf":
+			return []Set{Runes(0x0D), Runes(0x0A)}, true
+		case r.core:
+			return []Set{r.first}, true
+		}
+		seen[name] = true
+		defer delete(seen, name)
+		return g.sequence(r.body, r.fold, seen)
+	case abnf.CharVal:
+		result := make([]Set, 0, len(n.Value))
+		for _, r := range n.Value {
+			if fold {
+				result = append(result, Runes(unicode.ToLower(r), unicode.ToUpper(r)))
+			} else {
+				result = append(result, Runes(r))
+			}
+		}
+		return result, true
+	case abnf.HexVal:
+		return sequenceNumeric(n.Numeric), true
+	case abnf.DecVal:
+		return sequenceNumeric(n.Numeric), true
+	case abnf.BinVal:
+		return sequenceNumeric(n.Numeric), true
+	}
+	return nil, false
+}
+
+func sequenceNumeric(n abnf.Numeric) []Set {
+	if n.Mode != abnf.NumericModeSequence {
+		return []Set{firstNumeric(n)}
+	}
+	result := make([]Set, len(n.Sequence))
+	for i, v := range n.Sequence {
+		result[i] = Runes(rune(v))
+	}
+	return result
+}
+
+// prefix returns the code points every input matching node starts with, at
+// each position. Sets may hold more code points than the ones matched.
+func (g *Grammar) prefix(node interface{}, fold bool, seen map[string]bool) []Set {
+	if seq, ok := g.sequence(node, fold, map[string]bool{}); ok {
+		return seq
+	}
+	if g.nullable(node, fold) {
+		return nil
+	}
+	switch n := node.(type) {
+	case abnf.Elements:
+		return g.prefix(n.Alternation, fold, seen)
+	case abnf.Alternation:
+		if len(n.Elements) == 1 {
+			return g.prefix(n.Elements[0], fold, seen)
+		}
+	case abnf.Concatenation:
+		var result []Set
+		for i, r := range n.Elements {
+			if g.nullable(r, fold) {
+				rest := abnf.Concatenation{Elements: n.Elements[i:]}
+				if g.nullable(rest, fold) {
+					return result
+				}
+				return append(result, g.first(rest, fold))
+			}
+			if seq, ok := g.sequence(r, fold, map[string]bool{}); ok {
+				result = append(result, seq...)
+				continue
+			}
+			return append(result, g.prefix(r, fold, seen)...)
+		}
+		return result
+	case abnf.Repetition:
+		return g.prefix(n.Element, fold, seen)
+	case abnf.Element:
+		return g.prefix(n.Inner, fold, seen)
+	case abnf.Group:
+		return g.prefix(n.Elements, fold, seen)
+	case abnf.RuleName:
+		name := strings.ToLower(n.Name)
+		if r := g.rule(name); r != nil && !r.core && !seen[name] {
+			seen[name] = true
+			defer delete(seen, name)
+			return g.prefix(r.body, r.fold, seen)
+		}
+	}
+	return []Set{g.first(node, fold)}
+}
+
+// factor returns the ABNF text of a and b with their common leading elements,
+// or the common prefix of their leading literal strings, factored out.
+func factor(a, b abnf.Concatenation) (string, bool) {
+	n := 0
+	for n < len(a.Elements) && n < len(b.Elements) && abnf.Format(a.Elements[n]) == abnf.Format(b.Elements[n]) {
+		n++
+	}
+	common := append([]abnf.Repetition(nil), a.Elements[:n]...)
+	restA := append([]abnf.Repetition(nil), a.Elements[n:]...)
+	restB := append([]abnf.Repetition(nil), b.Elements[n:]...)
+	if len(restA) > 0 && len(restB) > 0 {
+		litA, okA := literal(restA[0])
+		litB, okB := literal(restB[0])
+		i := 0
+		for okA && okB && i < len(litA) && i < len(litB) && litA[i] == litB[i] {
+			i++
+		}
+		if i > 0 {
+			common = append(common, charVal(litA[:i]))
+			restA = splitLiteral(restA, litA[i:])
+			restB = splitLiteral(restB, litB[i:])
+		}
+	}
+	if len(common) == 0 {
+		return "", false
+	}
+
+	head := abnf.Format(abnf.Concatenation{Elements: common})
+	tailA := abnf.Format(abnf.Concatenation{Elements: restA})
+	tailB := abnf.Format(abnf.Concatenation{Elements: restB})
+	switch {
+	case tailA == "" && tailB == "":
+		return head, true
+	case tailA == "":
+		return fmt.Sprintf("%s [ %s ]", head, tailB), true
+	case tailB == "":
+		return fmt.Sprintf("%s [ %s ]", head, tailA), true
+	}
+	return fmt.Sprintf("%s ( %s / %s )", head, tailA, tailB), true
+}
+
+func literal(r abnf.Repetition) (string, bool) {
+	if r.Meta != nil {
+		return "", false
+	}
+	v, ok := r.Element.Inner.(abnf.CharVal)
+	return v.Value, ok
+}
+
+func charVal(value string) abnf.Repetition {
+	return abnf.Repetition{Element: abnf.Element{Inner: abnf.CharVal{Value: value}}}
+}
+
+// splitLiteral replaces the leading literal of rest by its remaining value.
+func splitLiteral(rest []abnf.Repetition, value string) []abnf.Repetition {
+	if value == "" {
+		return rest[1:]
+	}
+	rest[0] = charVal(value)
+	return rest
+}
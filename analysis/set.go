@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Range is an inclusive range of code points.
+type Range struct {
+	Lo, Hi rune
+}
+
+func (r Range) String() string {
+	if r.Lo == r.Hi {
+		return fmt.Sprintf("%%x%02X", r.Lo)
+	}
+	return fmt.Sprintf("%%x%02X-%02X", r.Lo, r.Hi)
+}
+
+// Set is a set of code points, kept as sorted, non-adjacent ranges, that may
+// also hold the end of input. The zero value is the empty set.
+type Set struct {
+	ranges []Range
+	eof    bool
+}
+
+// Any holds every code point.
+var Any = NewSet(Range{0, unicode.MaxRune})
+
+// EOF holds only the end of input.
+var EOF = Set{eof: true}
+
+// NewSet returns the set holding the given ranges.
+func NewSet(ranges ...Range) Set {
+	s := Set{}
+	for _, r := range ranges {
+		s = s.Union(Set{ranges: []Range{r}})
+	}
+	return s
+}
+
+// Runes returns the set holding the given code points.
+func Runes(runes ...rune) Set {
+	ranges := make([]Range, len(runes))
+	for i, r := range runes {
+		ranges[i] = Range{r, r}
+	}
+	return NewSet(ranges...)
+}
+
+// Ranges returns the code point ranges of s, in order.
+func (s Set) Ranges() []Range { return append([]Range(nil), s.ranges...) }
+
+// HasEOF reports whether s holds the end of input.
+func (s Set) HasEOF() bool { return s.eof }
+
+// IsEmpty reports whether s holds neither code points nor the end of input.
+func (s Set) IsEmpty() bool { return len(s.ranges) == 0 && !s.eof }
+
+// Contains reports whether s holds r.
+func (s Set) Contains(r rune) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].Hi >= r })
+	return i < len(s.ranges) && s.ranges[i].Lo <= r
+}
+
+// Len returns the amount of code points in s.
+func (s Set) Len() int {
+	n := 0
+	for _, r := range s.ranges {
+		n += int(r.Hi-r.Lo) + 1
+	}
+	return n
+}
+
+func (s Set) Union(o Set) Set {
+	all := make([]Range, 0, len(s.ranges)+len(o.ranges))
+	all = append(append(all, s.ranges...), o.ranges...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Lo < all[j].Lo })
+	var ranges []Range
+	for _, r := range all {
+		if n := len(ranges); n > 0 && r.Lo <= ranges[n-1].Hi+1 {
+			if r.Hi > ranges[n-1].Hi {
+				ranges[n-1].Hi = r.Hi
+			}
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return Set{ranges: ranges, eof: s.eof || o.eof}
+}
+
+func (s Set) Intersect(o Set) Set {
+	var ranges []Range
+	for i, j := 0, 0; i < len(s.ranges) && j < len(o.ranges); {
+		a, b := s.ranges[i], o.ranges[j]
+		lo, hi := a.Lo, a.Hi
+		if b.Lo > lo {
+			lo = b.Lo
+		}
+		if b.Hi < hi {
+			hi = b.Hi
+		}
+		if lo <= hi {
+			ranges = append(ranges, Range{lo, hi})
+		}
+		if a.Hi < b.Hi {
+			i++
+		} else {
+			j++
+		}
+	}
+	return Set{ranges: ranges, eof: s.eof && o.eof}
+}
+
+// Without returns the code points of s not held by o.
+func (s Set) Without(o Set) Set {
+	var ranges []Range
+	for _, r := range s.ranges {
+		for _, x := range o.ranges {
+			if x.Hi < r.Lo || x.Lo > r.Hi {
+				continue
+			}
+			if x.Lo > r.Lo {
+				ranges = append(ranges, Range{r.Lo, x.Lo - 1})
+			}
+			r.Lo = x.Hi + 1
+			if r.Lo > r.Hi {
+				break
+			}
+		}
+		if r.Lo <= r.Hi {
+			ranges = append(ranges, r)
+		}
+	}
+	return Set{ranges: ranges, eof: s.eof && !o.eof}
+}
+
+func (s Set) Equal(o Set) bool {
+	if s.eof != o.eof || len(s.ranges) != len(o.ranges) {
+		return false
+	}
+	for i, r := range s.ranges {
+		if o.ranges[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats s as an ABNF alternation of numeric values, such as
+// "%x09 / %x20-7E / EOF".
+func (s Set) String() string {
+	if s.IsEmpty() {
+		return "{}"
+	}
+	parts := make([]string, 0, len(s.ranges)+1)
+	for _, r := range s.ranges {
+		parts = append(parts, r.String())
+	}
+	if s.eof {
+		parts = append(parts, "EOF")
+	}
+	return strings.Join(parts, " / ")
+}
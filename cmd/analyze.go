@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/heyvito/goparse/analysis"
+	"github.com/heyvito/goparse/parser"
+)
+
+var analyzeCommand = &cli.Command{
+	Name:      "analyze",
+	Usage:     "prints whether rules match the empty string, their FIRST and FOLLOW sets, and conflicts between alternatives",
+	ArgsUsage: "GRAMMAR",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "rule",
+			Aliases: []string{"r"},
+			Usage:   "Rule to print. Defaults to every rule of the grammar",
+		},
+		&cli.StringFlag{
+			Name:    "start",
+			Aliases: []string{"s"},
+			Usage:   "Rule FOLLOW sets are computed from. Defaults to the rule annotated with @start, or the first rule",
+		},
+		&cli.BoolFlag{
+			Name:  "conflicts",
+			Usage: "Prints conflicts between alternatives and repetitions instead, exiting with an error when any is found",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Invalid input arguments.\nUsage: goparse analyze [-r RULE] [-s RULE] [--conflicts] GRAMMAR")
+			os.Exit(1)
+		}
+
+		path := c.Args().First()
+		list, source := loadGrammarSource(path)
+		g, err := analysis.New(list, c.String("start"))
+		if err != nil {
+			fmt.Printf("Error analysing %s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		if c.Bool("conflicts") {
+			conflicts := g.Conflicts()
+			lines := parser.NewLines(source)
+			for _, conflict := range conflicts {
+				line, col := lines.LineCol(conflict.Span.Start)
+				fmt.Printf("%s:%d:%d: %s [%s]\n    suggestion: %s\n", path, line, col, conflict.Message, conflict.Kind, conflict.Suggestion)
+			}
+			if len(conflicts) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		}
+
+		rules := c.StringSlice("rule")
+		if len(rules) == 0 {
+			rules = g.Rules()
+		}
+		for _, name := range rules {
+			info, ok := g.Rule(name)
+			if !ok {
+				fmt.Printf("Rule %s is not defined\n", name)
+				os.Exit(1)
+			}
+			fmt.Printf("%s\n  nullable: %t\n  first:    %s\n  follow:   %s\n", name, info.Nullable, info.First, info.Follow)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/heyvito/goparse/abnf"
+	"github.com/heyvito/goparse/abnf2"
+	"github.com/heyvito/goparse/parser"
+)
+
+var checkCommand = &cli.Command{
+	Name:      "check",
+	Usage:     "reports undefined, unreachable, duplicate and shadowed rules in ABNF grammars",
+	ArgsUsage: "GRAMMAR...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "start",
+			Aliases: []string{"s"},
+			Usage:   "Rule inputs are parsed from. Defaults to the rule annotated with @start, or the first rule",
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Fails on warnings, such as unreachable rules, along with errors",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			fmt.Println("Invalid input arguments.\nUsage: goparse check [-s RULE] [--strict] GRAMMAR...")
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, path := range c.Args().Slice() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Printf("%s: error: %s\n", path, err)
+				failed = true
+				continue
+			}
+			source := string(data)
+			list, err := abnf2.Parse(source)
+			if err != nil {
+				if pe, ok := err.(*parser.ParseError); ok {
+					pe = pe.Furthest()
+					line, col := parser.LineCol(source, pe.Position)
+					fmt.Printf("%s:%d:%d: error: %s\n", path, line, col, pe.Message)
+				} else {
+					fmt.Printf("%s: error: %s\n", path, err)
+				}
+				failed = true
+				continue
+			}
+			lines := parser.NewLines(source)
+			for _, f := range abnf.Analyze(list, c.String("start")) {
+				if f.Severity == abnf.SeverityError || c.Bool("strict") {
+					failed = true
+				}
+				if f.Span == (parser.Span{}) {
+					fmt.Printf("%s: %s\n", path, f)
+					continue
+				}
+				line, col := lines.LineCol(f.Span.Start)
+				fmt.Printf("%s:%d:%d: %s\n", path, line, col, f)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
@@ -19,7 +19,11 @@ func genOutput(pkg, output string) (string, error) {
 		"",
 		"package " + pkg,
 		"",
-		"import p \"github.com/heyvito/goparse/parser\"",
+		"import (",
+		"\"sync\"",
+		"",
+		"p \"github.com/heyvito/goparse/parser\"",
+		")",
 		"",
 		output,
 	}, "\n")
@@ -32,65 +36,124 @@ func genOutput(pkg, output string) (string, error) {
 	return string(p), nil
 }
 
+var genFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Usage:   "Instructs the parser to use the provided grammar to parse input data (ABNF/EDL)",
+		Value:   "abnf",
+	},
+	&cli.StringFlag{
+		Name:    "package",
+		Aliases: []string{"p"},
+		Usage:   "Go package name to use when generating output file (required)",
+	},
+	&cli.BoolFlag{
+		Name:    "optimize",
+		Aliases: []string{"O"},
+		Usage:   "Runs the optimisation passes over the grammar before generating the parser",
+	},
+	&cli.BoolFlag{
+		Name:  "native",
+		Usage: "Generates a standalone recursive-descent parser instead of rules for the parser package",
+	},
+	&cli.BoolFlag{
+		Name:  "ast",
+		Usage: "Also generates types for the rules, along with the reducers building them",
+	},
+}
+
+func genAction(c *cli.Context) error {
+	if c.NArg() != 2 {
+		fmt.Println("Invalid input arguments.\nUsage: goparse [gen] -p PACKAGE-NAME INPUT OUTPUT")
+		os.Exit(1)
+	}
+
+	// The flag cannot be marked as required, as the root command shares it,
+	// and required flags of the root command are also checked for every
+	// other command.
+	if !c.IsSet("package") {
+		return fmt.Errorf("Required flag %q not set", "package")
+	}
+
+	pkg := c.String("package")
+	inputFormat := strings.ToLower(c.String("format"))
+	input := c.Args().First()
+	if inputFormat != "abnf" && inputFormat != "edl" {
+		fmt.Println("Invalid format.\nFormats available: abnf, edl")
+		os.Exit(1)
+	}
+
+	// For now we don't really care about EDL...
+	rules := loadGrammar(input)
+	if c.Bool("optimize") {
+		rules = abnf.Optimize(rules)
+	}
+
+	var output string
+	var err error
+	if c.Bool("native") {
+		output, err = abnf.GenerateNative(pkg, rules)
+	} else if c.Bool("ast") {
+		output, err = abnf.GenerateAST(pkg, rules)
+	} else if output, err = abnf.GenerateE(rules); err == nil {
+		output, err = genOutput(pkg, output)
+	}
+	if err != nil {
+		fmt.Printf("Error generating sources: %s\nThis is probably a bug. Please report it to https://github.com/heyvito/goparse/issues/new\n", err)
+		os.Exit(1)
+	}
+
+	outFile := c.Args().Get(1)
+	err = os.WriteFile(outFile, []byte(output), os.ModePerm)
+	if err != nil {
+		fmt.Printf("Error writing %s: %s\n", outFile, err)
+	}
+
+	return nil
+}
+
+func loadGrammar(input string) *abnf.RuleList {
+	rules, _ := loadGrammarSource(input)
+	return rules
+}
+
+// loadGrammarSource is like loadGrammar, but also returns the text of the
+// grammar.
+func loadGrammarSource(input string) (*abnf.RuleList, string) {
+	inputBytes, err := os.ReadFile(input)
+	if err != nil {
+		fmt.Printf("Error reading %s: %s\n", input, err)
+		os.Exit(1)
+	}
+
+	rules, err := abnf2.Parse(string(inputBytes))
+	if err != nil {
+		fmt.Printf("Error parsing %s:\n    %s\n", input, err)
+		os.Exit(1)
+	}
+	return rules, string(inputBytes)
+}
+
 func main() {
 	app := &cli.App{
-		Name:  "goparse",
-		Usage: "generates parsers from ABNF/EDL rules",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:    "format",
-				Aliases: []string{"f"},
-				Usage:   "Instructs the parser to use the provided grammar to parse input data (ABNF/EDL)",
-				Value:   "abnf",
-			},
-			&cli.StringFlag{
-				Name:     "package",
-				Aliases:  []string{"p"},
-				Usage:    "Go package name to use when generating output file",
-				Required: true,
-				Value:    "abnf",
-			},
-		},
+		Name:      "goparse",
+		Usage:     "generates parsers from ABNF/EDL rules",
+		Flags:     genFlags,
 		ArgsUsage: "INPUT OUTPUT",
-		Action: func(c *cli.Context) error {
-			if c.NArg() != 2 {
-				fmt.Println("Invalid input arguments.\nUsage: goparse -p PACKAGE-NAME INPUT OUTPUT")
-				os.Exit(1)
-			}
-
-			pkg := c.String("package")
-			inputFormat := strings.ToLower(c.String("format"))
-			input := c.Args().First()
-			if inputFormat != "abnf" && inputFormat != "edl" {
-				fmt.Println("Invalid format.\nFormats available: abnf, edl")
-				os.Exit(1)
-			}
-
-			// For now we don't really care about EDL...
-			inputBytes, err := os.ReadFile(input)
-			if err != nil {
-				fmt.Printf("Error reading %s: %s\n", input, err)
-				os.Exit(1)
-			}
-
-			rules, err := abnf2.Parse(string(inputBytes))
-			if err != nil {
-				fmt.Printf("Error parsing %s:\n    %s", input, err)
-			}
-
-			output, err := genOutput(pkg, abnf.Generate(rules))
-			if err != nil {
-				fmt.Printf("Error generating sources: %s\nThis is probably a bug. Please report it to https://github.com/heyvito/goparse/issues/new\n", err)
-				os.Exit(1)
-			}
-
-			outFile := c.Args().Get(1)
-			err = os.WriteFile(outFile, []byte(output), os.ModePerm)
-			if err != nil {
-				fmt.Printf("Error writing %s: %s\n", outFile, err)
-			}
-
-			return nil
+		Action:    genAction,
+		Commands: []*cli.Command{
+			{
+				Name:      "gen",
+				Usage:     "generates a parser from an ABNF grammar",
+				Flags:     genFlags,
+				ArgsUsage: "INPUT OUTPUT",
+				Action:    genAction,
+			},
+			queryCommand,
+			checkCommand,
+			analyzeCommand,
+			transformCommand,
 		},
 	}
 
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/heyvito/goparse/abnf"
+	"github.com/heyvito/goparse/parser"
+)
+
+var queryCommand = &cli.Command{
+	Name:      "query",
+	Usage:     "parses a document with a grammar and prints the text of nodes matching a selector",
+	ArgsUsage: "SELECTOR [INPUT]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "grammar",
+			Aliases:  []string{"g"},
+			Usage:    "ABNF grammar used to parse the input",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "rule",
+			Aliases:  []string{"r"},
+			Usage:    "Rule the input is parsed as",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "tree",
+			Usage: "Prints the tree of each match instead of its text",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 || c.NArg() > 2 {
+			fmt.Println("Invalid input arguments.\nUsage: goparse query -g GRAMMAR -r RULE SELECTOR [INPUT]")
+			os.Exit(1)
+		}
+
+		query, err := parser.CompileQuery(c.Args().First())
+		if err != nil {
+			fmt.Printf("Invalid selector: %s\n", err)
+			os.Exit(1)
+		}
+
+		rules, err := abnf.Compile(loadGrammar(c.String("grammar")))
+		if err != nil {
+			fmt.Printf("Error compiling %s: %s\n", c.String("grammar"), err)
+			os.Exit(1)
+		}
+
+		input := "stdin"
+		var data []byte
+		if c.NArg() == 2 {
+			input = c.Args().Get(1)
+			data, err = os.ReadFile(input)
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Printf("Error reading %s: %s\n", input, err)
+			os.Exit(1)
+		}
+
+		cur := parser.CursorFromString(string(data))
+		tree, err := parser.KickoffParser(&cur, rules, c.String("rule"))
+		if err != nil {
+			fmt.Printf("Error parsing %s:\n    %s\n", input, err)
+			os.Exit(1)
+		}
+
+		for _, match := range query.Select(tree) {
+			if c.Bool("tree") {
+				fmt.Print(parser.PrintTree(match))
+			} else {
+				fmt.Println(parser.Text(match))
+			}
+		}
+		return nil
+	},
+}
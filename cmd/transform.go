@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/heyvito/goparse/abnf"
+)
+
+var transformCommand = &cli.Command{
+	Name:      "transform",
+	Usage:     "rewrites an ABNF grammar, writing the result as ABNF",
+	ArgsUsage: "GRAMMAR [OUTPUT]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "eliminate-left-recursion",
+			Usage: "Rewrites left recursive rules using repetitions",
+		},
+		&cli.BoolFlag{
+			Name:  "hints",
+			Usage: "Annotates rewritten rules with @fold-left, so reducers can still fold their results left-associatively",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 || c.NArg() > 2 {
+			fmt.Println("Invalid input arguments.\nUsage: goparse transform --eliminate-left-recursion [--hints] GRAMMAR [OUTPUT]")
+			os.Exit(1)
+		}
+		if !c.Bool("eliminate-left-recursion") {
+			fmt.Println("No transform selected.\nTransforms available: --eliminate-left-recursion")
+			os.Exit(1)
+		}
+
+		input := c.Args().First()
+		list := loadGrammar(input)
+		list, err := abnf.EliminateLeftRecursion(list, c.Bool("hints"))
+		if err != nil {
+			fmt.Printf("Error transforming %s: %s\n", input, err)
+			os.Exit(1)
+		}
+
+		output := abnf.Format(list)
+		if c.NArg() == 1 {
+			fmt.Print(output)
+			return nil
+		}
+		outFile := c.Args().Get(1)
+		if err = os.WriteFile(outFile, []byte(output), 0644); err != nil {
+			fmt.Printf("Error writing %s: %s\n", outFile, err)
+			os.Exit(1)
+		}
+		return nil
+	},
+}
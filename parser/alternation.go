@@ -32,7 +32,14 @@ func (a AlternationConsumer) String() string {
 	}
 	return fmt.Sprintf("( %s )", strings.Join(str, " / "))
 }
-func (a AlternationConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+func (a *AlternationConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+	if sets := dispatchSets(ctx, a); sets != nil {
+		if v, ok := a.dispatch(ctx, c, sets); ok {
+			return v, nil
+		}
+		// Errors are reported as if every alternative had been attempted.
+	}
+
 	var results WeightedResults
 	var errors ParseErrors
 	for _, v := range a.cons {
@@ -58,3 +65,29 @@ func (a AlternationConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, e
 	c.Merge(res.c)
 	return res.v, nil
 }
+
+// dispatch attempts only the alternatives that may start with the next rune,
+// according to their FIRST sets, where a nil set stands for an alternative
+// that must always be attempted. It picks among them as TryConsume does.
+func (a *AlternationConsumer) dispatch(ctx context.Context, c *Cursor, sets []*CharClassConsumer) (Atom, bool) {
+	ok, r := c.TryPeek()
+	var best Atom
+	var end Cursor
+	weight := -1
+	for i, v := range a.cons {
+		if sets[i] != nil && (!ok || !sets[i].Contains(r)) {
+			continue
+		}
+		if w := v.Weight(); w > weight {
+			cd := c.dup()
+			if ret, err := v.TryConsume(ctx, &cd); err == nil {
+				best, end, weight = ret, cd, w
+			}
+		}
+	}
+	if weight < 0 {
+		return nil, false
+	}
+	c.Merge(end)
+	return best, true
+}
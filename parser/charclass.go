@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CharRange is an inclusive range of runes matched by a CharClass.
+type CharRange struct {
+	From, To rune
+}
+
+func (r CharRange) String() string {
+	if r.From == r.To {
+		return fmt.Sprintf("%%x%02x", r.From)
+	}
+	return fmt.Sprintf("%%x%02x-%02x", r.From, r.To)
+}
+
+// CharClassConsumer matches a single rune within a set of ranges, producing
+// a Char as Lit and HexRange do. ASCII runes are looked up in a bitmap, and
+// others are searched among the ranges, which are kept sorted and disjoint.
+type CharClassConsumer struct {
+	ranges []CharRange
+	ascii  [2]uint64
+}
+
+// CharClass returns a consumer matching any rune within ranges. It is
+// equivalent to an alternation of the corresponding Lit and HexRange
+// consumers, without trying each of them in turn.
+func CharClass(ranges ...CharRange) *CharClassConsumer {
+	return newCharClass(append([]CharRange(nil), ranges...))
+}
+
+// Chars returns a consumer matching any of runes.
+func Chars(runes ...rune) *CharClassConsumer {
+	ranges := make([]CharRange, len(runes))
+	for i, r := range runes {
+		ranges[i] = CharRange{r, r}
+	}
+	return newCharClass(ranges)
+}
+
+func newCharClass(ranges []CharRange) *CharClassConsumer {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+	c := &CharClassConsumer{}
+	for _, r := range ranges {
+		if r.From > r.To {
+			continue
+		}
+		if n := len(c.ranges); n > 0 && r.From <= c.ranges[n-1].To+1 {
+			if r.To > c.ranges[n-1].To {
+				c.ranges[n-1].To = r.To
+			}
+			continue
+		}
+		c.ranges = append(c.ranges, r)
+	}
+	for _, r := range c.ranges {
+		for v := r.From; v <= r.To && v <= unicode.MaxASCII; v++ {
+			c.ascii[v/64] |= 1 << uint(v%64)
+		}
+	}
+	return c
+}
+
+// Ranges returns the sorted, disjoint ranges matched by c.
+func (c CharClassConsumer) Ranges() []CharRange { return append([]CharRange(nil), c.ranges...) }
+
+// Contains reports whether c matches r.
+func (c CharClassConsumer) Contains(r rune) bool {
+	if r >= 0 && r <= unicode.MaxASCII {
+		return c.ascii[r/64]&(1<<uint(r%64)) != 0
+	}
+	i := sort.Search(len(c.ranges), func(i int) bool { return c.ranges[i].To >= r })
+	return i < len(c.ranges) && c.ranges[i].From <= r
+}
+
+// Union returns a class matching runes matched by either c or other.
+func (c CharClassConsumer) Union(other *CharClassConsumer) *CharClassConsumer {
+	return newCharClass(append(c.Ranges(), other.ranges...))
+}
+
+// Intersect returns a class matching runes matched by both c and other.
+func (c CharClassConsumer) Intersect(other *CharClassConsumer) *CharClassConsumer {
+	var ranges []CharRange
+	for i, j := 0, 0; i < len(c.ranges) && j < len(other.ranges); {
+		a, b := c.ranges[i], other.ranges[j]
+		from, to := a.From, a.To
+		if b.From > from {
+			from = b.From
+		}
+		if b.To < to {
+			to = b.To
+		}
+		if from <= to {
+			ranges = append(ranges, CharRange{from, to})
+		}
+		if a.To < b.To {
+			i++
+		} else {
+			j++
+		}
+	}
+	return newCharClass(ranges)
+}
+
+// Negate returns a class matching every rune not matched by c.
+func (c CharClassConsumer) Negate() *CharClassConsumer {
+	var ranges []CharRange
+	next := rune(0)
+	for _, r := range c.ranges {
+		if r.From > next {
+			ranges = append(ranges, CharRange{next, r.From - 1})
+		}
+		next = r.To + 1
+	}
+	if next <= unicode.MaxRune {
+		ranges = append(ranges, CharRange{next, unicode.MaxRune})
+	}
+	return newCharClass(ranges)
+}
+
+func (c CharClassConsumer) Name() string { return fmt.Sprintf("CHARCLASS(%s)", c.String()) }
+func (c CharClassConsumer) String() string {
+	str := make([]string, len(c.ranges))
+	for i, r := range c.ranges {
+		str[i] = r.String()
+	}
+	return fmt.Sprintf("( %s )", strings.Join(str, " / "))
+}
+
+// Weight is the same as the alternation c replaces.
+func (CharClassConsumer) Weight() int { return 1 }
+func (c CharClassConsumer) TryConsume(ctx context.Context, cur *Cursor) (Atom, error) {
+	ok, v := cur.TryPeek()
+	if !ok {
+		return nil, Error(cur, "Expected one of %s, found EOF", c.String())
+	}
+	if c.Contains(v) {
+		cur.Consume()
+		return Char{value: string(v), link: terminalLink(cur)}, nil
+	}
+	return nil, Error(cur, "Expected one of %s, found %q instead", c.String(), v)
+}
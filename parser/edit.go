@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+)
+
+// EditNode is a mutable view over an atom, used to change a parsed tree and
+// print it back. Regions of the input that were not changed, including any
+// text between atoms such as trivia removed by a lossless Parser, are printed
+// exactly as they were.
+type EditNode struct {
+	atom     Atom
+	source   []rune
+	parent   *EditNode
+	children []*EditNode
+	// gap holds the input between the previous sibling, or the start of the
+	// parent, and this node. tail holds the input between the last child and
+	// the end of this node.
+	gap   string
+	tail  string
+	text  *string
+	dirty bool
+}
+
+var (
+	errEditRoot   = errors.New("cannot edit the position of a node without a parent")
+	errEditNil    = errors.New("cannot place a nil node")
+	errEditWithin = errors.New("cannot place a node within itself")
+)
+
+// Edit returns a mutable tree for the tree rooted at root, or nil when root
+// is nil.
+func Edit(root Atom) *EditNode {
+	if root == nil {
+		return nil
+	}
+	return newEditNode(root, sourceOf(root))
+}
+
+// EditText returns a detached node printed as text, to be placed in a tree
+// through Replace, InsertBefore or InsertAfter.
+func EditText(text string) *EditNode {
+	return &EditNode{text: &text, dirty: true}
+}
+
+func newEditNode(a Atom, source []rune) *EditNode {
+	n := &EditNode{atom: a, source: source}
+	span := a.Span()
+	pos := span.Start
+	for _, c := range a.Children() {
+		child := newEditNode(c, source)
+		child.parent = n
+		if source != nil {
+			cs := c.Span()
+			if cs.Start >= pos {
+				child.gap = string(source[pos:cs.Start])
+			}
+			pos = cs.End
+		}
+		n.children = append(n.children, child)
+	}
+	if source != nil && span.End > pos {
+		n.tail = string(source[pos:span.End])
+	}
+	return n
+}
+
+// Atom returns the atom n was created from, or nil for nodes created through
+// EditText.
+func (n *EditNode) Atom() Atom { return n.atom }
+
+// Name returns the name of the rule n was created from, if any.
+func (n *EditNode) Name() string {
+	if ref, ok := n.atom.(RefResult); ok {
+		return ref.Name
+	}
+	return ""
+}
+
+func (n *EditNode) Parent() *EditNode { return n.parent }
+
+func (n *EditNode) Children() []*EditNode { return n.children }
+
+// FindAll returns every node within n, including n itself, created from a
+// rule named ruleName, in document order.
+func (n *EditNode) FindAll(ruleName string) []*EditNode {
+	var result []*EditNode
+	if n.Name() == ruleName {
+		result = append(result, n)
+	}
+	for _, c := range n.children {
+		result = append(result, c.FindAll(ruleName)...)
+	}
+	return result
+}
+
+func (n *EditNode) touch() {
+	for p := n; p != nil; p = p.parent {
+		p.dirty = true
+	}
+}
+
+func (n *EditNode) indexInParent() int {
+	for i, c := range n.parent.children {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// detach removes n from its parent, along with the input that preceded it.
+func (n *EditNode) detach() {
+	if n.parent == nil {
+		return
+	}
+	p := n.parent
+	i := n.indexInParent()
+	p.children = append(p.children[:i:i], p.children[i+1:]...)
+	p.touch()
+	n.parent, n.gap = nil, ""
+}
+
+// placeable reports why other cannot be placed next to n or in its place:
+// when n has no parent, or when other is n or one of its ancestors, which
+// would make the tree hold itself.
+func (n *EditNode) placeable(other *EditNode) error {
+	if n.parent == nil {
+		return errEditRoot
+	}
+	if other == nil {
+		return errEditNil
+	}
+	for p := n; p != nil; p = p.parent {
+		if p == other {
+			return errEditWithin
+		}
+	}
+	return nil
+}
+
+func (n *EditNode) insertAt(i int, other *EditNode) {
+	other.detach()
+	n.children = append(n.children[:i:i], append([]*EditNode{other}, n.children[i:]...)...)
+	other.parent = n
+	n.touch()
+}
+
+// Replace puts other in the place of n, keeping the input that preceded n.
+// other is detached from its current parent, if any, and cannot be n or one
+// of its ancestors.
+func (n *EditNode) Replace(other *EditNode) error {
+	if err := n.placeable(other); err != nil {
+		return err
+	}
+	other.detach()
+	p, i, gap := n.parent, n.indexInParent(), n.gap
+	n.detach()
+	p.insertAt(i, other)
+	other.gap = gap
+	return nil
+}
+
+// InsertBefore places other right before n, without any text between them.
+func (n *EditNode) InsertBefore(other *EditNode) error {
+	if err := n.placeable(other); err != nil {
+		return err
+	}
+	other.detach()
+	n.parent.insertAt(n.indexInParent(), other)
+	return nil
+}
+
+// InsertAfter places other right after n, without any text between them.
+func (n *EditNode) InsertAfter(other *EditNode) error {
+	if err := n.placeable(other); err != nil {
+		return err
+	}
+	other.detach()
+	n.parent.insertAt(n.indexInParent()+1, other)
+	return nil
+}
+
+// Remove detaches n from its parent, along with the input that preceded it.
+func (n *EditNode) Remove() error {
+	if n.parent == nil {
+		return errEditRoot
+	}
+	n.detach()
+	return nil
+}
+
+// SetText makes n print as text, discarding its children.
+func (n *EditNode) SetText(text string) {
+	n.text = &text
+	n.children = nil
+	n.tail = ""
+	n.touch()
+}
+
+// Text returns the text of the subtree rooted at n, reflecting every change
+// made to it.
+func (n *EditNode) Text() string {
+	str := strings.Builder{}
+	n.write(&str)
+	return str.String()
+}
+
+func (n *EditNode) String() string { return n.Text() }
+
+func (n *EditNode) write(str *strings.Builder) {
+	switch {
+	case n.text != nil:
+		str.WriteString(*n.text)
+	case !n.dirty && n.source != nil:
+		span := n.atom.Span()
+		str.WriteString(string(n.source[span.Start:span.End]))
+	case !n.dirty:
+		str.WriteString(renderText(n.atom))
+	case isTerminal(n.atom):
+		str.WriteString(terminalText(n.atom))
+	default:
+		for _, c := range n.children {
+			str.WriteString(c.gap)
+			c.write(str)
+		}
+		str.WriteString(n.tail)
+	}
+}
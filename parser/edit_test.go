@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEdit(t *testing.T) {
+	input := "  ab #first\r\n, cd\r\n  #last"
+	root, err := New(listRules, WithTrivia("ws")).Parse("list", input)
+	require.NoError(t, err)
+
+	tree := Edit(root)
+	require.Equal(t, input, tree.Text())
+	items := tree.FindAll("item")
+	require.Len(t, items, 2)
+
+	items[0].SetText("xyz")
+	require.Equal(t, "  xyz, cd\r\n  #last", tree.Text())
+
+	require.NoError(t, items[1].Replace(EditText("ef")))
+	require.Equal(t, "  xyz, ef", tree.Text())
+	require.Nil(t, items[1].Parent())
+
+	require.Error(t, items[1].InsertAfter(EditText("!")))
+
+	ef := tree.Children()[0].Children()[2].Children()[0].Children()[2]
+	require.Equal(t, "ef", ef.Text())
+	require.NoError(t, ef.InsertBefore(EditText("gh,")))
+	require.NoError(t, ef.InsertAfter(EditText(";")))
+	require.Equal(t, "  xyz, gh,ef;", tree.Text())
+
+	require.NoError(t, ef.Remove())
+	require.Equal(t, "  xyz, gh,;", tree.Text())
+	require.Error(t, tree.Remove())
+
+	// Untouched regions keep their original text.
+	root, err = New(listRules).Parse("list", input)
+	require.NoError(t, err)
+	tree = Edit(root)
+	tree.FindAll("comment")[0].SetText("#changed")
+	require.Equal(t, "  ab #changed\r\n, cd\r\n  #last", tree.Text())
+	key := tree.FindAll("item")[1]
+	require.NoError(t, tree.FindAll("item")[0].Replace(key))
+	require.Equal(t, "  cd\r\n  #last, ", tree.Text())
+
+	// Nodes cannot be placed within themselves.
+	tree = Edit(root)
+	item := tree.FindAll("item")[0]
+	for _, other := range []*EditNode{item, item.Parent(), tree, nil} {
+		require.Error(t, item.Replace(other))
+		require.Error(t, item.InsertBefore(other))
+		require.Error(t, item.InsertAfter(other))
+	}
+	require.Equal(t, input, tree.Text())
+	require.Nil(t, Edit(nil))
+}
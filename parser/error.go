@@ -41,3 +41,42 @@ func Error(cur *Cursor, format string, args ...interface{}) *ParseError {
 		Errors:   nil,
 	}
 }
+
+// LineCol returns the 1-based line and column of the rune at offset within
+// source, as used by Span and ParseError.Position. Use NewLines to look up
+// several offsets within the same source.
+func LineCol(source string, offset int) (int, int) {
+	return NewLines(source).LineCol(offset)
+}
+
+// Lines maps rune offsets within a source to lines and columns, scanning the
+// source once regardless of how many offsets are looked up.
+type Lines struct {
+	starts []int
+	length int
+}
+
+// NewLines returns the Lines of source.
+func NewLines(source string) *Lines {
+	l := &Lines{starts: []int{0}}
+	for _, r := range source {
+		l.length++
+		if r == '\n' {
+			l.starts = append(l.starts, l.length)
+		}
+	}
+	return l
+}
+
+// LineCol returns the 1-based line and column of the rune at offset, as
+// LineCol does for the whole source. Offsets past the end of the source are
+// placed right after its last rune, and negative ones at its start.
+func (l *Lines) LineCol(offset int) (int, int) {
+	if offset > l.length {
+		offset = l.length
+	} else if offset < 0 {
+		offset = 0
+	}
+	line := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset })
+	return line, offset - l.starts[line-1] + 1
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"context"
+	"unicode"
+)
+
+const dispatchTableKey = "__DISPATCHTABLE"
+
+// dispatchTable holds, for the alternations reachable from a set of rules,
+// the runes each of their alternatives may start with. It is built once by
+// New, and consulted by AlternationConsumer through the context.
+type dispatchTable map[*AlternationConsumer][]*CharClassConsumer
+
+// firstInfo describes the runes a consumer may start with. A consumer that
+// may match the empty string, or that is not understood, may be followed by
+// anything, and sets nullable or any respectively.
+type firstInfo struct {
+	ranges   []CharRange
+	nullable bool
+	any      bool
+}
+
+func (f firstInfo) union(other firstInfo) firstInfo {
+	return firstInfo{
+		ranges:   newCharClass(append(append([]CharRange(nil), f.ranges...), other.ranges...)).ranges,
+		nullable: f.nullable || other.nullable,
+		any:      f.any || other.any,
+	}
+}
+
+func (f firstInfo) equal(other firstInfo) bool {
+	if f.nullable != other.nullable || f.any != other.any || len(f.ranges) != len(other.ranges) {
+		return false
+	}
+	for i, r := range f.ranges {
+		if other.ranges[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func runeInfo(ranges ...CharRange) firstInfo {
+	return firstInfo{ranges: newCharClass(ranges).ranges}
+}
+
+// firstSets computes the FIRST set of every rule in rules.
+func firstSets(rules map[string]Consumer) map[string]firstInfo {
+	infos := map[string]firstInfo{}
+	for changed := true; changed; {
+		changed = false
+		for name, con := range rules {
+			info := firstOf(con, infos)
+			if !info.equal(infos[name]) {
+				infos[name] = info
+				changed = true
+			}
+		}
+	}
+	return infos
+}
+
+// newDispatchTable computes, from the FIRST sets of the rules in infos, those
+// of the alternatives of each alternation reachable from rules. Alternatives
+// that may match the empty string are left without a set, and are always
+// attempted.
+func newDispatchTable(rules map[string]Consumer, infos map[string]firstInfo) dispatchTable {
+	table := dispatchTable{}
+	visited := map[Consumer]bool{}
+	var visit func(con Consumer)
+	visit = func(con Consumer) {
+		if visited[con] {
+			return
+		}
+		visited[con] = true
+		if alt, ok := con.(*AlternationConsumer); ok {
+			sets := make([]*CharClassConsumer, len(alt.cons))
+			useful := false
+			for i, c := range alt.cons {
+				if info := firstOf(c, infos); !info.nullable && !info.any {
+					sets[i] = newCharClass(info.ranges)
+					useful = true
+				}
+			}
+			if useful {
+				table[alt] = sets
+			}
+		}
+		for _, c := range innerConsumers(con) {
+			visit(c)
+		}
+	}
+	for _, con := range rules {
+		visit(con)
+	}
+	return table
+}
+
+// innerConsumers returns the consumers con is made of.
+func innerConsumers(con Consumer) []Consumer {
+	switch c := con.(type) {
+	case *ConcatenationConsumer:
+		return c.cons
+	case *AlternationConsumer:
+		return c.cons
+	case *TrieConsumer:
+		return c.alts
+	case *OptionalConsumer:
+		return []Consumer{c.con}
+	case *RepetitionConsumer:
+		return []Consumer{c.con}
+	case *BlankConsumer:
+		return []Consumer{c.con}
+	case *ShapedConsumer:
+		return []Consumer{c.con}
+	case *LabelConsumer:
+		return []Consumer{c.con}
+	}
+	return nil
+}
+
+// firstOf returns the FIRST set of con, given those of the rules in infos.
+func firstOf(con Consumer, infos map[string]firstInfo) firstInfo {
+	switch c := con.(type) {
+	case *RefConsumer:
+		return infos[c.name]
+	case *LitConsumer:
+		return runeInfo(CharRange{c.lit, c.lit})
+	case *HexRangeConsumer:
+		return runeInfo(CharRange{c.from, c.to})
+	case *DecimalConsumer:
+		return runeInfo(CharRange{rune(c.v), rune(c.v)})
+	case *DecRangeConsumer:
+		return runeInfo(CharRange{rune(c.from), rune(c.to)})
+	case *CharClassConsumer:
+		return firstInfo{ranges: c.ranges}
+	case *LiteralConsumer:
+		if len(c.value) == 0 {
+			return firstInfo{nullable: true}
+		}
+		r := c.value[0]
+		if c.fold && r <= unicode.MaxASCII {
+			return runeInfo(CharRange{unicode.ToLower(r), unicode.ToLower(r)}, CharRange{unicode.ToUpper(r), unicode.ToUpper(r)})
+		}
+		return runeInfo(CharRange{r, r})
+	case *ConcatenationConsumer:
+		info := firstInfo{nullable: true}
+		for _, inner := range c.cons {
+			next := firstOf(inner, infos)
+			info = info.union(next)
+			if !next.nullable {
+				info.nullable = false
+				break
+			}
+		}
+		return info
+	case *AlternationConsumer, *TrieConsumer:
+		var info firstInfo
+		for _, inner := range innerConsumers(c) {
+			info = info.union(firstOf(inner, infos))
+		}
+		return info
+	case *OptionalConsumer:
+		info := firstOf(c.con, infos)
+		info.nullable = true
+		return info
+	case *RepetitionConsumer:
+		info := firstOf(c.con, infos)
+		if min, _ := c.bounds(); min == 0 {
+			info.nullable = true
+		}
+		return info
+	case *BlankConsumer:
+		return firstOf(c.con, infos)
+	case *ShapedConsumer:
+		return firstOf(c.con, infos)
+	case *LabelConsumer:
+		return firstOf(c.con, infos)
+	case *AlphaConsumer:
+		return runeInfo(CharRange{'A', 'Z'}, CharRange{'a', 'z'})
+	case *BitConsumer:
+		return runeInfo(CharRange{'0', '1'})
+	case *CharConsumer:
+		return runeInfo(CharRange{0x01, 0x01}, CharRange{0x7F, unicode.MaxRune})
+	case *CRConsumer:
+		return runeInfo(CharRange{0x0D, 0x0D})
+	case *LFConsumer:
+		return runeInfo(CharRange{0x0A, 0x0A})
+	case *DigitConsumer:
+		return runeInfo(CharRange{'0', '9'})
+	case *DQuoteConsumer:
+		return runeInfo(CharRange{'"', '"'})
+	case *HTabConsumer:
+		return runeInfo(CharRange{0x09, 0x09})
+	case *OctetConsumer:
+		return runeInfo(CharRange{0, unicode.MaxRune})
+	case *SPConsumer:
+		return runeInfo(CharRange{0x20, 0x20})
+	case *VCharConsumer:
+		return runeInfo(CharRange{0x21, 0x7E})
+	}
+	// CTL also matches at the end of the input, and other consumers are not
+	// known to start with anything in particular.
+	return firstInfo{any: true}
+}
+
+// dispatchSets returns the FIRST sets of the alternatives of a, or nil when
+// the parser did not compute them.
+func dispatchSets(ctx context.Context, a *AlternationConsumer) []*CharClassConsumer {
+	if t, ok := ctx.Value(dispatchTableKey).(dispatchTable); ok {
+		return t[a]
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+)
+
+const memoTableKey = "__MEMOTABLE"
+
+// TextEdit describes a change to the input of a tree: the runes in
+// [Start, OldEnd) are replaced by NewText.
+type TextEdit struct {
+	Start   int
+	OldEnd  int
+	NewText string
+}
+
+type memoRef struct {
+	rule string
+	pos  int
+}
+
+// memoEntry holds the outcome of a rule called at a given position. end and
+// examined are positions in the current input, while the positions within
+// atom and err are off by shift, as entries are carried over from previous
+// inputs without touching their trees.
+type memoEntry struct {
+	atom     Atom
+	err      *ParseError
+	end      int
+	examined int
+	shift    int
+}
+
+type memoTable struct {
+	entries map[memoRef]*memoEntry
+	// hits counts how many rule calls were answered from the table.
+	hits int
+}
+
+func newMemoTable() *memoTable {
+	return &memoTable{entries: map[memoRef]*memoEntry{}}
+}
+
+func (m *memoTable) consume(ctx context.Context, o RefConsumer, c *Cursor) (Atom, error) {
+	key := memoRef{rule: o.name, pos: c.pos}
+	if e, ok := m.entries[key]; ok {
+		m.hits++
+		if c.examined != nil && e.examined > *c.examined {
+			*c.examined = e.examined
+		}
+		if e.err != nil {
+			return nil, shiftError(*e.err, e.shift)
+		}
+		c.pos = e.end
+		return cloneAtom(e.atom, e.shift), nil
+	}
+
+	if c.examined == nil {
+		return o.consume(ctx, c)
+	}
+	outer := *c.examined
+	*c.examined = c.pos
+	atom, err := o.consume(ctx, c)
+	e := &memoEntry{atom: atom, end: c.pos, examined: *c.examined}
+	if err != nil {
+		pe := *err.(*ParseError)
+		e.err = &pe
+	}
+	m.entries[key] = e
+	if outer > *c.examined {
+		*c.examined = outer
+	}
+	return atom, err
+}
+
+// rebase returns a table for the input resulting from edit, holding the
+// entries that did not look at any of the replaced runes.
+func (m *memoTable) rebase(edit TextEdit) *memoTable {
+	delta := len([]rune(edit.NewText)) - (edit.OldEnd - edit.Start)
+	result := newMemoTable()
+	for key, e := range m.entries {
+		if l := linkOf(e.atom); l != nil && l.memo != nil {
+			// Roots are never reused, and keeping them would keep every
+			// previous table alive.
+			continue
+		}
+		switch {
+		case e.examined < edit.Start:
+			result.entries[key] = e
+		case key.pos+1 >= edit.OldEnd:
+			key.pos += delta
+			result.entries[key] = &memoEntry{
+				atom:     e.atom,
+				err:      e.err,
+				end:      e.end + delta,
+				examined: e.examined + delta,
+				shift:    e.shift + delta,
+			}
+		}
+	}
+	return result
+}
+
+// Reparse parses the input of old, a tree returned by Parse or Reparse, after
+// applying edit to it. With WithIncremental, rules whose results did not
+// depend on the edited region are not parsed again, but copied from old with
+// their spans moved. Otherwise, the new input is parsed from scratch. Either
+// way, the resulting tree is identical to the one returned by Parse.
+//
+// As atoms hold their parent and their absolute span, the results reused are
+// copied into the new tree rather than shared with old. Reparse thus saves the
+// cost of matching rules again, but still takes time proportional to the size
+// of the resulting tree, as Parse does.
+func (p *Parser) Reparse(old Atom, edit TextEdit) (Atom, error) {
+	ref, ok := old.(RefResult)
+	src := sourceOf(old)
+	if !ok || src == nil || old.Parent() != nil {
+		return nil, fmt.Errorf("reparse requires the root of a parsed tree")
+	}
+	if edit.Start < 0 || edit.Start > edit.OldEnd || edit.OldEnd > len(src) {
+		return nil, fmt.Errorf("invalid edit [%d, %d) for an input of length %d", edit.Start, edit.OldEnd, len(src))
+	}
+
+	input := string(src[:edit.Start]) + edit.NewText + string(src[edit.OldEnd:])
+	if !p.incremental {
+		return p.parse(ref.Name, input, nil)
+	}
+	memo := newMemoTable()
+	if l := linkOf(old); l.memo != nil {
+		memo = l.memo.rebase(edit)
+	}
+	return p.parse(ref.Name, input, memo)
+}
+
+func shiftError(err ParseError, shift int) *ParseError {
+	err.Position += shift
+	if err.Errors != nil {
+		errs := make(ParseErrors, len(err.Errors))
+		for i, e := range err.Errors {
+			errs[i] = *shiftError(e, shift)
+		}
+		err.Errors = errs
+	}
+	return &err
+}
+
+// cloneAtom returns a copy of the tree rooted at a with fresh links, moving
+// every span by shift. Its cost is proportional to the size of the tree, and
+// is paid on every use of a memo entry: trees cannot be shared, as each atom
+// links to its parent.
+func cloneAtom(a Atom, shift int) Atom {
+	if a == nil {
+		return nil
+	}
+	var l *link
+	if old := linkOf(a); old != nil {
+		l = &link{index: -1, span: Span{Start: old.span.Start + shift, End: old.span.End + shift}}
+	}
+	switch v := a.(type) {
+	case Alpha:
+		v.link = l
+		return v
+	case Bit:
+		v.link = l
+		return v
+	case Char:
+		v.link = l
+		return v
+	case CRVal:
+		v.link = l
+		return v
+	case LFVal:
+		v.link = l
+		return v
+	case Ctl:
+		v.link = l
+		return v
+	case Digit:
+		v.link = l
+		return v
+	case DQuote:
+		v.link = l
+		return v
+	case HTab:
+		v.link = l
+		return v
+	case Octet:
+		v.link = l
+		return v
+	case SPVal:
+		v.link = l
+		return v
+	case VChar:
+		v.link = l
+		return v
+	case TokenVal:
+		v.link = l
+		return v
+	case OptionVal:
+		v.link = l
+		v.value = cloneAtom(v.value, shift)
+		adopt(v, v.Children())
+		return v
+	case AtomList:
+		v.link = l
+		if v.value != nil {
+			items := make([]Atom, len(v.value))
+			for i, c := range v.value {
+				items[i] = cloneAtom(c, shift)
+			}
+			v.value = items
+		}
+		adopt(v, v.Children())
+		return v
+	case RefResult:
+		v.link = l
+		v.value = cloneAtom(v.value, shift)
+		adopt(v, v.Children())
+		return v
+	}
+	return a
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var exprRules = MakeRules(map[string]Consumer{
+	"expr":   Cat(Ref("term"), Star(Cat(Alt(Lit('+'), Lit('-')), Ref("term")))),
+	"term":   Cat(Ref("factor"), Star(Cat(Lit('*'), Ref("factor")))),
+	"factor": Cat(Star(SP), Alt(Ref("number"), Ref("name"), Cat(Lit('('), Ref("expr"), Lit(')'))), Star(SP)),
+	"number": Plus(DIGIT),
+	"name":   Plus(ALPHA),
+})
+
+func randomExpr(r *rand.Rand, depth int) string {
+	var factor string
+	switch n := r.Intn(3); {
+	case n == 0 && depth < 4:
+		factor = "(" + randomExpr(r, depth+1) + ")"
+	case n == 1:
+		factor = strings.Repeat("ab", 1+r.Intn(2))
+	default:
+		factor = strings.Repeat("7", 1+r.Intn(3))
+	}
+	if r.Intn(3) == 0 {
+		factor = " " + factor
+	}
+	if r.Intn(2) == 0 && depth < 6 {
+		return factor + string("+-*"[r.Intn(3)]) + randomExpr(r, depth+1)
+	}
+	return factor
+}
+
+func randomEdit(r *rand.Rand, input string) TextEdit {
+	size := len([]rune(input))
+	start := r.Intn(size + 1)
+	end := start + r.Intn(min(3, size-start)+1)
+	text := strings.Builder{}
+	for i := r.Intn(3); i > 0; i-- {
+		text.WriteByte("0123456789ab+-*() "[r.Intn(18)])
+	}
+	return TextEdit{Start: start, OldEnd: end, NewText: text.String()}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// requireSameTree requires got to hold the same atoms as want, as sameTree
+// reports, along with the same spans, indices and parents.
+func requireSameTree(t *testing.T, want, got Atom, msg string) {
+	require.True(t, sameTree(want, got), msg)
+	var walk func(w, g Atom)
+	walk = func(w, g Atom) {
+		require.Equal(t, w.Span(), g.Span(), msg)
+		require.Equal(t, w.Index(), g.Index(), msg)
+		gc := g.Children()
+		for i, c := range w.Children() {
+			require.Equal(t, g.Span(), gc[i].Parent().Span(), msg)
+			walk(c, gc[i])
+		}
+	}
+	walk(want, got)
+}
+
+func TestReparse(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for _, c := range []struct {
+		rules map[string]Consumer
+		rule  string
+		opts  []ParserOption
+		input func() string
+	}{
+		{exprRules, "expr", nil, func() string { return randomExpr(r, 0) }},
+		{listRules, "list", []ParserOption{WithTrivia("ws")}, func() string { return "  ab #first\r\n, cd\r\n  #last" }},
+	} {
+		p := New(c.rules, append(c.opts, WithIncremental())...)
+		plain := New(c.rules, c.opts...)
+		hits := 0
+		for i := 0; i < 50; i++ {
+			input := c.input()
+			tree, err := p.Parse(c.rule, input)
+			require.NoError(t, err, input)
+			for j := 0; j < 20; j++ {
+				edit := randomEdit(r, Source(tree))
+				src := []rune(Source(tree))
+				edited := string(src[:edit.Start]) + edit.NewText + string(src[edit.OldEnd:])
+
+				full, fullErr := plain.Parse(c.rule, edited)
+				inc, incErr := p.Reparse(tree, edit)
+				if fullErr != nil {
+					require.Error(t, incErr, edited)
+					require.Equal(t, fullErr.Error(), incErr.Error(), edited)
+					continue
+				}
+				require.Nil(t, linkOf(full).memo)
+				require.NoError(t, incErr, edited)
+				requireSameTree(t, full, inc, edited)
+				require.Equal(t, edited, Source(inc))
+				require.Equal(t, FullText(full), FullText(inc))
+				hits += linkOf(inc).memo.hits
+				tree = inc
+			}
+		}
+		require.NotZero(t, hits)
+	}
+
+	// Parsers built without WithIncremental parse edited inputs from
+	// scratch.
+	p := New(exprRules)
+	tree, err := p.Parse("expr", "1+2")
+	require.NoError(t, err)
+	edited, err := p.Reparse(tree, TextEdit{Start: 1, OldEnd: 2, NewText: "*"})
+	require.NoError(t, err)
+	require.Equal(t, "1*2", Source(edited))
+	require.Nil(t, linkOf(edited).memo)
+	_, err = p.Reparse(tree, TextEdit{Start: 2, OldEnd: 4})
+	require.Error(t, err)
+	_, err = p.Reparse(tree.Children()[0], TextEdit{})
+	require.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LiteralConsumer matches a string as a whole. It produces the same tree as
+// Str, or StrFold when folding case, without going through a consumer per
+// character.
+type LiteralConsumer struct {
+	value []rune
+	fold  bool
+}
+
+func Literal(val string) *LiteralConsumer { return &LiteralConsumer{value: []rune(val)} }
+
+// LiteralFold is like Literal, but matches ASCII letters regardless of their
+// case, as StrFold does.
+func LiteralFold(val string) *LiteralConsumer {
+	return &LiteralConsumer{value: []rune(val), fold: true}
+}
+
+func (l LiteralConsumer) Name() string {
+	if l.fold {
+		return fmt.Sprintf("LITERALFOLD(%s)", string(l.value))
+	}
+	return fmt.Sprintf("LITERAL(%s)", string(l.value))
+}
+func (l LiteralConsumer) String() string { return fmt.Sprintf("%q", string(l.value)) }
+func (LiteralConsumer) Weight() int      { return 1 }
+func (l LiteralConsumer) TryConsume(ctx context.Context, cur *Cursor) (Atom, error) {
+	ret := AtomList{link: newLink(cur)}
+	cd := cur.dup()
+	results := make([]Atom, 0, len(l.value))
+	for _, r := range l.value {
+		ok, v := cd.TryPeek()
+		if !ok {
+			return nil, Error(&cd, "Expected a literal %q, found EOF", r)
+		}
+		if !l.matches(r, v) {
+			return nil, Error(&cd, "Expected a literal %q, found %q instead", r, v)
+		}
+		cd.Consume()
+		results = append(results, Char{value: string(v), link: terminalLink(&cd)})
+	}
+
+	ret.value = results
+	ret.finish(&cd)
+	adopt(ret, results)
+	cur.Merge(cd)
+	return ret, nil
+}
+
+func (l LiteralConsumer) matches(r, v rune) bool {
+	return r == v || (l.fold && r <= unicode.MaxASCII && (v == unicode.ToLower(r) || v == unicode.ToUpper(r)))
+}
+
+// TrieConsumer matches one of a set of literals by walking a trie of them,
+// instead of trying each one in turn. It picks among the literals matching
+// the input the same way Alt would, and produces the same tree.
+type TrieConsumer struct {
+	alts []Consumer
+	root *trieNode
+	// others holds the indexes of the alternatives that are not literals,
+	// which are attempted regardless of the input.
+	others []int
+}
+
+type trieNode struct {
+	next map[rune]*trieNode
+	// ends holds the indexes of the alternatives ending at this node.
+	ends []int
+}
+
+// Trie returns a consumer equivalent to Alt(alternatives...). Alternatives
+// that are neither a LitConsumer nor a LiteralConsumer are left out of the
+// trie, and attempted in turn along with the literals matching the input.
+func Trie(alternatives ...Consumer) *TrieConsumer {
+	t := &TrieConsumer{alts: alternatives, root: &trieNode{}}
+	for i, alt := range alternatives {
+		var value []rune
+		switch a := alt.(type) {
+		case *LitConsumer:
+			value = []rune{a.lit}
+		case *LiteralConsumer:
+			value = a.value
+		default:
+			t.others = append(t.others, i)
+			continue
+		}
+		node := t.root
+		for _, r := range value {
+			key := trieKey(r)
+			if node.next == nil {
+				node.next = map[rune]*trieNode{}
+			}
+			if node.next[key] == nil {
+				node.next[key] = &trieNode{}
+			}
+			node = node.next[key]
+		}
+		node.ends = append(node.ends, i)
+	}
+	return t
+}
+
+// trieKey folds ASCII letters, so that folding literals share their nodes
+// with the others. Candidates are then checked against their own literal.
+func trieKey(r rune) rune {
+	if r <= unicode.MaxASCII {
+		return unicode.ToLower(r)
+	}
+	return r
+}
+
+func (t TrieConsumer) Name() string { return "TRIE" }
+func (TrieConsumer) Weight() int    { return 1 }
+func (t TrieConsumer) String() string {
+	str := make([]string, len(t.alts))
+	for i, c := range t.alts {
+		str[i] = c.String()
+	}
+	return fmt.Sprintf("( %s )", strings.Join(str, " / "))
+}
+func (t TrieConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+	var candidates []int
+	cd := c.dup()
+	for node := t.root; node != nil; {
+		candidates = append(candidates, node.ends...)
+		if node.next == nil {
+			break
+		}
+		ok, v := cd.TryPeek()
+		if !ok {
+			break
+		}
+		node = node.next[trieKey(v)]
+		cd.Consume()
+	}
+
+	// Like Alt, prefer the first match weighing the most.
+	candidates = append(candidates, t.others...)
+	sort.Ints(candidates)
+	best := -1
+	var result Atom
+	var end Cursor
+	for _, i := range candidates {
+		if best >= 0 && t.alts[i].Weight() <= t.alts[best].Weight() {
+			continue
+		}
+		cd := c.dup()
+		if v, err := t.alts[i].TryConsume(ctx, &cd); err == nil {
+			best, result, end = i, v, cd
+		}
+	}
+	if best < 0 {
+		return nil, Error(c, "Expected one of %s", t.String())
+	}
+	c.Merge(end)
+	return result, nil
+}
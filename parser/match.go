@@ -0,0 +1,217 @@
+package parser
+
+import "context"
+
+// Matches reports whether a is a tree con could have produced, looking up the
+// rules it refers to in rules. Terminals are checked against the runes they
+// accept, and tokens are parsed again.
+func Matches(rules map[string]Consumer, con Consumer, a Atom) bool {
+	return matcher{rules}.match(con, a)
+}
+
+// Alternative returns the index of the alternative of alt that produced a,
+// or -1 if none of them could have. When several could, the one alt prefers
+// is returned, as it is the one picked while parsing.
+func Alternative(rules map[string]Consumer, alt *AlternationConsumer, a Atom) int {
+	m := matcher{rules}
+	found := -1
+	for i, c := range alt.cons {
+		if (found < 0 || c.Weight() > alt.cons[found].Weight()) && m.match(c, a) {
+			found = i
+		}
+	}
+	return found
+}
+
+type matcher struct {
+	rules map[string]Consumer
+}
+
+// arity returns how many atoms con adds to the lists holding it: none when
+// its results are suppressed, and -1 when they are inlined, as any number of
+// them may be spliced in.
+func (m matcher) arity(con Consumer) int {
+	switch c := con.(type) {
+	case *RefConsumer:
+		if s, ok := m.rules[c.name].(*ShapedConsumer); ok {
+			return m.arity(s)
+		}
+	case *ShapedConsumer:
+		switch c.shape {
+		case ShapeSuppress:
+			return 0
+		case ShapeInline:
+			return -1
+		}
+	case *BlankConsumer:
+		return 0
+	case *LabelConsumer:
+		return m.arity(c.con)
+	}
+	return 1
+}
+
+// sequence reports whether atoms are the results of cons, in order.
+func (m matcher) sequence(cons []Consumer, atoms []Atom) bool {
+	if len(cons) == 0 {
+		return len(atoms) == 0
+	}
+	switch m.arity(cons[0]) {
+	case 0:
+		return m.sequence(cons[1:], atoms)
+	case 1:
+		return len(atoms) > 0 && m.match(cons[0], atoms[0]) && m.sequence(cons[1:], atoms[1:])
+	}
+	if len(atoms) > 0 && m.match(cons[0], atoms[0]) && m.sequence(cons[1:], atoms[1:]) {
+		return true
+	}
+	for n := 0; n <= len(atoms); n++ {
+		if m.match(cons[0], AtomList{value: atoms[:n]}) && m.sequence(cons[1:], atoms[n:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m matcher) match(con Consumer, a Atom) bool {
+	switch c := con.(type) {
+	case *RefConsumer:
+		target, ok := m.rules[c.name]
+		if !ok {
+			return false
+		}
+		if s, ok := target.(*ShapedConsumer); ok && (s.shape == ShapeSuppress || s.shape == ShapeInline) {
+			return m.match(s, a)
+		}
+		ref, ok := a.(RefResult)
+		return ok && ref.Name == c.name
+	case *ConcatenationConsumer:
+		list, ok := a.(AtomList)
+		return ok && m.sequence(c.cons, list.value)
+	case *AlternationConsumer, *TrieConsumer:
+		for _, inner := range innerConsumers(c) {
+			if m.match(inner, a) {
+				return true
+			}
+		}
+		return false
+	case *RepetitionConsumer:
+		list, ok := a.(AtomList)
+		if !ok {
+			return false
+		}
+		if _, max := c.bounds(); max > 0 && m.arity(c.con) == 1 && len(list.value) > max {
+			return false
+		}
+		return m.repetition(c.con, list.value)
+	case *OptionalConsumer:
+		opt, ok := a.(OptionVal)
+		if !ok {
+			return false
+		}
+		return !opt.Valid || m.match(c.con, opt.value)
+	case *LiteralConsumer:
+		list, ok := a.(AtomList)
+		if !ok || len(list.value) != len(c.value) {
+			return false
+		}
+		for i, r := range c.value {
+			v := []rune(terminalText(list.value[i]))
+			if list.value[i].Kind() != KindChar || len(v) != 1 || !c.matches(r, v[0]) {
+				return false
+			}
+		}
+		return true
+	case *ShapedConsumer:
+		switch c.shape {
+		case ShapeSuppress:
+			return a == nil
+		case ShapeToken:
+			tok, ok := a.(TokenVal)
+			if !ok {
+				return false
+			}
+			ctx := context.WithValue(context.Background(), ruleMapKey, m.rules)
+			cur := CursorFromString(tok.value)
+			_, err := c.con.TryConsume(ctx, &cur)
+			return err == nil && cur.pos+1 == cur.bufLen
+		}
+		return m.match(c.con, a)
+	case *BlankConsumer:
+		return a == nil
+	case *LabelConsumer:
+		return m.match(c.con, a)
+	}
+	return m.terminal(con, a)
+}
+
+// repetition reports whether atoms are the results of any number of matches
+// of con.
+func (m matcher) repetition(con Consumer, atoms []Atom) bool {
+	switch m.arity(con) {
+	case 0:
+		return len(atoms) == 0
+	case 1:
+		for _, a := range atoms {
+			if !m.match(con, a) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(atoms) == 0 {
+		return true
+	}
+	for n := 1; n <= len(atoms); n++ {
+		if m.match(con, AtomList{value: atoms[:n]}) && m.repetition(con, atoms[n:]) {
+			return true
+		}
+	}
+	return m.match(con, atoms[0]) && m.repetition(con, atoms[1:])
+}
+
+// terminal reports whether a is the single rune matched by con.
+func (m matcher) terminal(con Consumer, a Atom) bool {
+	if a == nil {
+		return false
+	}
+	var kind AtomKind
+	switch con.(type) {
+	case *LitConsumer, *HexRangeConsumer, *DecimalConsumer, *DecRangeConsumer, *CharClassConsumer, *CharConsumer:
+		kind = KindChar
+	case *AlphaConsumer:
+		kind = KindAlpha
+	case *BitConsumer:
+		kind = KindBit
+	case *CRConsumer:
+		kind = KindCR
+	case *LFConsumer:
+		kind = KindLF
+	case *CtlConsumer:
+		kind = KindCtl
+	case *DigitConsumer:
+		kind = KindDigit
+	case *DQuoteConsumer:
+		kind = KindDQuote
+	case *HTabConsumer:
+		kind = KindHTab
+	case *OctetConsumer:
+		kind = KindOctet
+	case *SPConsumer:
+		kind = KindSP
+	case *VCharConsumer:
+		kind = KindVChar
+	default:
+		// Consumers defined elsewhere cannot be told apart.
+		return true
+	}
+	if a.Kind() != kind {
+		return false
+	}
+	info := firstOf(con, nil)
+	if info.any {
+		return true
+	}
+	v := []rune(terminalText(a))
+	return len(v) == 1 && newCharClass(info.ranges).Contains(v[0])
+}
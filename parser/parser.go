@@ -3,6 +3,8 @@ package parser
 import (
 	"context"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type AtomKind int
@@ -23,6 +25,7 @@ const (
 	KindOption
 	KindAtomList
 	KindRefResult
+	KindToken
 )
 
 var atomKindString = map[AtomKind]string{
@@ -41,6 +44,7 @@ var atomKindString = map[AtomKind]string{
 	KindOption:    "KindOption",
 	KindAtomList:  "KindAtomList",
 	KindRefResult: "KindRefResult",
+	KindToken:     "KindToken",
 }
 
 func (a AtomKind) String() string {
@@ -51,21 +55,35 @@ type Cursor struct {
 	buffer []rune
 	bufLen int
 	pos    int
+	// examined, when set, tracks the furthest position looked at through
+	// Peek or TryPeek, which is how memoized results know which part of the
+	// input they depend on.
+	examined *int
 }
 
 func CursorFromString(data string) Cursor {
+	// The cursor moves over runes, so its length must be counted in runes
+	// rather than bytes for inputs holding multi-byte characters.
+	buffer := []rune(data)
 	return Cursor{
-		buffer: []rune(data),
-		bufLen: len(data),
+		buffer: buffer,
+		bufLen: len(buffer),
 		pos:    -1,
 	}
 }
 
 func (c Cursor) dup() Cursor {
 	return Cursor{
-		buffer: c.buffer,
-		bufLen: c.bufLen,
-		pos:    c.pos,
+		buffer:   c.buffer,
+		bufLen:   c.bufLen,
+		pos:      c.pos,
+		examined: c.examined,
+	}
+}
+
+func (c Cursor) examine() {
+	if c.examined != nil && c.pos+1 > *c.examined {
+		*c.examined = c.pos + 1
 	}
 }
 
@@ -74,6 +92,7 @@ func (c *Cursor) Merge(other Cursor) {
 }
 
 func (c Cursor) Peek() rune {
+	c.examine()
 	if c.pos+1 >= c.bufLen {
 		return 0x00
 	}
@@ -81,6 +100,7 @@ func (c Cursor) Peek() rune {
 }
 
 func (c Cursor) TryPeek() (bool, rune) {
+	c.examine()
 	if c.pos+1 >= c.bufLen {
 		return false, 0x00
 	}
@@ -112,6 +132,20 @@ func Str(val string) *ConcatenationConsumer {
 	}
 	return Cat(cons...)
 }
+
+// StrFold matches val ignoring the case of its ASCII letters.
+func StrFold(val string) *ConcatenationConsumer {
+	var cons []Consumer
+	for _, v := range val {
+		lower, upper := unicode.ToLower(v), unicode.ToUpper(v)
+		if lower == upper || v > unicode.MaxASCII {
+			cons = append(cons, Lit(v))
+		} else {
+			cons = append(cons, Alt(Lit(lower), Lit(upper)))
+		}
+	}
+	return Cat(cons...)
+}
 func Dec(i int) *DecimalConsumer              { return &DecimalConsumer{i} }
 func DecRange(from, to int) *DecRangeConsumer { return &DecRangeConsumer{from, to} }
 func Star(con Consumer) *RepetitionConsumer {
@@ -196,7 +230,151 @@ func MakeRules(m map[string]Consumer) map[string]Consumer {
 const ruleMapKey = "__RULEMAP"
 
 func KickoffParser(cur *Cursor, parser map[string]Consumer, initialRule string) (Atom, error) {
-	startAt := Ref(initialRule)
 	ctx := context.WithValue(context.Background(), ruleMapKey, parser)
-	return startAt.TryConsume(ctx, cur)
+	return kickoff(ctx, cur, initialRule)
+}
+
+func kickoff(ctx context.Context, cur *Cursor, initialRule string) (Atom, error) {
+	startAt := Ref(initialRule)
+	var res Atom
+	var err error
+	if s, ok := ConsumerByRef(ctx, startAt.name).(*ShapedConsumer); ok && (s.shape == ShapeSuppress || s.shape == ShapeInline) {
+		// Those shapes change how a rule appears within its parent, which
+		// the start rule lacks, so that it is wrapped like other rules.
+		res, err = startAt.wrap(ctx, cur, s.con)
+	} else {
+		res, err = startAt.TryConsume(ctx, cur)
+	}
+	if l := linkOf(res); l != nil {
+		l.source = &cur.buffer
+	}
+	return res, err
+}
+
+// parseFully parses text as rule, requiring the whole text to be consumed.
+func parseFully(rules map[string]Consumer, rule, text string) (Atom, error) {
+	cur := CursorFromString(text)
+	tree, err := KickoffParser(&cur, rules, rule)
+	if err != nil {
+		return nil, err
+	}
+	if cur.pos+1 != cur.bufLen {
+		return nil, Error(&cur, "Expected end of input")
+	}
+	return tree, nil
+}
+
+// Parser parses inputs using a set of rules, applying the options it was
+// built with to the resulting trees.
+type Parser struct {
+	rules    map[string]Consumer
+	trivia   map[string]bool
+	dispatch dispatchTable
+	// starts holds the runes inputs of each rule may start with, for rules
+	// not matching the empty string.
+	starts map[string]*CharClassConsumer
+	// incremental keeps the results of rules along with parsed trees, for
+	// use by Reparse.
+	incremental bool
+}
+
+// ParserOption configures a Parser built by New.
+type ParserOption func(*Parser)
+
+// WithTrivia enables the lossless mode, where the given rules are removed
+// from parsed trees and attached as trivia to the neighbouring terminals.
+// See LeadingTrivia and TrailingTrivia.
+func WithTrivia(rules ...string) ParserOption {
+	return func(p *Parser) {
+		for _, r := range rules {
+			p.trivia[strings.ToLower(r)] = true
+		}
+	}
+}
+
+// WithIncremental keeps the results of every rule along with the trees
+// returned by Parse and Reparse, so that Reparse only parses again the rules
+// affected by an edit. This costs memory for as long as the trees are kept,
+// along with some time while parsing.
+func WithIncremental() ParserOption {
+	return func(p *Parser) {
+		p.incremental = true
+	}
+}
+
+func New(rules map[string]Consumer, opts ...ParserOption) *Parser {
+	infos := firstSets(rules)
+	p := &Parser{rules: rules, trivia: map[string]bool{}, dispatch: newDispatchTable(rules, infos), starts: map[string]*CharClassConsumer{}}
+	for name, info := range infos {
+		if !info.nullable && !info.any {
+			p.starts[name] = newCharClass(info.ranges)
+		}
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+func (p *Parser) Rules() map[string]Consumer { return p.rules }
+
+// Parse parses input as rule, requiring the whole input to be consumed. The
+// tree returned is a RefResult for rule, even when rule is suppressed or
+// inlined, as those shapes only apply within other rules.
+func (p *Parser) Parse(rule, input string) (Atom, error) {
+	var memo *memoTable
+	if p.incremental {
+		memo = newMemoTable()
+	}
+	return p.parse(strings.ToLower(rule), input, memo)
+}
+
+// Match reports whether the whole of input matches rule, as Parse would,
+// without building a tree: inputs are only recognised, allocating neither
+// atoms nor errors. Inputs whose first rune cannot start rule are rejected
+// without being looked at any further.
+func (p *Parser) Match(rule, input string) bool {
+	rule = strings.ToLower(rule)
+	if class, ok := p.starts[rule]; ok {
+		if r, size := utf8.DecodeRuneInString(input); size == 0 || !class.Contains(r) {
+			return false
+		}
+	}
+	cur := CursorFromString(input)
+	ctx := context.WithValue(context.Background(), ruleMapKey, p.rules)
+	ctx = context.WithValue(ctx, dispatchTableKey, p.dispatch)
+	target, ok := p.rules[rule]
+	if !ok {
+		return false
+	}
+	r := recognizer{ctx: ctx, rules: p.rules, dispatch: p.dispatch}
+	end, ok := r.recognize(target, cur)
+	return ok && end.pos+1 == end.bufLen
+}
+
+// parse parses input as rule, reusing and recording results of rules in
+// memo, when given.
+func (p *Parser) parse(rule, input string, memo *memoTable) (Atom, error) {
+	cur := CursorFromString(input)
+	ctx := context.WithValue(context.Background(), ruleMapKey, p.rules)
+	if memo != nil {
+		examined := -1
+		cur.examined = &examined
+		ctx = context.WithValue(ctx, memoTableKey, memo)
+	}
+	ctx = context.WithValue(ctx, dispatchTableKey, p.dispatch)
+	tree, err := kickoff(ctx, &cur, rule)
+	if err != nil {
+		return nil, err
+	}
+	if cur.pos+1 != cur.bufLen {
+		return nil, Error(&cur, "Expected end of input")
+	}
+	if l := linkOf(tree); l != nil && memo != nil {
+		l.memo = memo
+	}
+	if len(p.trivia) > 0 {
+		tree = attachTrivia(tree, p.trivia)
+	}
+	return tree, nil
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -28,3 +29,484 @@ func TestStar(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(v)
 }
+
+func TestCursorFromStringRunes(t *testing.T) {
+	c := CursorFromString("é1")
+	v, err := Plus(OCTET).TryConsume(context.Background(), &c)
+	require.NoError(t, err)
+	require.Len(t, v.Children(), 2)
+	ok, _ := c.TryPeek()
+	require.False(t, ok)
+}
+
+var pairRules = MakeRules(map[string]Consumer{
+	"pairs": Cat(Ref("pair"), Star(Cat(Lit(','), Opt(SP), Ref("pair")))),
+	"pair":  Cat(Ref("key"), Lit('='), Ref("value")),
+	"key":   Plus(ALPHA),
+	"value": Plus(DIGIT),
+})
+
+func parsePairs(t *testing.T, rule, input string) Atom {
+	c := CursorFromString(input)
+	root, err := KickoffParser(&c, pairRules, rule)
+	require.NoError(t, err)
+	return root
+}
+
+func TestParentLinks(t *testing.T) {
+	root := parsePairs(t, "pair", "ab=12")
+
+	list := root.Children()[0]
+	require.Len(t, list.Children(), 3)
+	key, eq, value := list.Children()[0], list.Children()[1], list.Children()[2]
+
+	require.Equal(t, "key", key.(RefResult).Name)
+	require.Equal(t, KindAtomList, key.Parent().Kind())
+	require.Equal(t, KindRefResult, key.Parent().Parent().Kind())
+	require.Equal(t, "pair", key.Parent().Parent().(RefResult).Name)
+	require.Equal(t, 3, key.Parent().(AtomList).Len())
+
+	require.Equal(t, 1, eq.Index())
+	require.Equal(t, "=", eq.Value())
+	require.Equal(t, key, eq.PrevSibling())
+	require.Equal(t, value, eq.NextSibling())
+	require.Nil(t, value.NextSibling())
+	require.Nil(t, key.PrevSibling())
+	require.Nil(t, root.Parent())
+	require.Equal(t, -1, root.Index())
+
+	digit := value.Children()[0].Children()[1]
+	require.Equal(t, "2", digit.Value())
+	require.Equal(t, []string{"pair", "value"}, digit.Path())
+	require.Equal(t, []string{"pair", "value"}, value.Path())
+	require.Equal(t, []string{"pair"}, root.Path())
+}
+
+func TestWalk(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=23")
+	var events []string
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		ref, ok := a.(RefResult)
+		if !ok {
+			return WalkContinue
+		}
+		if enter {
+			events = append(events, "+"+ref.Name)
+			if ref.Name == "key" {
+				return WalkSkipChildren
+			}
+			if ref.Name == "value" && Text(ref) == "23" {
+				return WalkStop
+			}
+		} else {
+			events = append(events, "-"+ref.Name)
+		}
+		return WalkContinue
+	})
+	require.Equal(t, []string{
+		"+pairs", "+pair", "+key", "-key", "+value", "-value", "-pair",
+		"+pair", "+key", "-key", "+value",
+	}, events)
+}
+
+type digitCounter struct {
+	BaseVisitor
+	digits int
+}
+
+func (d *digitCounter) VisitDigit(Digit) WalkAction { d.digits++; return WalkContinue }
+
+func TestVisitFindAndText(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=23,d=456")
+	require.Equal(t, "a=1, bc=23,d=456", Text(root))
+
+	v := &digitCounter{}
+	Visit(root, v)
+	require.Equal(t, 6, v.digits)
+
+	var keys []string
+	for _, k := range FindAll(root, "key") {
+		keys = append(keys, Text(k))
+	}
+	require.Equal(t, []string{"a", "bc", "d"}, keys)
+
+	first, ok := FindFirst(root, "value")
+	require.True(t, ok)
+	require.Equal(t, "1", Text(first))
+	_, ok = FindFirst(root, "missing")
+	require.False(t, ok)
+}
+
+func TestQuery(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=23,d=456")
+	texts := func(q string) []string {
+		var result []string
+		for _, r := range MustCompileQuery(q).Select(root) {
+			result = append(result, Text(r))
+		}
+		return result
+	}
+
+	require.Equal(t, []string{"a", "bc", "d"}, texts("key"))
+	require.Equal(t, []string{"a", "bc", "d"}, texts("pairs pair > key"))
+	require.Equal(t, []string{"a", "bc", "d"}, texts("//pair/key"))
+	require.Equal(t, []string{"a"}, texts("/pairs/pair:first/key"))
+	require.Equal(t, []string{"456"}, texts("pairs > pair:last value"))
+	require.Equal(t, []string{"bc=23"}, texts("pairs > pair:nth(1)"))
+	require.Equal(t, []string{"bc", "23"}, texts("pair:nth(1) > *"))
+	require.Nil(t, texts("/pair"))
+	require.Nil(t, texts("pairs > key"))
+
+	first, ok := MustCompileQuery("value").First(root)
+	require.True(t, ok)
+	require.Equal(t, "1", Text(first))
+
+	for _, q := range []string{"", "pair >", "pair:nope", "pair:nth(x)", "a*b", "pair !"} {
+		_, err := CompileQuery(q)
+		require.Error(t, err, q)
+	}
+}
+
+func TestReduceIntoE(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=2")
+	reducers := map[string]ReducerE{
+		"pairs": func(ctx *ReducerContext) (interface{}, error) {
+			var pairs []interface{}
+			for _, pair := range FindAll(ctx.Value.(Atom), "pair") {
+				pairs = append(pairs, ctx.Reduce(pair))
+			}
+			return pairs, nil
+		},
+		"pair": func(ctx *ReducerContext) (interface{}, error) {
+			key, err := ctx.ReduceE(ctx.FindWithin("key"))
+			if err != nil {
+				return nil, err
+			}
+			return key.(string) + "=" + Text(ctx.FindWithin("value")), nil
+		},
+		"key": func(ctx *ReducerContext) (interface{}, error) {
+			_, v := ctx.ListAsString()
+			if len(v) > 1 {
+				return nil, fmt.Errorf("key %q is too long", v)
+			}
+			return v, nil
+		},
+	}
+
+	v, err := ReduceIntoE(parsePairs(t, "pairs", "a=1, b=2"), reducers)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a=1", "b=2"}, v)
+
+	_, err = ReduceIntoE(root, reducers)
+	require.Error(t, err)
+	errs := err.(ReduceErrors)
+	require.Len(t, errs, 1)
+	require.Equal(t, "key", errs[0].Rule)
+	require.Equal(t, []string{"pairs", "pair", "key"}, errs[0].Path)
+	require.Equal(t, Span{Start: 5, End: 7}, errs[0].Span)
+	require.EqualError(t, err, `pairs > pair > key: key "bc" is too long at position 5`)
+
+	legacy := AsReducersE(map[string]Reducer{
+		"pairs": func(ctx *ReducerContext) interface{} {
+			var values []interface{}
+			for _, v := range FindAll(ctx.Value.(Atom), "value") {
+				values = append(values, ctx.Reduce(v))
+			}
+			return values
+		},
+		"value": func(ctx *ReducerContext) interface{} {
+			return ctx.Value.(RefResult).Name
+		},
+	})
+	_, err = ReduceIntoE(root, legacy)
+	require.Len(t, err.(ReduceErrors), 2)
+	require.Contains(t, err.Error(), "pairs > pair > value: reducer panicked: interface conversion")
+	require.Panics(t, func() {
+		ReduceInto(root, map[string]Reducer{
+			"pairs": func(ctx *ReducerContext) interface{} { panic("boom") },
+		})
+	})
+
+	require.PanicsWithError(t, `key "bc" is too long`, func() {
+		ReduceInto(root, AsReducers(reducers))
+	})
+}
+
+func TestReduceAsE(t *testing.T) {
+	digits := AtomList{value: []Atom{Digit{value: "1"}, Digit{value: "2"}}}
+	ok, v, err := digits.ReduceAsIntE()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 12, v)
+	ok, v, err = digits.ReduceAsHexE()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 0x12, v)
+
+	ok, _, err = AtomList{}.ReduceAsIntE()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	letters := AtomList{value: []Atom{Alpha{value: "g"}}}
+	_, _, err = letters.ReduceAsIntE()
+	require.Error(t, err)
+	_, _, err = letters.ReduceAsHexE()
+	require.Error(t, err)
+	require.Panics(t, func() { letters.ReduceAsHex() })
+
+	_, err = AtomList{value: []Atom{CRVal{}}}.ReduceAsStringE()
+	require.EqualError(t, err, "Cannot reduce parser.CRVal as string")
+	_, err = ReReduceE(&ReducerContext{})
+	require.Error(t, err)
+}
+
+func TestReducerContext(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=23")
+	type symbols map[string]string
+
+	var seen []string
+	describe := func(ctx *ReducerContext) (interface{}, error) {
+		parent := "-"
+		if ctx.Parent() != nil {
+			parent = ctx.Parent().Rule()
+		}
+		seen = append(seen, fmt.Sprintf("%s %q %v parent=%s", ctx.Rule(), ctx.Text(), ctx.Span(), parent))
+		return ctx.Text(), nil
+	}
+	v, err := ReduceIntoE(root, map[string]ReducerE{
+		"pairs": func(ctx *ReducerContext) (interface{}, error) {
+			require.Equal(t, "a=1, bc=23", ctx.Source())
+			ctx.SetUserData(symbols{})
+			for _, pair := range FindAll(ctx.Value.(Atom), "pair") {
+				if _, err := ctx.ReduceE(pair); err != nil {
+					return nil, err
+				}
+			}
+			return ctx.UserData(), nil
+		},
+		"pair": func(ctx *ReducerContext) (interface{}, error) {
+			key, err := ctx.ReduceE(ctx.FindWithin("key"))
+			if err != nil {
+				return nil, err
+			}
+			ctx.UserData().(symbols)[key.(string)] = Text(ctx.FindWithin("value"))
+			return nil, nil
+		},
+		"key": describe,
+	})
+	require.NoError(t, err)
+	require.Equal(t, symbols{"a": "1", "bc": "23"}, v)
+	require.Equal(t, []string{
+		`key "a" {0 1} parent=pair`,
+		`key "bc" {5 7} parent=pair`,
+	}, seen)
+}
+
+func TestLabelAndStrFold(t *testing.T) {
+	rules := MakeRules(map[string]Consumer{
+		"kw":  Label("the let keyword", StrFold("let!")),
+		"kws": Plus(Cat(Ref("kw"), Opt(SP))),
+	})
+	root, err := New(rules).Parse("kws", "let! LET! LeT!")
+	require.NoError(t, err)
+	require.Len(t, FindAll(root, "kw"), 3)
+
+	_, err = New(rules).Parse("kw", "lex!")
+	require.EqualError(t, err, "Expected the let keyword at position 0")
+	require.Equal(t, 2, err.(*ParseError).Furthest().Position)
+}
+
+func TestLiteralAndTrie(t *testing.T) {
+	for _, alts := range [][]Consumer{
+		{Literal("in"), LiteralFold("int"), Lit('i'), Literal("is"), Literal("int")},
+		{Literal("in"), Plus(ALPHA), Lit('i'), Str("is"), Literal("int"), Cat(DIGIT, Lit('i'))},
+	} {
+		for _, input := range []string{"i", "in", "int", "INT", "is", "iNt", "x", "1i", "1"} {
+			want, wantErr := New(MakeRules(map[string]Consumer{"a": Alt(alts...)})).Parse("a", input)
+			got, gotErr := New(MakeRules(map[string]Consumer{"a": Trie(alts...)})).Parse("a", input)
+			require.Equal(t, wantErr == nil, gotErr == nil, input)
+			if wantErr == nil {
+				require.Equal(t, PrintTree(want), PrintTree(got), input)
+			}
+		}
+	}
+
+	lit := New(MakeRules(map[string]Consumer{"a": Literal("let")}))
+	a, err := lit.Parse("a", "let")
+	require.NoError(t, err)
+	b, err := New(MakeRules(map[string]Consumer{"a": Str("let")})).Parse("a", "let")
+	require.NoError(t, err)
+	require.Equal(t, PrintTree(b), PrintTree(a))
+	_, err = lit.Parse("a", "lex")
+	require.EqualError(t, err, "Expected a literal 't', found 'x' instead at position 2")
+}
+
+func TestCharClass(t *testing.T) {
+	hex := CharClass(CharRange{'0', '9'}, CharRange{'A', 'F'}, CharRange{'a', 'f'})
+	require.Equal(t, "( %x30-39 / %x41-46 / %x61-66 )", hex.String())
+	require.True(t, hex.Contains('b'))
+	require.False(t, hex.Contains('g'))
+
+	letters := CharClass(CharRange{'A', 'Z'}, CharRange{'a', 'z'})
+	require.Equal(t, []CharRange{{'A', 'F'}, {'a', 'f'}}, hex.Intersect(letters).Ranges())
+	require.Equal(t, []CharRange{{'0', '9'}, {'A', 'Z'}, {'a', 'z'}}, hex.Union(letters).Ranges())
+	require.Equal(t, []CharRange{{'0', '9'}, {'a', 'f'}}, Chars('a', 'b', 'c', 'd', 'e', 'f').Union(CharClass(CharRange{'0', '9'})).Ranges())
+
+	not := hex.Negate()
+	require.True(t, not.Contains('g'))
+	require.True(t, not.Contains('é'))
+	require.False(t, not.Contains('7'))
+	require.Equal(t, hex.Ranges(), not.Negate().Ranges())
+
+	for _, input := range []string{"7", "c", "x", "é", ""} {
+		want, wantErr := New(MakeRules(map[string]Consumer{"a": Alt(HexRange('0', '9'), HexRange('A', 'F'), HexRange('a', 'f'))})).Parse("a", input)
+		got, gotErr := New(MakeRules(map[string]Consumer{"a": hex})).Parse("a", input)
+		require.Equal(t, wantErr == nil, gotErr == nil, input)
+		if wantErr == nil {
+			require.Equal(t, PrintTree(want), PrintTree(got), input)
+		}
+	}
+}
+
+func BenchmarkCharClass(b *testing.B) {
+	input := strings.Repeat("The quick brown fox jumps over the lazy dog! ", 100)
+	for name, con := range map[string]Consumer{
+		"alt":       Alt(HexRange(0x20, 0x21), HexRange(0x23, 0x7E)),
+		"charclass": CharClass(CharRange{0x20, 0x21}, CharRange{0x23, 0x7E}),
+	} {
+		rules := MakeRules(map[string]Consumer{"text": Star(con)})
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := New(rules).Parse("text", input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchTable(t *testing.T) {
+	alt := Alt(Ref("pair"), Cat(Lit('#'), Ref("value")), Opt(SP), StrFold("x="), CTL)
+	rules := MakeRules(map[string]Consumer{"a": alt})
+	for k, v := range pairRules {
+		rules[k] = v
+	}
+	sets := newDispatchTable(rules, firstSets(rules))[alt]
+	require.Len(t, sets, 5)
+	require.Equal(t, "( %x41-5a / %x61-7a )", sets[0].String())
+	require.Equal(t, "( %x23 )", sets[1].String())
+	require.Nil(t, sets[2])
+	require.Equal(t, "( %x58 / %x78 )", sets[3].String())
+	require.Nil(t, sets[4])
+
+	for _, input := range []string{"ab=1", "#12", " ", "", "X=", "x", "\x01", "?"} {
+		cur := CursorFromString(input)
+		want, wantErr := KickoffParser(&cur, rules, "a")
+		if wantErr == nil && cur.pos+1 != cur.bufLen {
+			wantErr = Error(&cur, "Expected end of input")
+		}
+		got, gotErr := New(rules).Parse("a", input)
+		if wantErr != nil {
+			require.EqualError(t, gotErr, wantErr.Error(), input)
+			continue
+		}
+		require.NoError(t, gotErr, input)
+		require.Equal(t, PrintTree(want), PrintTree(got), input)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	alt := Alt(
+		Ref("num"),
+		Cat(Lit('-'), Ref("ws"), Ref("num")),
+		Cat(Ref("pair"), Ref("word")),
+		Ref("word"),
+		Cat(Lit('<'), HexRange('a', 'c'), Lit('>')),
+		Cat(Lit('<'), Ref("word"), Lit('>')),
+	)
+	rules := MakeRules(map[string]Consumer{
+		"a":    alt,
+		"num":  Plus(DIGIT),
+		"ws":   Suppress(Star(SP)),
+		"pair": Inline(Cat(ALPHA, Lit('='))),
+		"word": Token(Plus(ALPHA)),
+	})
+	for input, want := range map[string]int{"12": 0, "- 3": 1, "-3": 1, "a=bc": 2, "abc": 3, "<b>": 4, "<d>": 5} {
+		tree, err := New(rules).Parse("a", input)
+		require.NoError(t, err, input)
+		a := tree.Children()[0]
+		require.Equal(t, want, Alternative(rules, alt, a), input)
+		require.True(t, Matches(rules, alt, a), input)
+		for i, c := range alt.cons {
+			if i != want {
+				require.False(t, Matches(rules, c, a), "%s: %d", input, i)
+			}
+		}
+	}
+
+	word, err := New(rules).Parse("word", "abc")
+	require.NoError(t, err)
+	require.True(t, Matches(rules, rules["word"], word.Children()[0]))
+	require.False(t, Matches(rules, Token(Plus(DIGIT)), word.Children()[0]))
+	require.Equal(t, -1, Alternative(rules, Alt(Lit('x'), Ref("num")), word))
+}
+
+func TestParserMatch(t *testing.T) {
+	p := New(MakeRules(map[string]Consumer{
+		"num":    Plus(DIGIT),
+		"digits": Star(DIGIT),
+		"pair":   Cat(Ref("num"), Lit('='), Ref("num")),
+		"any":    Cat(Opt(SP), CTL),
+		"word":   Trie(Literal("in"), LiteralFold("int"), Lit('i'), Plus(ALPHA)),
+		"pick":   Alt(Lit('1'), Cat(DIGIT, Lit('=')), Str("1=2")),
+		"bound":  Cat(Repeat(1, 2, DIGIT), Star(ALPHA)),
+		"shaped": Token(Cat(Label("a digit", DIGIT), Inline(Opt(Lit('='))), Suppress(Star(DIGIT)))),
+		"ctl":    Cat(DIGIT, CTL),
+		"custom": Cat(LitConsumer{lit: '1'}, ALPHA),
+		"never":  Repeat(3, 2, DIGIT),
+	}))
+	rules := []string{"num", "digits", "pair", "any", "NUM", "missing", "word", "pick", "bound", "shaped", "ctl", "custom", "never"}
+	inputs := []string{"", "1", "12", "123", "1a", "a1", "1=2", "1=", " \x01", "\x01", "é", "in", "INT", "iNtx", "i", "ab"}
+	for _, rule := range rules {
+		for _, input := range inputs {
+			_, err := p.Parse(rule, input)
+			require.Equal(t, err == nil, p.Match(rule, input), "%s %q", rule, input)
+		}
+	}
+	parse := testing.AllocsPerRun(10, func() { _, _ = p.Parse("pair", "12=34") })
+	match := testing.AllocsPerRun(10, func() { p.Match("pair", "12=34") })
+	require.Less(t, match, parse)
+	require.LessOrEqual(t, match, 4.0)
+	require.Equal(t, "( %x30-39 )", p.starts["pair"].String())
+	require.Nil(t, p.starts["digits"])
+	require.Nil(t, p.starts["any"])
+}
+
+func TestDeprecatedParentContext(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, GetParent(ctx))
+	parent := &AtomList{}
+	require.Equal(t, parent, GetParent(SetParent(parent, ctx)))
+}
+
+func TestLines(t *testing.T) {
+	source := "ab\né\n\nxyz"
+	lines := NewLines(source)
+	for offset := -1; offset <= 12; offset++ {
+		line, col := lines.LineCol(offset)
+		wantLine, wantCol := 1, 1
+		for i, r := range []rune(source) {
+			if i >= offset {
+				break
+			}
+			if r == '\n' {
+				wantLine, wantCol = wantLine+1, 1
+			} else {
+				wantCol++
+			}
+		}
+		require.Equal(t, [2]int{wantLine, wantCol}, [2]int{line, col}, "offset %d", offset)
+	}
+	line, col := LineCol(source, 4)
+	require.Equal(t, [2]int{2, 2}, [2]int{line, col})
+}
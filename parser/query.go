@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled selector over RefResult names. Selectors are made of
+// steps separated by combinators:
+//
+//	rule elements          elements anywhere below a rule
+//	rule > elements        elements directly below a rule
+//	//rule/elements        XPath-like equivalent of the above
+//	/rulelist/rule         anchored at the root of the tree
+//
+// Each step is a rule name or "*", optionally followed by pseudo-classes
+// filtering the matches found under each context node: ":first", ":last"
+// and ":nth(N)" (zero-based).
+type Query struct {
+	source   string
+	anchored bool
+	steps    []queryStep
+}
+
+type queryStep struct {
+	child  bool
+	name   string
+	pseudo []queryPseudo
+}
+
+type queryPseudo struct {
+	name string
+	n    int
+}
+
+func CompileQuery(src string) (*Query, error) {
+	q := &Query{source: src}
+	l := queryLexer{src: []rune(src)}
+	l.skipSpace()
+	switch {
+	case l.accept("//"):
+	case l.accept("/"):
+		q.anchored = true
+	}
+
+	child := false
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return nil, l.error("Expected a rule name, found end of query")
+		}
+		step, err := l.step()
+		if err != nil {
+			return nil, err
+		}
+		step.child = child
+		q.steps = append(q.steps, step)
+
+		hadSpace := l.skipSpace()
+		switch {
+		case l.eof():
+			return q, nil
+		case l.accept("//"):
+			child = false
+		case l.accept("/"), l.accept(">"):
+			child = true
+		case hadSpace:
+			child = false
+		default:
+			return nil, l.error("Unexpected %q", l.peek())
+		}
+	}
+}
+
+func MustCompileQuery(src string) *Query {
+	q, err := CompileQuery(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *Query) String() string { return q.source }
+
+// Select returns every RefResult within root matched by q, in document
+// order and without duplicates.
+func (q *Query) Select(root Atom) []RefResult {
+	context := []Atom{root}
+	for i, step := range q.steps {
+		seen := map[*link]bool{}
+		var next []Atom
+		for _, ctx := range context {
+			var candidates []RefResult
+			switch {
+			case i == 0 && q.anchored:
+				if ref, ok := ctx.(RefResult); ok {
+					candidates = []RefResult{ref}
+				}
+			case i == 0:
+				candidates = refDescendants(ctx, true)
+			case step.child:
+				candidates = refChildren(ctx)
+			default:
+				candidates = refDescendants(ctx, false)
+			}
+			for _, ref := range step.filter(candidates) {
+				if !seen[ref.link] {
+					seen[ref.link] = true
+					next = append(next, ref)
+				}
+			}
+		}
+		context = next
+	}
+
+	matched := map[*link]bool{}
+	for _, a := range context {
+		matched[a.(RefResult).link] = true
+	}
+	var result []RefResult
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if ref, ok := a.(RefResult); ok && enter && matched[ref.link] {
+			result = append(result, ref)
+		}
+		return WalkContinue
+	})
+	return result
+}
+
+// First returns the first RefResult within root matched by q.
+func (q *Query) First(root Atom) (RefResult, bool) {
+	if res := q.Select(root); len(res) > 0 {
+		return res[0], true
+	}
+	return RefResult{}, false
+}
+
+func (s queryStep) filter(candidates []RefResult) []RefResult {
+	var result []RefResult
+	for _, ref := range candidates {
+		if s.name == "*" || ref.Name == s.name {
+			result = append(result, ref)
+		}
+	}
+	for _, p := range s.pseudo {
+		if len(result) == 0 {
+			return nil
+		}
+		switch p.name {
+		case "first":
+			result = result[:1]
+		case "last":
+			result = result[len(result)-1:]
+		case "nth":
+			if p.n >= len(result) {
+				return nil
+			}
+			result = result[p.n : p.n+1]
+		}
+	}
+	return result
+}
+
+// refChildren returns the RefResults directly below a, looking through
+// lists and options.
+func refChildren(a Atom) []RefResult {
+	var result []RefResult
+	for _, c := range a.Children() {
+		Walk(c, func(a Atom, enter bool) WalkAction {
+			if ref, ok := a.(RefResult); ok && enter {
+				result = append(result, ref)
+				return WalkSkipChildren
+			}
+			return WalkContinue
+		})
+	}
+	return result
+}
+
+func refDescendants(a Atom, includeSelf bool) []RefResult {
+	var result []RefResult
+	Walk(a, func(c Atom, enter bool) WalkAction {
+		if ref, ok := c.(RefResult); ok && enter && (includeSelf || ref.link != linkOf(a)) {
+			result = append(result, ref)
+		}
+		return WalkContinue
+	})
+	return result
+}
+
+type queryLexer struct {
+	src []rune
+	pos int
+}
+
+func (l *queryLexer) eof() bool  { return l.pos >= len(l.src) }
+func (l *queryLexer) peek() rune { return l.src[l.pos] }
+
+func (l *queryLexer) error(format string, args ...interface{}) *ParseError {
+	return &ParseError{Message: fmt.Sprintf(format, args...), Position: l.pos}
+}
+
+func (l *queryLexer) skipSpace() bool {
+	start := l.pos
+	for !l.eof() && (l.peek() == ' ' || l.peek() == '\t') {
+		l.pos++
+	}
+	return l.pos != start
+}
+
+func (l *queryLexer) accept(s string) bool {
+	if strings.HasPrefix(string(l.src[l.pos:]), s) {
+		l.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+func isQueryNameRune(r rune) bool {
+	return r == '-' || r == '_' || r == '*' ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func (l *queryLexer) name() string {
+	start := l.pos
+	for !l.eof() && isQueryNameRune(l.peek()) {
+		l.pos++
+	}
+	return string(l.src[start:l.pos])
+}
+
+func (l *queryLexer) step() (queryStep, error) {
+	name := l.name()
+	if name == "" {
+		return queryStep{}, l.error("Expected a rule name, found %q", l.peek())
+	}
+	if name != "*" && strings.Contains(name, "*") {
+		return queryStep{}, l.error("Invalid rule name %q", name)
+	}
+	step := queryStep{name: strings.ToLower(name)}
+	for l.accept(":") {
+		pseudo := queryPseudo{name: l.name()}
+		switch pseudo.name {
+		case "first", "last":
+		case "nth":
+			if !l.accept("(") {
+				return queryStep{}, l.error("Expected '(' after :nth")
+			}
+			start := l.pos
+			for !l.eof() && l.peek() >= '0' && l.peek() <= '9' {
+				l.pos++
+			}
+			n, err := strconv.Atoi(string(l.src[start:l.pos]))
+			if err != nil {
+				return queryStep{}, l.error("Expected an index for :nth")
+			}
+			if !l.accept(")") {
+				return queryStep{}, l.error("Expected ')' after :nth index")
+			}
+			pseudo.n = n
+		default:
+			return queryStep{}, l.error("Unknown pseudo-class %q", pseudo.name)
+		}
+		step.pseudo = append(step.pseudo, pseudo)
+	}
+	return step, nil
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"context"
+	"sort"
+)
+
+// recognizer moves a cursor over the input the way TryConsume would, picking
+// the same alternatives and repeating consumers as many times, without
+// building atoms or reporting errors. Consumers defined elsewhere are still
+// attempted through TryConsume.
+type recognizer struct {
+	ctx      context.Context
+	rules    map[string]Consumer
+	dispatch dispatchTable
+}
+
+// recognize reports whether con matches at c, returning the cursor moved past
+// what it matched. Cursors are passed by value, which keeps them off the
+// heap.
+func (r recognizer) recognize(con Consumer, c Cursor) (Cursor, bool) {
+	switch con := con.(type) {
+	case *RefConsumer:
+		target, ok := r.rules[con.name]
+		if !ok {
+			return c, false
+		}
+		return r.recognize(target, c)
+	case *ConcatenationConsumer:
+		cd := c
+		for _, inner := range con.cons {
+			var ok bool
+			if cd, ok = r.recognize(inner, cd); !ok {
+				return c, false
+			}
+		}
+		return cd, true
+	case *AlternationConsumer:
+		return r.alternation(con, c)
+	case *TrieConsumer:
+		return r.trie(con, c)
+	case *RepetitionConsumer:
+		return r.repetition(con, c)
+	case *OptionalConsumer:
+		if cd, ok := r.recognize(con.con, c); ok {
+			return cd, true
+		}
+		return c, true
+	case *LiteralConsumer:
+		cd := c
+		for _, l := range con.value {
+			ok, v := cd.TryPeek()
+			if !ok || !con.matches(l, v) {
+				return c, false
+			}
+			cd.Consume()
+		}
+		return cd, true
+	case *ShapedConsumer:
+		return r.recognize(con.con, c)
+	case *BlankConsumer:
+		return r.recognize(con.con, c)
+	case *LabelConsumer:
+		return r.recognize(con.con, c)
+	}
+	if matched, ok := r.terminal(con, &c); ok {
+		return c, matched
+	}
+	cd := c
+	_, err := con.TryConsume(r.ctx, &cd)
+	return cd, err == nil
+}
+
+// alternation picks the first of the alternatives weighing the most among
+// those matching, skipping the ones the next rune cannot start, as
+// AlternationConsumer does.
+func (r recognizer) alternation(a *AlternationConsumer, c Cursor) (Cursor, bool) {
+	sets := r.dispatch[a]
+	ok, next := c.TryPeek()
+	end := c
+	weight := -1
+	for i, v := range a.cons {
+		if sets != nil && sets[i] != nil && (!ok || !sets[i].Contains(next)) {
+			continue
+		}
+		if w := v.Weight(); w > weight {
+			if cd, ok := r.recognize(v, c); ok {
+				end, weight = cd, w
+			}
+		}
+	}
+	return end, weight >= 0
+}
+
+// trie attempts the literals of t found along the input, and its other
+// alternatives, picking among them as TrieConsumer does.
+func (r recognizer) trie(t *TrieConsumer, c Cursor) (Cursor, bool) {
+	candidates := append([]int(nil), t.others...)
+	cd := c
+	for node := t.root; node != nil; {
+		candidates = append(candidates, node.ends...)
+		if node.next == nil {
+			break
+		}
+		ok, v := cd.TryPeek()
+		if !ok {
+			break
+		}
+		node = node.next[trieKey(v)]
+		cd.Consume()
+	}
+
+	sort.Ints(candidates)
+	best := -1
+	end := c
+	for _, i := range candidates {
+		if best >= 0 && t.alts[i].Weight() <= t.alts[best].Weight() {
+			continue
+		}
+		if cd, ok := r.recognize(t.alts[i], c); ok {
+			best, end = i, cd
+		}
+	}
+	return end, best >= 0
+}
+
+// repetition matches the consumer of rep as many times as possible, failing
+// unless rep accepts that count, as RepetitionConsumer does.
+func (r recognizer) repetition(rep *RepetitionConsumer, c Cursor) (Cursor, bool) {
+	min, max := rep.bounds()
+	if max > 0 && min > max {
+		return c, false
+	}
+	count := 0
+	cd := c
+	for {
+		if rep.mode == RepeatStar {
+			if ok, _ := cd.TryPeek(); !ok {
+				break
+			}
+		}
+		next, ok := r.recognize(rep.con, cd)
+		if !ok {
+			break
+		}
+		count++
+		if next.pos == cd.pos {
+			if count < min {
+				count = min
+			}
+			break
+		}
+		cd = next
+	}
+	if !rep.accepts(count) {
+		return c, false
+	}
+	return cd, true
+}
+
+// terminal matches the consumers of a single rune, reporting whether con is
+// one of them. Those looking at the next rune through Peek see 0x00 at the
+// end of the input, which CTL accepts.
+func (r recognizer) terminal(con Consumer, c *Cursor) (bool, bool) {
+	ok, v := c.TryPeek()
+	var matched bool
+	switch con := con.(type) {
+	case *LitConsumer:
+		matched = ok && v == con.lit
+	case *HexRangeConsumer:
+		matched = ok && v >= con.from && v <= con.to
+	case *DecimalConsumer:
+		matched = ok && int(v) == con.v
+	case *DecRangeConsumer:
+		matched = ok && int(v) >= con.from && int(v) <= con.to
+	case *CharClassConsumer:
+		matched = ok && con.Contains(v)
+	case *OctetConsumer:
+		matched = ok
+	case *AlphaConsumer:
+		matched = (v >= 0x41 && v <= 0x5A) || (v >= 0x61 && v <= 0x7A)
+	case *BitConsumer:
+		matched = v == '0' || v == '1'
+	case *CharConsumer:
+		matched = v == 0x01 || v >= 0x7F
+	case *LFConsumer:
+		matched = v == 0x0A
+	case *CRConsumer:
+		matched = v == 0x0D
+	case *CtlConsumer:
+		matched = v <= 0x1f || v == 0x7f
+	case *DigitConsumer:
+		matched = v >= 0x30 && v <= 0x39
+	case *DQuoteConsumer:
+		matched = v == 0x22
+	case *HTabConsumer:
+		matched = v == 0x09
+	case *SPConsumer:
+		matched = v == 0x20
+	case *VCharConsumer:
+		matched = v >= 0x21 && v <= 0x7E
+	default:
+		return false, false
+	}
+	if matched {
+		c.Consume()
+	}
+	return matched, true
+}
@@ -1,9 +1,56 @@
 package parser
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 type Reducer func(ctx *ReducerContext) interface{}
 
+// ReducerE is a Reducer able to report failures through an error instead of
+// panicking.
+type ReducerE func(ctx *ReducerContext) (interface{}, error)
+
+// AsReducerE adapts a legacy Reducer into a ReducerE.
+func AsReducerE(fn Reducer) ReducerE {
+	return func(ctx *ReducerContext) (interface{}, error) {
+		return fn(ctx), nil
+	}
+}
+
+// AsReducersE adapts a map of legacy reducers for use with ReduceIntoE.
+func AsReducersE(reducers map[string]Reducer) map[string]ReducerE {
+	result := make(map[string]ReducerE, len(reducers))
+	for k, v := range reducers {
+		result[k] = AsReducerE(v)
+	}
+	return result
+}
+
+// AsReducer adapts a ReducerE into a legacy Reducer, which panics with the
+// errors fn returns.
+func AsReducer(fn ReducerE) Reducer {
+	return func(ctx *ReducerContext) interface{} {
+		v, err := fn(ctx)
+		if err != nil {
+			panic(err)
+		}
+		return v
+	}
+}
+
+// AsReducers adapts a map of error-returning reducers for use with
+// ReduceInto.
+func AsReducers(reducers map[string]ReducerE) map[string]Reducer {
+	result := make(map[string]Reducer, len(reducers))
+	for k, v := range reducers {
+		result[k] = AsReducer(v)
+	}
+	return result
+}
+
 func ReduceAsString(ctx *ReducerContext) interface{} {
 	ok, v := ctx.ListAsString()
 	if !ok {
@@ -12,15 +59,161 @@ func ReduceAsString(ctx *ReducerContext) interface{} {
 	return v
 }
 
+func ReduceAsStringE(ctx *ReducerContext) (interface{}, error) {
+	if _, ok := ctx.Value.(AtomList); !ok {
+		return nil, fmt.Errorf("cannot reduce %T as string", ctx.Value)
+	}
+	return Text(ctx.Value.(Atom)), nil
+}
+
 func ReReduce(ctx *ReducerContext) interface{} {
 	return ctx.Reduce(ctx.Value.(Atom))
 }
 
+func ReReduceE(ctx *ReducerContext) (interface{}, error) {
+	a, ok := ctx.Value.(Atom)
+	if !ok {
+		return nil, fmt.Errorf("cannot reduce %T", ctx.Value)
+	}
+	return ctx.ReduceE(a)
+}
+
+// ReduceError reports a reducer failure along with the rule being reduced,
+// the chain of rules leading to it and the span of input it matched.
+type ReduceError struct {
+	Rule string
+	Path []string
+	Span Span
+	Err  error
+}
+
+func (r *ReduceError) Error() string {
+	return fmt.Sprintf("%s: %s at position %d", strings.Join(r.Path, " > "), r.Err, r.Span.Start)
+}
+
+func (r *ReduceError) Unwrap() error { return r.Err }
+
+type ReduceErrors []*ReduceError
+
+func (r ReduceErrors) Error() string {
+	msgs := make([]string, len(r))
+	for i, e := range r {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// reduction holds the state shared by every ReducerContext of a single
+// ReduceInto or ReduceIntoE call.
+type reduction struct {
+	reducers map[string]ReducerE
+	errors   ReduceErrors
+	recover  bool
+	current  *ReducerContext
+}
+
+func (r *reduction) reduce(root Atom) (interface{}, error) {
+	switch v := root.(type) {
+	case RefResult:
+		if fn, ok := r.reducers[v.Name]; ok {
+			return r.call(fn, v)
+		}
+		return v, nil
+	case AtomList:
+		var result []interface{}
+		var firstErr error
+		for _, i := range v.value {
+			res, err := r.reduce(i)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			result = append(result, res)
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return result, nil
+	}
+	return root, nil
+}
+
+func (r *reduction) call(fn ReducerE, ref RefResult) (result interface{}, err error) {
+	before := len(r.errors)
+	defer func() {
+		if r.recover {
+			if p := recover(); p != nil {
+				result, err = nil, fmt.Errorf("reducer panicked: %v", p)
+			}
+		}
+		if err == nil {
+			return
+		}
+		if len(r.errors) > before {
+			// Failures of nested reductions were already recorded, and are
+			// likely the reason this one failed too.
+			err = r.errors[before]
+			return
+		}
+		var errs ReduceErrors
+		var rerr *ReduceError
+		if errors.As(err, &errs) {
+			r.errors = append(r.errors, errs...)
+			return
+		} else if !errors.As(err, &rerr) {
+			rerr = &ReduceError{Rule: ref.Name, Path: ref.Path(), Span: ref.Span(), Err: err}
+		}
+		r.errors = append(r.errors, rerr)
+		err = rerr
+	}()
+	ctx := &ReducerContext{red: r, parent: r.current, ref: ref, Value: ref.value}
+	r.current = ctx
+	defer func() { r.current = ctx.parent }()
+	return fn(ctx)
+}
+
 type ReducerContext struct {
-	reducers *map[string]Reducer
+	red      *reduction
+	parent   *ReducerContext
+	ref      RefResult
+	userData interface{}
 	Value    interface{}
 }
 
+// Rule returns the name of the rule being reduced.
+func (r ReducerContext) Rule() string { return r.ref.Name }
+
+// Span returns the span of input matched by the rule being reduced.
+func (r ReducerContext) Span() Span { return r.ref.Span() }
+
+// Text returns the input matched by the rule being reduced.
+func (r ReducerContext) Text() string { return Text(r.ref) }
+
+// Source returns the whole input the tree was parsed from, or an empty string
+// when it is unknown.
+func (r ReducerContext) Source() string { return Source(r.ref) }
+
+// Atom returns the RefResult being reduced.
+func (r ReducerContext) Atom() RefResult { return r.ref }
+
+// Parent returns the context of the reducer that requested this reduction,
+// or nil for the outermost one.
+func (r ReducerContext) Parent() *ReducerContext { return r.parent }
+
+// UserData returns the value set through SetUserData by this context or the
+// closest of its parents.
+func (r *ReducerContext) UserData() interface{} {
+	for c := r; c != nil; c = c.parent {
+		if c.userData != nil {
+			return c.userData
+		}
+	}
+	return nil
+}
+
+// SetUserData attaches v to this context, making it visible to every
+// reduction it requests, such as a symbol table for a scope.
+func (r *ReducerContext) SetUserData(v interface{}) { r.userData = v }
+
 func (r ReducerContext) ListAsList() *AtomList {
 	if v, ok := r.Value.(AtomList); ok {
 		return &v
@@ -42,8 +235,28 @@ func (r ReducerContext) ListAsString() (bool, string) {
 	return false, ""
 }
 
+// Reduce reduces a using the same reducers as the current context. Under
+// ReduceIntoE, failures are recorded and nil is returned.
 func (r ReducerContext) Reduce(a Atom) interface{} {
-	return ReduceInto(a, *r.reducers)
+	v, _ := r.red.reduce(a)
+	return v
+}
+
+// ReduceE reduces a using the same reducers as the current context, returning
+// any error found while doing so. Errors are only reported by ReduceIntoE if
+// the calling reducer returns them.
+func (r ReducerContext) ReduceE(a Atom) (interface{}, error) {
+	before := len(r.red.errors)
+	v, err := r.red.reduce(a)
+	if len(r.red.errors) == before {
+		return v, err
+	}
+	errs := append(ReduceErrors(nil), r.red.errors[before:]...)
+	r.red.errors = r.red.errors[:before]
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, errs
 }
 
 func (r ReducerContext) Flatten(v interface{}) interface{} {
@@ -100,6 +313,43 @@ func (r ReducerContext) Iterate(i interface{}, fn func(i interface{})) {
 	}
 }
 
+// FoldLeft reduces rules shaped as a head followed by a repetition of tails,
+// such as the ones rewritten by abnf.EliminateLeftRecursion, like
+//
+//	expr = term *( "+" term )
+//
+// The head is reduced first, then fn combines the result so far with the
+// reduced elements of each tail, in order, so that results nest as they
+// would under the original left recursive rule.
+func (r ReducerContext) FoldLeft(fn func(acc interface{}, tail []interface{}) (interface{}, error)) (interface{}, error) {
+	list := r.AtomList()
+	if len(list) != 2 {
+		return nil, fmt.Errorf("rule %s: expected a head followed by tails", r.Rule())
+	}
+	tails, ok := list[1].(AtomList)
+	if !ok {
+		return nil, fmt.Errorf("rule %s: expected a repetition of tails", r.Rule())
+	}
+	acc, err := r.ReduceE(list[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, tail := range tails.value {
+		v, err := r.ReduceE(tail)
+		if err != nil {
+			return nil, err
+		}
+		elements, ok := v.([]interface{})
+		if _, isList := tail.(AtomList); !isList || !ok {
+			elements = []interface{}{v}
+		}
+		if acc, err = fn(acc, elements); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
 func (r ReducerContext) FindWithin(name string) Atom {
 	if v := r.AtomList(); v != nil {
 		for _, val := range v {
@@ -112,21 +362,18 @@ func (r ReducerContext) FindWithin(name string) Atom {
 }
 
 func ReduceInto(root Atom, reducers map[string]Reducer) interface{} {
-	switch v := root.(type) {
-	case RefResult:
-		if fn, ok := reducers[v.Name]; ok {
-			return fn(&ReducerContext{
-				reducers: &reducers,
-				Value:    v.value,
-			})
-		}
-		return v
-	case AtomList:
-		var result []interface{}
-		for _, i := range v.value {
-			result = append(result, ReduceInto(i, reducers))
-		}
-		return result
+	v, _ := (&reduction{reducers: AsReducersE(reducers)}).reduce(root)
+	return v
+}
+
+// ReduceIntoE reduces root like ReduceInto, but collects failures instead of
+// stopping at the first one. Panics raised by reducers are recovered and
+// reported as errors. The returned error, if any, is a ReduceErrors.
+func ReduceIntoE(root Atom, reducers map[string]ReducerE) (interface{}, error) {
+	red := &reduction{reducers: reducers, recover: true}
+	v, _ := red.reduce(root)
+	if len(red.errors) > 0 {
+		return nil, red.errors
 	}
-	return root
+	return v, nil
 }
@@ -28,15 +28,33 @@ func (o RefConsumer) Name() string   { return o.name }
 func (o RefConsumer) String() string { return o.name }
 func (RefConsumer) Weight() int      { return 0 }
 func (o RefConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+	if memo, ok := ctx.Value(memoTableKey).(*memoTable); ok {
+		return memo.consume(ctx, o, c)
+	}
+	return o.consume(ctx, c)
+}
+
+func (o RefConsumer) consume(ctx context.Context, c *Cursor) (Atom, error) {
 	con := ConsumerByRef(ctx, o.name)
 	if con == nil {
 		return nil, Error(c, "unknown rule %s", o.name)
 	}
+	if s, ok := con.(*ShapedConsumer); ok && (s.shape == ShapeSuppress || s.shape == ShapeInline) {
+		return con.TryConsume(ctx, c)
+	}
+	return o.wrap(ctx, c, con)
+}
+
+// wrap returns the result of con within a RefResult for the rule o refers
+// to.
+func (o RefConsumer) wrap(ctx context.Context, c *Cursor, con Consumer) (Atom, error) {
 	cd := c.dup()
-	res := RefResult{parent: GetParent(ctx), Name: o.name}
-	if v, err := con.TryConsume(SetParent(&res, ctx), &cd); err == nil {
+	res := RefResult{link: newLink(c), Name: o.name}
+	if v, err := con.TryConsume(ctx, &cd); err == nil {
 		c.Merge(cd)
-		res.value = v
+		res.value = unspliced(v)
+		res.finish(c)
+		adopt(res, res.Children())
 		return res, nil
 	} else {
 		return nil, err
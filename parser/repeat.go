@@ -59,83 +59,80 @@ func (r RepetitionConsumer) Name() string {
 	return str.String()
 }
 
+// bounds returns the minimum and maximum number of matches accepted by r,
+// where a maximum of zero means no limit.
+func (r RepetitionConsumer) bounds() (int, int) {
+	switch r.mode {
+	case RepeatPlus:
+		return 1, 0
+	case RepeatStar:
+		return 0, 0
+	case RepeatMin:
+		return r.min, 0
+	case RepeatMinMax:
+		return r.min, r.max
+	}
+	panic("Invalid repetition mode")
+}
+
+// accepts reports whether r matches when its inner consumer matched count
+// times in a row. Repetitions are greedy: RepeatMin requires exactly min
+// matches, and RepeatMinMax fails when more than max are available.
+func (r RepetitionConsumer) accepts(count int) bool {
+	switch r.mode {
+	case RepeatPlus:
+		return count > 0
+	case RepeatMin:
+		return count == r.min
+	case RepeatMinMax:
+		return count >= r.min && count <= r.max
+	}
+	return true
+}
+
 func (r RepetitionConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
-	result := AtomList{parent: GetParent(ctx)}
+	if min, max := r.bounds(); max > 0 && min > max {
+		return nil, Error(c, "Repetition %s cannot match, as its minimum exceeds its maximum", r.String())
+	}
+	result := AtomList{link: newLink(c)}
 	var list []Atom
+	var lastErr error
+	count := 0
 	cd := c.dup()
-	switch r.mode {
-	case RepeatPlus:
-		for {
-			v, err := r.con.TryConsume(SetParent(&result, ctx), &cd)
-			if err == nil {
-				if v != nil {
-					list = append(list, v)
-				}
-				continue
+	for {
+		if r.mode == RepeatStar {
+			if ok, _ := cd.TryPeek(); !ok {
+				break
 			}
-			if len(list) > 0 {
-				c.Merge(cd)
-			} else {
-				return result, err
-			}
-			result.value = list
-			return result, nil
 		}
-	case RepeatStar:
-		moveCursor := false
-		for {
-			// Do we have CD?
-			if ok, _ := cd.TryPeek(); ok {
-				v, err := r.con.TryConsume(SetParent(&result, ctx), &cd)
-				if err == nil {
-					moveCursor = true
-					if v != nil {
-						list = append(list, v)
-					}
-					continue
-				}
-			}
-			if moveCursor {
-				c.Merge(cd)
-			}
-			result.value = list
-			return result, nil
+		pos := cd.pos
+		v, err := r.con.TryConsume(ctx, &cd)
+		if err != nil {
+			lastErr = err
+			break
 		}
-	case RepeatMin:
-		for {
-			v, err := r.con.TryConsume(SetParent(&result, ctx), &cd)
-			if err == nil {
-				if v != nil {
-					list = append(list, v)
-				}
-				continue
+		count++
+		// Results may be suppressed, so matches are counted rather than the
+		// atoms they produced.
+		list = appendResult(list, v)
+		if cd.pos == pos {
+			// Further matches would not move the cursor either, so they are
+			// all taken as done.
+			if min, _ := r.bounds(); count < min {
+				count = min
 			}
-			if len(list) == r.min {
-				c.Merge(cd)
-			} else {
-				return nil, err
-			}
-			result.value = list
-			return result, nil
+			break
 		}
-	case RepeatMinMax:
-		for {
-			v, err := r.con.TryConsume(SetParent(&result, ctx), &cd)
-			if err == nil {
-				if v != nil {
-					list = append(list, v)
-				}
-				continue
-			}
-			if len(list) >= r.min && len(list) <= r.max {
-				c.Merge(cd)
-			} else {
-				return nil, err
-			}
-			result.value = list
-			return result, nil
+	}
+	if !r.accepts(count) {
+		if lastErr == nil {
+			lastErr = Error(c, "Expected %s", r.String())
 		}
-	default:
-		panic("Invalid repetition mode")
+		return nil, lastErr
 	}
+	c.Merge(cd)
+	result.value = list
+	result.finish(c)
+	adopt(result, list)
+	return result, nil
 }
@@ -0,0 +1,372 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type SerializeOptions struct {
+	// CollapseTerminals emits lists made only of terminals as a single "text"
+	// node. Such nodes are decoded back as a list of Char atoms.
+	CollapseTerminals bool
+	// OmitEmptyOptions skips options that did not match when they appear in a
+	// list.
+	OmitEmptyOptions bool
+}
+
+const kindText = "text"
+
+var atomKindNames = map[AtomKind]string{
+	KindAlpha:     "alpha",
+	KindBit:       "bit",
+	KindChar:      "char",
+	KindCR:        "cr",
+	KindLF:        "lf",
+	KindCtl:       "ctl",
+	KindDigit:     "digit",
+	KindDQuote:    "dquote",
+	KindHTab:      "htab",
+	KindOctet:     "octet",
+	KindSP:        "sp",
+	KindVChar:     "vchar",
+	KindOption:    "option",
+	KindAtomList:  "list",
+	KindRefResult: "rule",
+	KindToken:     "token",
+}
+
+var atomKindsByName = func() map[string]AtomKind {
+	m := map[string]AtomKind{}
+	for k, v := range atomKindNames {
+		m[v] = k
+	}
+	return m
+}()
+
+// serialNode is the format-independent representation of an atom used by
+// both the JSON and S-expression encoders.
+type serialNode struct {
+	Kind     string        `json:"kind"`
+	Name     string        `json:"name,omitempty"`
+	Valid    *bool         `json:"valid,omitempty"`
+	Span     [2]int        `json:"span"`
+	Text     string        `json:"text"`
+	Children []*serialNode `json:"children,omitempty"`
+}
+
+func toSerialNode(a Atom, opts SerializeOptions) *serialNode {
+	if a == nil {
+		return nil
+	}
+	span := a.Span()
+	n := &serialNode{
+		Kind: atomKindNames[a.Kind()],
+		Span: [2]int{span.Start, span.End},
+		Text: Text(a),
+	}
+	switch v := a.(type) {
+	case RefResult:
+		n.Name = v.Name
+	case OptionVal:
+		valid := v.Valid
+		n.Valid = &valid
+	case AtomList:
+		if opts.CollapseTerminals && v.Len() > 0 && v.AllTerminals() {
+			n.Kind = kindText
+			return n
+		}
+	}
+	for _, c := range a.Children() {
+		if o, ok := c.(OptionVal); ok && opts.OmitEmptyOptions && !o.Valid && a.Kind() == KindAtomList {
+			continue
+		}
+		n.Children = append(n.Children, toSerialNode(c, opts))
+	}
+	return n
+}
+
+// decodeRoot rebuilds the tree rooted at n. When it starts at the beginning of
+// its input, the text it covers is kept as its source, as KickoffParser does.
+func decodeRoot(n *serialNode) (Atom, error) {
+	root, err := fromSerialNode(n)
+	if l := linkOf(root); err == nil && l != nil && l.span.Start == 0 {
+		src := []rune(Text(root))
+		l.source = &src
+	}
+	return root, err
+}
+
+func fromSerialNode(n *serialNode) (Atom, error) {
+	if n == nil {
+		return nil, nil
+	}
+	l := &link{index: -1, span: Span{Start: n.Span[0], End: n.Span[1]}}
+	if n.Kind == kindText {
+		list := AtomList{link: l}
+		for i, r := range []rune(n.Text) {
+			start := n.Span[0] + i
+			list.value = append(list.value, Char{
+				value: string(r),
+				link:  &link{index: -1, span: Span{Start: start, End: start + 1}},
+			})
+		}
+		adopt(list, list.value)
+		return list, nil
+	}
+
+	kind, ok := atomKindsByName[n.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown atom kind %q", n.Kind)
+	}
+	children := make([]Atom, 0, len(n.Children))
+	for _, c := range n.Children {
+		child, err := fromSerialNode(c)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	single := func() (Atom, error) {
+		switch len(children) {
+		case 0:
+			return nil, nil
+		case 1:
+			return children[0], nil
+		}
+		return nil, fmt.Errorf("%s node expects a single child, found %d", n.Kind, len(children))
+	}
+
+	var result Atom
+	switch kind {
+	case KindAlpha:
+		result = Alpha{value: n.Text, link: l}
+	case KindBit:
+		result = Bit{value: n.Text, link: l}
+	case KindChar:
+		result = Char{value: n.Text, link: l}
+	case KindCR:
+		result = CRVal{link: l}
+	case KindLF:
+		result = LFVal{link: l}
+	case KindCtl:
+		result = Ctl{value: n.Text, link: l}
+	case KindDigit:
+		result = Digit{value: n.Text, link: l}
+	case KindDQuote:
+		result = DQuote{link: l}
+	case KindHTab:
+		result = HTab{link: l}
+	case KindOctet:
+		r := []rune(n.Text)
+		if len(r) != 1 {
+			return nil, fmt.Errorf("octet node expects a single character, found %q", n.Text)
+		}
+		result = Octet{value: r[0], link: l}
+	case KindSP:
+		result = SPVal{link: l}
+	case KindVChar:
+		result = VChar{value: n.Text, link: l}
+	case KindToken:
+		result = TokenVal{value: n.Text, link: l}
+	case KindOption:
+		v, err := single()
+		if err != nil {
+			return nil, err
+		}
+		result = OptionVal{link: l, Valid: (n.Valid != nil && *n.Valid) || v != nil, value: v}
+	case KindAtomList:
+		result = AtomList{link: l, value: children}
+	case KindRefResult:
+		v, err := single()
+		if err != nil {
+			return nil, err
+		}
+		result = RefResult{link: l, Name: n.Name, value: v}
+	}
+	adopt(result, result.Children())
+	return result, nil
+}
+
+// EncodeJSON serializes the tree rooted at root as JSON. Each node holds its
+// kind, rule name (for rules), span, matched text and children.
+func EncodeJSON(root Atom, opts SerializeOptions) ([]byte, error) {
+	return json.Marshal(toSerialNode(root, opts))
+}
+
+// DecodeJSON rebuilds a tree serialized by EncodeJSON.
+func DecodeJSON(data []byte) (Atom, error) {
+	var n *serialNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return decodeRoot(n)
+}
+
+// EncodeSExpr serializes the tree rooted at root as an S-expression. Nodes are
+// written as (kind [name] start end children...), where terminal and text nodes
+// hold their quoted text as their single child, and a valid option without a
+// value holds ().
+func EncodeSExpr(root Atom, opts SerializeOptions) string {
+	sb := strings.Builder{}
+	writeSExpr(toSerialNode(root, opts), &sb)
+	return sb.String()
+}
+
+func writeSExpr(n *serialNode, sb *strings.Builder) {
+	if n == nil {
+		sb.WriteString("()")
+		return
+	}
+	sb.WriteString("(")
+	sb.WriteString(n.Kind)
+	if n.Kind == atomKindNames[KindRefResult] {
+		sb.WriteString(" ")
+		sb.WriteString(n.Name)
+	}
+	sb.WriteString(fmt.Sprintf(" %d %d", n.Span[0], n.Span[1]))
+	switch n.Kind {
+	case atomKindNames[KindOption], atomKindNames[KindAtomList], atomKindNames[KindRefResult]:
+		if n.Valid != nil && *n.Valid && len(n.Children) == 0 {
+			sb.WriteString(" ()")
+		}
+		for _, c := range n.Children {
+			sb.WriteString(" ")
+			writeSExpr(c, sb)
+		}
+	default:
+		sb.WriteString(" ")
+		sb.WriteString(strconv.Quote(n.Text))
+	}
+	sb.WriteString(")")
+}
+
+// DecodeSExpr rebuilds a tree serialized by EncodeSExpr.
+func DecodeSExpr(src string) (Atom, error) {
+	r := sexprReader{src: src}
+	n, err := r.node()
+	if err != nil {
+		return nil, err
+	}
+	r.skipSpace()
+	if r.pos != len(r.src) {
+		return nil, r.error("Unexpected trailing data")
+	}
+	return decodeRoot(n)
+}
+
+type sexprReader struct {
+	src string
+	pos int
+}
+
+func (r *sexprReader) error(format string, args ...interface{}) *ParseError {
+	return &ParseError{Message: fmt.Sprintf(format, args...), Position: r.pos}
+}
+
+func (r *sexprReader) skipSpace() {
+	for r.pos < len(r.src) && strings.ContainsRune(" \t\r\n", rune(r.src[r.pos])) {
+		r.pos++
+	}
+}
+
+func (r *sexprReader) expect(b byte) error {
+	r.skipSpace()
+	if r.pos >= len(r.src) || r.src[r.pos] != b {
+		return r.error("Expected %q", b)
+	}
+	r.pos++
+	return nil
+}
+
+func (r *sexprReader) symbol() string {
+	r.skipSpace()
+	start := r.pos
+	for r.pos < len(r.src) && !strings.ContainsRune(" \t\r\n()\"", rune(r.src[r.pos])) {
+		r.pos++
+	}
+	return r.src[start:r.pos]
+}
+
+func (r *sexprReader) string() (string, error) {
+	start := r.pos
+	for r.pos++; r.pos < len(r.src) && r.src[r.pos] != '"'; r.pos++ {
+		if r.src[r.pos] == '\\' {
+			r.pos++
+		}
+	}
+	if r.pos >= len(r.src) {
+		return "", r.error("Unterminated string")
+	}
+	r.pos++
+	text, err := strconv.Unquote(r.src[start:r.pos])
+	if err != nil {
+		r.pos = start
+		return "", r.error("Invalid string")
+	}
+	return text, nil
+}
+
+func (r *sexprReader) number() (int, error) {
+	sym := r.symbol()
+	v, err := strconv.Atoi(sym)
+	if err != nil {
+		return 0, r.error("Expected a number, found %q", sym)
+	}
+	return v, nil
+}
+
+func (r *sexprReader) peek() byte {
+	r.skipSpace()
+	if r.pos >= len(r.src) {
+		return 0
+	}
+	return r.src[r.pos]
+}
+
+func (r *sexprReader) node() (*serialNode, error) {
+	if err := r.expect('('); err != nil {
+		return nil, err
+	}
+	if r.peek() == ')' {
+		r.pos++
+		return nil, nil
+	}
+
+	n := &serialNode{Kind: r.symbol()}
+	if n.Kind == atomKindNames[KindRefResult] {
+		n.Name = r.symbol()
+	}
+	var err error
+	if n.Span[0], err = r.number(); err != nil {
+		return nil, err
+	}
+	if n.Span[1], err = r.number(); err != nil {
+		return nil, err
+	}
+
+	for r.peek() != ')' {
+		switch r.peek() {
+		case 0:
+			return nil, r.error("Unexpected end of input")
+		case '"':
+			if n.Text, err = r.string(); err != nil {
+				return nil, err
+			}
+		default:
+			c, err := r.node()
+			if err != nil {
+				return nil, err
+			}
+			if c == nil && n.Kind == atomKindNames[KindOption] {
+				valid := true
+				n.Valid = &valid
+				continue
+			}
+			n.Children = append(n.Children, c)
+		}
+	}
+	r.pos++
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, bc=23")
+
+	data, err := EncodeJSON(root, SerializeOptions{})
+	require.NoError(t, err)
+	decoded, err := DecodeJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, root, decoded)
+	redata, err := EncodeJSON(decoded, SerializeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(redata))
+
+	sexpr := EncodeSExpr(root, SerializeOptions{})
+	decoded, err = DecodeSExpr(sexpr)
+	require.NoError(t, err)
+	require.Equal(t, root, decoded)
+	require.Equal(t, sexpr, EncodeSExpr(decoded, SerializeOptions{}))
+	require.Equal(t, PrintTree(root), PrintTree(decoded))
+	require.Equal(t, "a=1, bc=23", Source(decoded))
+}
+
+func TestSerializeOptions(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1,b=2")
+	opts := SerializeOptions{CollapseTerminals: true, OmitEmptyOptions: true}
+
+	require.Equal(t, `(rule pairs 0 7 (list 0 7 `+
+		`(rule pair 0 3 (list 0 3 (rule key 0 1 (text 0 1 "a")) (char 1 2 "=") (rule value 2 3 (text 2 3 "1")))) `+
+		`(list 3 7 (list 3 7 (char 3 4 ",") `+
+		`(rule pair 4 7 (list 4 7 (rule key 4 5 (text 4 5 "b")) (char 5 6 "=") (rule value 6 7 (text 6 7 "2"))))))))`,
+		EncodeSExpr(root, opts))
+
+	data, err := EncodeJSON(FindAll(root, "key")[0], opts)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kind":"rule","name":"key","span":[0,1],"text":"a","children":[{"kind":"text","span":[0,1],"text":"a"}]}`, string(data))
+
+	decoded, err := DecodeJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, "a", Text(decoded))
+	require.Equal(t, KindChar, decoded.Children()[0].Children()[0].Kind())
+
+	_, err = DecodeSExpr(`(rule key 0 1 (bogus 0 1 "a"))`)
+	require.Error(t, err)
+	_, err = DecodeSExpr(`(rule key 0 1`)
+	require.Error(t, err)
+}
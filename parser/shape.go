@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+)
+
+// Shape changes how the result of a rule appears in the produced tree.
+type Shape int
+
+const (
+	// ShapeDefault wraps the result of a rule in a RefResult.
+	ShapeDefault Shape = iota
+	// ShapeSuppress matches the rule without producing anything.
+	ShapeSuppress
+	// ShapeInline splices the result of a rule into its parent, without a
+	// RefResult wrapper.
+	ShapeInline
+	// ShapeToken collapses the result of a rule into a single TokenVal holding
+	// the matched text.
+	ShapeToken
+)
+
+var shapeNames = map[Shape]string{
+	ShapeDefault:  "default",
+	ShapeSuppress: "suppress",
+	ShapeInline:   "inline",
+	ShapeToken:    "token",
+}
+
+func (s Shape) String() string { return shapeNames[s] }
+
+// ShapeByName returns the shape with the given name, as used by grammar
+// annotations.
+func ShapeByName(name string) (Shape, bool) {
+	for k, v := range shapeNames {
+		if v == name {
+			return k, true
+		}
+	}
+	return ShapeDefault, false
+}
+
+// ShapedConsumer applies a Shape to the result of a rule. It is meant to be
+// used as the consumer of a rule, like in
+//
+//	"ws": p.Suppress(p.Star(p.WSP)),
+type ShapedConsumer struct {
+	shape Shape
+	con   Consumer
+}
+
+func Suppress(con Consumer) *ShapedConsumer { return &ShapedConsumer{shape: ShapeSuppress, con: con} }
+func Inline(con Consumer) *ShapedConsumer   { return &ShapedConsumer{shape: ShapeInline, con: con} }
+func Token(con Consumer) *ShapedConsumer    { return &ShapedConsumer{shape: ShapeToken, con: con} }
+
+// WithShape returns con shaped as shape. ShapeDefault returns con unchanged.
+func WithShape(shape Shape, con Consumer) Consumer {
+	if shape == ShapeDefault {
+		return con
+	}
+	return &ShapedConsumer{shape: shape, con: con}
+}
+
+func (s ShapedConsumer) Shape() Shape       { return s.shape }
+func (s ShapedConsumer) Consumer() Consumer { return s.con }
+func (s ShapedConsumer) Name() string       { return fmt.Sprintf("%s(%s)", s.shape, s.con.Name()) }
+func (s ShapedConsumer) String() string     { return fmt.Sprintf("@%s %s", s.shape, s.con.String()) }
+func (s ShapedConsumer) Weight() int        { return s.con.Weight() }
+func (s ShapedConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+	l := newLink(c)
+	v, err := s.con.TryConsume(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	switch s.shape {
+	case ShapeSuppress:
+		return nil, nil
+	case ShapeInline:
+		if list, ok := v.(AtomList); ok {
+			list.splice = true
+			return list, nil
+		}
+	case ShapeToken:
+		l.finish(c)
+		return TokenVal{value: string(c.buffer[l.span.Start:l.span.End]), link: l}, nil
+	}
+	return v, nil
+}
+
+// appendResult appends the result of a consumer to list, skipping suppressed
+// results and splicing inlined ones.
+func appendResult(list []Atom, v Atom) []Atom {
+	switch i := v.(type) {
+	case nil:
+		return list
+	case AtomList:
+		if i.splice {
+			return append(list, i.value...)
+		}
+	}
+	return append(list, v)
+}
+
+// unspliced returns v as held by an atom taking it as its value rather than
+// splicing it into a list, which an inlined list no longer is.
+func unspliced(v Atom) Atom {
+	if list, ok := v.(AtomList); ok && list.splice {
+		list.splice = false
+		return list
+	}
+	return v
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShapes(t *testing.T) {
+	rules := MakeRules(map[string]Consumer{
+		"call":  Cat(Ref("name"), Ref("open"), Ref("args"), Ref("close")),
+		"name":  Token(Plus(ALPHA)),
+		"open":  Suppress(Cat(Lit('('), Star(SP))),
+		"close": Suppress(Cat(Star(SP), Lit(')'))),
+		"args":  Inline(Cat(Ref("arg"), Star(Cat(Ref("comma"), Ref("arg"))))),
+		"comma": Suppress(Lit(',')),
+		"arg":   Plus(DIGIT),
+	})
+	root, err := New(rules).Parse("call", "fn( 1,23)")
+	require.NoError(t, err)
+	require.Equal(t, `- Rule call:
+  - List:
+    - Rule name:
+      - Token: "fn"
+    - Rule arg:
+      - List:
+        - D: 1
+    - List:
+      - List:
+        - Rule arg:
+          - List:
+            - D: 2
+            - D: 3
+`, printCompact(root))
+	name := root.Children()[0].Children()[0]
+	require.Equal(t, Span{Start: 0, End: 2}, name.Span())
+	require.Equal(t, 1, root.Children()[0].Children()[1].Index())
+	require.Equal(t, "fn( 1,23)", Text(root))
+
+	for input, ok := range map[string]bool{"fn()": false, "fn(1)": true, "(1)": false} {
+		_, err := New(rules).Parse("call", input)
+		require.Equal(t, ok, err == nil, input)
+	}
+
+	require.Equal(t, ShapeInline, rules["args"].(*ShapedConsumer).Shape())
+	require.Equal(t, rules["arg"], WithShape(ShapeDefault, rules["arg"]))
+	shape, ok := ShapeByName("token")
+	require.True(t, ok)
+	require.Equal(t, ShapeToken, shape)
+}
+
+func TestRepetitionBounds(t *testing.T) {
+	rules := MakeRules(map[string]Consumer{
+		"exact":   Cat(Repeat(2, 2, DIGIT), Star(ALPHA)),
+		"atmost":  Cat(Repeat(0, 2, DIGIT), Star(DIGIT)),
+		"atleast": Repeat(2, 0, DIGIT),
+		"blank":   Plus(B(Lit('x'))),
+		"never":   Repeat(3, 2, DIGIT),
+	})
+	for _, c := range []struct {
+		rule, input string
+		ok          bool
+	}{
+		{"exact", "12ab", true},
+		{"exact", "1ab", false},
+		{"atmost", "12", true},
+		{"atmost", "123", false},
+		{"atleast", "1", false},
+		{"atleast", "12", true},
+		{"atleast", "123", false},
+		{"blank", "xxx", true},
+		{"blank", "", false},
+		{"never", "123", false},
+	} {
+		root, err := New(rules).Parse(c.rule, c.input)
+		require.Equal(t, c.ok, err == nil, "%s %q: %v", c.rule, c.input, err)
+		if c.rule == "atmost" && err == nil {
+			list := root.Children()[0].(AtomList)
+			require.Equal(t, 2, len(list.Nth(0).Children()))
+		}
+		if c.rule == "blank" && err == nil {
+			require.Empty(t, root.Children()[0].Children())
+			require.Equal(t, c.input, Text(root))
+		}
+	}
+
+	c := CursorFromString("123")
+	v, err := Repeat(3, 2, DIGIT).TryConsume(context.Background(), &c)
+	require.Nil(t, v)
+	require.EqualError(t, err, "Repetition 3*2DIGIT cannot match, as its minimum exceeds its maximum at position 0")
+}
+
+func TestShapedResults(t *testing.T) {
+	rules := MakeRules(map[string]Consumer{
+		"wrapper": Ref("pair"),
+		"maybe":   Opt(Ref("pair")),
+		"pair":    Inline(Cat(DIGIT, DIGIT)),
+		"hidden":  Suppress(Cat(DIGIT, DIGIT)),
+	})
+	// Inlined lists held as values rather than spliced decode as they were
+	// parsed.
+	for _, rule := range []string{"wrapper", "maybe"} {
+		root, err := New(rules).Parse(rule, "12")
+		require.NoError(t, err)
+		data, err := EncodeJSON(root, SerializeOptions{})
+		require.NoError(t, err)
+		decoded, err := DecodeJSON(data)
+		require.NoError(t, err)
+		require.Equal(t, root, decoded, rule)
+	}
+
+	// Start rules are wrapped whatever their shape, having no parent.
+	p := New(rules, WithIncremental())
+	for _, rule := range []string{"pair", "hidden"} {
+		root, err := p.Parse(rule, "12")
+		require.NoError(t, err)
+		require.Equal(t, rule, root.(RefResult).Name)
+		require.Equal(t, "12", Text(root))
+		edited, err := p.Reparse(root, TextEdit{Start: 1, OldEnd: 2, NewText: "3"})
+		require.NoError(t, err, rule)
+		require.Equal(t, "13", Text(edited))
+	}
+}
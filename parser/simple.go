@@ -3,18 +3,22 @@ package parser
 import (
 	"context"
 	"fmt"
-	"reflect"
 	"strings"
 )
 
 const parentContextKey = "__PARENT"
 
+// SetParent returns a copy of ctx holding parent.
+//
+// Deprecated: atoms are now linked to their parents once those are complete,
+// and consumers no longer need to pass them down. Use Atom.Parent instead.
 func SetParent(parent Atom, ctx context.Context) context.Context {
-	if reflect.TypeOf(parent).Kind() != reflect.Ptr {
-		panic("BUG: SetParent requires a pointer")
-	}
 	return context.WithValue(ctx, parentContextKey, parent)
 }
+
+// GetParent returns the parent stored in ctx by SetParent, if any.
+//
+// Deprecated: use the Parent method of the atoms returned by consumers.
 func GetParent(ctx context.Context) Atom {
 	if v, ok := ctx.Value(parentContextKey).(Atom); ok {
 		return v
@@ -30,7 +34,7 @@ func (AlphaConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v != 0x00 && (v >= 0x41 && v <= 0x5A) || (v >= 0x61 && v <= 0x7A) {
 		c.Consume()
-		return Alpha{value: string(v), parent: GetParent(ctx)}, nil
+		return Alpha{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected alpha character between a-z or A-Z. Found %q", v)
 }
@@ -45,7 +49,7 @@ func (BitConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == '0' || v == '1' {
 		c.Consume()
-		return Bit{value: string(v), parent: GetParent(ctx)}, nil
+		return Bit{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a bit (0-1). Found %q", v)
 }
@@ -59,7 +63,7 @@ func (CharConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x01 || v >= 0x7F {
 		c.Consume()
-		return Char{value: string(v), parent: GetParent(ctx)}, nil
+		return Char{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a value equal to 0x01 or greater than 0x7E. Found %q (0x%02x)", v, v)
 }
@@ -73,7 +77,7 @@ func (LFConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x0A {
 		c.Consume()
-		return LFVal{parent: GetParent(ctx)}, nil
+		return LFVal{link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a linefeed. Found %q", v)
 }
@@ -87,7 +91,7 @@ func (CRConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x0D {
 		c.Consume()
-		return CRVal{parent: GetParent(ctx)}, nil
+		return CRVal{link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "expected a carriage return. Found %q", v)
 }
@@ -101,7 +105,7 @@ func (CtlConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v <= 0x1f || v == 0x7f {
 		c.Consume()
-		return Ctl{value: string(v), parent: GetParent(ctx)}, nil
+		return Ctl{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a control character (0x7F, or <= 0x1F). Found %q (0x%2x)", v, v)
 }
@@ -115,7 +119,7 @@ func (DigitConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v >= 0x30 && v <= 0x39 {
 		c.Consume()
-		return Digit{value: string(v), parent: GetParent(ctx)}, nil
+		return Digit{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a digit (0-9). Found %q", v)
 }
@@ -129,7 +133,7 @@ func (DQuoteConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x22 {
 		c.Consume()
-		return DQuote{parent: GetParent(ctx)}, nil
+		return DQuote{link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a double-quote. Found %q", v)
 }
@@ -143,7 +147,7 @@ func (HTabConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x09 {
 		c.Consume()
-		return HTab{parent: GetParent(ctx)}, nil
+		return HTab{link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a horizontal tab. Found %q", v)
 }
@@ -156,7 +160,7 @@ func (OctetConsumer) Weight() int      { return 0 }
 func (OctetConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	if ok, v := c.TryPeek(); ok {
 		c.Consume()
-		return Octet{value: v, parent: GetParent(ctx)}, nil
+		return Octet{value: v, link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected octet, found EOF")
 }
@@ -170,7 +174,7 @@ func (SPConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v == 0x20 {
 		c.Consume()
-		return SPVal{parent: GetParent(ctx)}, nil
+		return SPVal{link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a space, found %q", v)
 }
@@ -184,7 +188,7 @@ func (VCharConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	v := c.Peek()
 	if v >= 0x21 && v <= 0x7E {
 		c.Consume()
-		return VChar{value: string(v), parent: GetParent(ctx)}, nil
+		return VChar{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a visible character, found %q (0x%02x) instead", v, v)
 }
@@ -204,19 +208,19 @@ func (c ConcatenationConsumer) String() string {
 }
 func (c ConcatenationConsumer) TryConsume(ctx context.Context, cur *Cursor) (Atom, error) {
 	var results []Atom
-	ret := AtomList{parent: GetParent(ctx)}
+	ret := AtomList{link: newLink(cur)}
 	cd := cur.dup()
 	for _, v := range c.cons {
-		if res, err := v.TryConsume(SetParent(&ret, ctx), &cd); err == nil {
-			if res != nil {
-				results = append(results, res)
-			}
+		if res, err := v.TryConsume(ctx, &cd); err == nil {
+			results = appendResult(results, res)
 		} else {
 			return nil, err
 		}
 	}
 
 	ret.value = results
+	ret.finish(&cd)
+	adopt(ret, results)
 	cur.Merge(cd)
 	return ret, nil
 }
@@ -230,12 +234,14 @@ func (o OptionalConsumer) String() string { return fmt.Sprintf("[ %s ]", o.con.S
 func (OptionalConsumer) Weight() int      { return 0 }
 func (o OptionalConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 	cd := c.dup()
-	ret := OptionVal{parent: GetParent(ctx)}
+	ret := OptionVal{link: newLink(c)}
 
-	if v, err := o.con.TryConsume(SetParent(&ret, ctx), &cd); err == nil {
+	if v, err := o.con.TryConsume(ctx, &cd); err == nil {
 		c.Merge(cd)
 		ret.Valid = true
-		ret.value = v
+		ret.value = unspliced(v)
+		ret.finish(c)
+		adopt(ret, ret.Children())
 	}
 	return ret, nil
 }
@@ -255,7 +261,7 @@ func (l LitConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
 
 	if v == l.lit {
 		c.Consume()
-		return Char{value: string(v), parent: GetParent(ctx)}, nil
+		return Char{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a literal %q, found %q instead", l.lit, v)
 }
@@ -275,13 +281,14 @@ func (h HexRangeConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, erro
 	}
 	if v >= h.from && v <= h.to {
 		c.Consume()
-		return Char{value: string(v), parent: GetParent(ctx)}, nil
+		return Char{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "expected a hexadecimal within range 0x%2x >= x <= 0x%2x, but found %q (0x%2x) instead", h.from, h.to, v, v)
 }
 
-// FIXME: Is this even working?!
-
+// BlankConsumer matches its consumer without producing any atom, which is
+// then skipped by concatenations and repetitions. Suppress does the same for
+// whole rules.
 type BlankConsumer struct {
 	con Consumer
 }
@@ -304,7 +311,7 @@ func (d DecimalConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error
 
 	if int(v) == d.v {
 		c.Consume()
-		return Char{value: string(v), parent: GetParent(ctx)}, nil
+		return Char{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "Expected a decimal %d, found %d instead", d.v, int(v))
 }
@@ -327,7 +334,31 @@ func (d DecRangeConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, erro
 	}
 	if int(v) >= d.from && int(v) <= d.to {
 		c.Consume()
-		return Char{value: string(v), parent: GetParent(ctx)}, nil
+		return Char{value: string(v), link: terminalLink(c)}, nil
 	}
 	return nil, Error(c, "expected a decimal within range %d >= x <= %d, but found %q (%d) instead", d.from, d.to, v, int(v))
 }
+
+// LabelConsumer replaces the errors of its consumer with a single error
+// naming what was expected, such as "Expected a rule name".
+type LabelConsumer struct {
+	label string
+	con   Consumer
+}
+
+func Label(label string, con Consumer) *LabelConsumer { return &LabelConsumer{label: label, con: con} }
+
+func (l LabelConsumer) Name() string   { return l.con.Name() }
+func (l LabelConsumer) String() string { return l.con.String() }
+func (l LabelConsumer) Weight() int    { return l.con.Weight() }
+func (l LabelConsumer) TryConsume(ctx context.Context, c *Cursor) (Atom, error) {
+	v, err := l.con.TryConsume(ctx, c)
+	if err != nil {
+		labeled := Error(c, "Expected %s", l.label)
+		if pe, ok := err.(*ParseError); ok {
+			labeled.Adopt(*pe)
+		}
+		return nil, labeled
+	}
+	return v, nil
+}
@@ -2,69 +2,207 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
+type PrintOptions struct {
+	// CollapseTerminals prints subtrees without rules as a single line holding
+	// their quoted text.
+	CollapseTerminals bool
+	// ShowSpans appends the span of each atom to its line.
+	ShowSpans bool
+	// HideRules omits rules with the given names, along with their subtrees.
+	HideRules []string
+	// MaxDepth limits how deep the printer descends. Zero means no limit.
+	MaxDepth int
+	// Color highlights rule names, values and spans using ANSI escapes.
+	Color bool
+	// BoxDrawing connects atoms using box-drawing characters instead of
+	// indentation. It implies Compact.
+	BoxDrawing bool
+	// Compact drops the trailing spaces and the blank line following each
+	// list, option and rule of the default format.
+	Compact bool
+}
+
+const (
+	ansiRule  = "\x1b[1;36m"
+	ansiValue = "\x1b[32m"
+	ansiSpan  = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// PrintTree returns the tree rooted at root in the default format, with each
+// atom on its own line.
 func PrintTree(root Atom) string {
-	return printTreeFn(root, 0)
+	str := strings.Builder{}
+	_ = PrintTreeTo(&str, root, PrintOptions{})
+	return str.String()
+}
+
+// PrintTreeTo writes the tree rooted at root to w, one line per atom, without
+// buffering the whole output.
+func PrintTreeTo(w io.Writer, root Atom, opts PrintOptions) error {
+	p := treePrinter{w: w, opts: opts, hidden: map[string]bool{}}
+	for _, r := range opts.HideRules {
+		p.hidden[strings.ToLower(r)] = true
+	}
+	if root == nil && !p.compact() {
+		p.writeLine("", "")
+	}
+	if root != nil && !p.isHidden(root) {
+		p.print(root, "", "", 0)
+	}
+	return p.err
+}
+
+type treePrinter struct {
+	w      io.Writer
+	opts   PrintOptions
+	hidden map[string]bool
+	err    error
+}
+
+func (p *treePrinter) isHidden(a Atom) bool {
+	ref, ok := a.(RefResult)
+	return ok && p.hidden[ref.Name]
+}
+
+func (p *treePrinter) compact() bool {
+	return p.opts.Compact || p.opts.BoxDrawing
+}
+
+func (p *treePrinter) colored(color, s string) string {
+	if !p.opts.Color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func (p *treePrinter) writeLine(prefix, line string) {
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, prefix+line+"\n")
+	}
+}
+
+func (p *treePrinter) print(a Atom, linePrefix, childPrefix string, depth int) {
+	label, children := p.label(a)
+	var visible []Atom
+	for _, c := range children {
+		if !p.isHidden(c) {
+			visible = append(visible, c)
+		}
+	}
+	if p.opts.ShowSpans {
+		span := a.Span()
+		label += " " + p.colored(ansiSpan, fmt.Sprintf("[%d, %d)", span.Start, span.End))
+	} else if _, ok := a.(RefResult); !ok && len(visible) > 0 && !p.compact() {
+		label += " "
+	}
+	if p.opts.BoxDrawing {
+		p.writeLine(linePrefix, label)
+	} else {
+		p.writeLine(strings.Repeat(" ", 2*depth), "- "+label)
+	}
+
+	if len(visible) == 0 {
+		return
+	}
+	if !p.compact() {
+		defer p.writeLine("", "")
+	}
+	if p.opts.MaxDepth > 0 && depth+1 >= p.opts.MaxDepth {
+		if p.opts.BoxDrawing {
+			p.writeLine(childPrefix, "└── ...")
+		} else {
+			p.writeLine(strings.Repeat(" ", 2*(depth+1)), "- ...")
+		}
+		return
+	}
+	for i, c := range visible {
+		if i == len(visible)-1 {
+			p.print(c, childPrefix+"└── ", childPrefix+"    ", depth+1)
+		} else {
+			p.print(c, childPrefix+"├── ", childPrefix+"│   ", depth+1)
+		}
+	}
 }
 
-func printTreeFn(root Atom, level int) string {
-	var str strings.Builder
-	str.WriteString(strings.Repeat(" ", 2*level))
-	switch v := root.(type) {
+// label returns the text describing a, along with the children that must
+// be printed below it.
+func (p *treePrinter) label(a Atom) (string, []Atom) {
+	value := func(s string) string { return p.colored(ansiValue, s) }
+	collapsed := func() (string, bool) {
+		if !p.opts.CollapseTerminals || !terminalsOnly(a) {
+			return "", false
+		}
+		return value(fmt.Sprintf("%q", Text(a))), true
+	}
+
+	switch v := a.(type) {
 	case Alpha:
-		str.WriteString("- A: ")
-		str.WriteString(v.value)
+		return "A: " + value(v.value), nil
 	case Bit:
-		str.WriteString("- B: ")
-		str.WriteString(v.value)
+		return "B: " + value(v.value), nil
 	case Char:
-		str.WriteString("- C: ")
-		str.WriteString(v.value)
+		return "C: " + value(v.value), nil
 	case CRVal:
-		str.WriteString("- CR")
+		return "CR", nil
 	case LFVal:
-		str.WriteString("- LF")
+		return "LF", nil
 	case Ctl:
-		str.WriteString("- T: ")
-		str.WriteString(fmt.Sprintf("0x%02x", v.value[0]))
+		return "T: " + value(fmt.Sprintf("0x%02x", v.value[0])), nil
 	case Digit:
-		str.WriteString("- D: ")
-		str.WriteString(v.value)
+		return "D: " + value(v.value), nil
 	case DQuote:
-		str.WriteString("- DQuote")
+		return "DQuote", nil
 	case HTab:
-		str.WriteString("- HTab")
+		return "HTab", nil
 	case Octet:
-		str.WriteString("- O: ")
-		str.WriteString(fmt.Sprintf("0x%02x", v.value))
+		return "O: " + value(fmt.Sprintf("0x%02x", v.value)), nil
 	case SPVal:
-		str.WriteString("- SP")
+		return "SP", nil
 	case VChar:
-		str.WriteString("- VChar: ")
-		str.WriteString(fmt.Sprintf("%q", v.value))
+		return "VChar: " + value(fmt.Sprintf("%q", v.value)), nil
+	case TokenVal:
+		return "Token: " + value(fmt.Sprintf("%q", v.value)), nil
 	case OptionVal:
 		if !v.Valid {
-			str.WriteString("- Empty Opt")
-		} else {
-			str.WriteString("- Opt: \n")
-			str.WriteString(printTreeFn(v.value, level+1))
+			return "Empty Opt", nil
+		}
+		if text, ok := collapsed(); ok {
+			return "Opt: " + text, nil
 		}
+		return "Opt:", v.Children()
 	case AtomList:
 		if len(v.value) == 0 {
-			str.WriteString("- Empty List")
-		} else {
-			str.WriteString("- List: \n")
-			for _, v := range v.value {
-				str.WriteString(printTreeFn(v, level+1))
-			}
+			return "Empty List", nil
+		}
+		if text, ok := collapsed(); ok {
+			return "Text: " + text, nil
 		}
+		return "List:", v.Children()
 	case RefResult:
-		str.WriteString(fmt.Sprintf("- Rule %s:\n", v.Name))
-		str.WriteString(printTreeFn(v.value, level+1))
+		name := p.colored(ansiRule, v.Name)
+		if text, ok := collapsed(); ok {
+			return "Rule " + name + ": " + text, nil
+		}
+		return "Rule " + name + ":", v.Children()
 	}
+	return fmt.Sprintf("%s: %v", a.Kind(), a.Value()), a.Children()
+}
 
-	str.WriteString("\n")
-	return str.String()
+// terminalsOnly reports whether the subtree rooted at a holds no rules other
+// than a itself.
+func terminalsOnly(a Atom) bool {
+	only := true
+	Walk(a, func(c Atom, enter bool) WalkAction {
+		if _, ok := c.(RefResult); ok && enter && linkOf(c) != linkOf(a) {
+			only = false
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	return only
 }
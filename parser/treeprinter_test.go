@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintTreeOptions(t *testing.T) {
+	root := parsePairs(t, "pairs", "a=1, b=2")
+	print := func(opts PrintOptions) string {
+		str := strings.Builder{}
+		require.NoError(t, PrintTreeTo(&str, root, opts))
+		return str.String()
+	}
+
+	require.Equal(t, strings.Join([]string{
+		"Rule pairs: [0, 8)",
+		"└── List: [0, 8)",
+		"    ├── Rule pair: [0, 3)",
+		"    │   └── List: [0, 3)",
+		"    │       ├── Rule key: \"a\" [0, 1)",
+		"    │       ├── C: = [1, 2)",
+		"    │       └── Rule value: \"1\" [2, 3)",
+		"    └── List: [3, 8)",
+		"        └── List: [3, 8)",
+		"            ├── C: , [3, 4)",
+		"            ├── Opt: \" \" [4, 5)",
+		"            └── Rule pair: [5, 8)",
+		"                └── ...",
+		"",
+	}, "\n"), print(PrintOptions{CollapseTerminals: true, ShowSpans: true, BoxDrawing: true, MaxDepth: 5}))
+
+	require.Equal(t, strings.Join([]string{
+		"- Rule pairs:",
+		"  - List:",
+		"    - Rule pair:",
+		"      - List:",
+		"        - C: =",
+		"        - Rule value:",
+		"          - List:",
+		"            - D: 1",
+	}, "\n"), strings.Join(strings.Split(print(PrintOptions{HideRules: []string{"KEY"}, Compact: true}), "\n")[:8], "\n"))
+
+	colored := print(PrintOptions{Color: true, CollapseTerminals: true})
+	require.Contains(t, colored, "Rule "+ansiRule+"key"+ansiReset+": "+ansiValue+`"a"`+ansiReset)
+	require.Equal(t, "- Rule pairs:\n", PrintTree(root)[:14])
+	require.Equal(t, print(PrintOptions{}), PrintTree(root))
+}
+
+func TestPrintTreeDefault(t *testing.T) {
+	require.Equal(t, "- Rule pair:\n  - List: \n    - Rule key:\n      - List: \n        - A: a\n\n\n    - C: =\n"+
+		"    - Rule value:\n      - List: \n        - D: 1\n\n\n\n\n", PrintTree(parsePairs(t, "pair", "a=1")))
+	require.Equal(t, "\n", PrintTree(nil))
+}
+
+// printCompact returns the tree rooted at root in the compact format.
+func printCompact(root Atom) string {
+	str := strings.Builder{}
+	_ = PrintTreeTo(&str, root, PrintOptions{Compact: true})
+	return str.String()
+}
@@ -0,0 +1,158 @@
+package parser
+
+import "strings"
+
+// Trivia is a match of a rule treated as layout by a lossless Parser, such as
+// whitespace or comments.
+type Trivia struct {
+	Rule string
+	Span Span
+	Text string
+	// Atom is the detached tree matched by the rule.
+	Atom RefResult
+}
+
+// LeadingTrivia returns the trivia found right before a.
+func LeadingTrivia(a Atom) []Trivia {
+	if l := linkOf(a); l != nil {
+		return l.leading
+	}
+	return nil
+}
+
+// TrailingTrivia returns the trivia found right after a, up to the end of its
+// line.
+func TrailingTrivia(a Atom) []Trivia {
+	if l := linkOf(a); l != nil {
+		return l.trailing
+	}
+	return nil
+}
+
+// FullText returns the text of the subtree rooted at a along with all its
+// trivia. For a tree returned by a lossless Parser, this is the input it was
+// parsed from.
+func FullText(a Atom) string {
+	str := strings.Builder{}
+	writeTrivia := func(trivia []Trivia) {
+		for _, t := range trivia {
+			str.WriteString(t.Text)
+		}
+	}
+	Walk(a, func(a Atom, enter bool) WalkAction {
+		if enter {
+			writeTrivia(LeadingTrivia(a))
+			str.WriteString(terminalText(a))
+		} else {
+			writeTrivia(TrailingTrivia(a))
+		}
+		return WalkContinue
+	})
+	return str.String()
+}
+
+func isTerminal(a Atom) bool {
+	switch a.(type) {
+	case OptionVal, AtomList, RefResult:
+		return false
+	}
+	return true
+}
+
+// attachTrivia removes every rule in trivia from the tree rooted at root,
+// attaching them to the terminals around them. A run of trivia following a
+// terminal is trailing trivia of that terminal up to the first line break,
+// and the rest is leading trivia of the next terminal. Trivia found when there
+// are no terminals left is trailing trivia of the last one, or leading trivia
+// of root when the tree has no terminals at all.
+func attachTrivia(root Atom, trivia map[string]bool) Atom {
+	src := sourceOf(root)
+	var pending []Trivia
+	var last *link
+	lineClosed := false
+
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if !enter {
+			return WalkContinue
+		}
+		if ref, ok := a.(RefResult); ok && trivia[ref.Name] && linkOf(a) != linkOf(root) {
+			t := Trivia{Rule: ref.Name, Span: ref.Span(), Text: Text(ref), Atom: ref}
+			if last != nil && !lineClosed {
+				last.trailing = append(last.trailing, t)
+				lineClosed = strings.Contains(t.Text, "\n")
+			} else {
+				pending = append(pending, t)
+			}
+			return WalkSkipChildren
+		}
+		if l := linkOf(a); l != nil && isTerminal(a) {
+			l.leading, pending = pending, nil
+			last, lineClosed = l, false
+		}
+		return WalkContinue
+	})
+	switch {
+	case last != nil:
+		last.trailing = append(last.trailing, pending...)
+	case linkOf(root) != nil:
+		linkOf(root).leading = pending
+	}
+
+	root = stripTrivia(root, trivia)
+	// Detached trivia become roots of their own, still able to reach the
+	// input they were parsed from.
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if l := linkOf(a); l != nil && enter {
+			for _, list := range [][]Trivia{l.leading, l.trailing} {
+				for _, t := range list {
+					tl := t.Atom.link
+					tl.parent, tl.index = nil, -1
+					if src != nil {
+						tl.source = &src
+					}
+				}
+			}
+		}
+		return WalkContinue
+	})
+	return root
+}
+
+// stripTrivia returns a copy of a without rules in trivia, relinking the
+// atoms left.
+func stripTrivia(a Atom, trivia map[string]bool) Atom {
+	isTrivia := func(a Atom) bool {
+		ref, ok := a.(RefResult)
+		return ok && trivia[ref.Name]
+	}
+
+	switch v := a.(type) {
+	case AtomList:
+		var items []Atom
+		for _, c := range v.value {
+			if !isTrivia(c) {
+				items = append(items, stripTrivia(c, trivia))
+			}
+		}
+		v.value = items
+		adopt(v, v.Children())
+		return v
+	case OptionVal:
+		if isTrivia(v.value) {
+			v.value = nil
+		} else if v.value != nil {
+			v.value = stripTrivia(v.value, trivia)
+			adopt(v, v.Children())
+		}
+		return v
+	case RefResult:
+		if isTrivia(v.value) {
+			v.value = nil
+		} else if v.value != nil {
+			v.value = stripTrivia(v.value, trivia)
+			adopt(v, v.Children())
+		}
+		return v
+	}
+	return a
+}
@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var listRules = MakeRules(map[string]Consumer{
+	"list":    Cat(Star(Ref("ws")), Ref("item"), Star(Cat(Lit(','), Star(Ref("ws")), Ref("item"))), Star(Ref("ws"))),
+	"item":    Cat(Plus(ALPHA), Star(Ref("ws"))),
+	"ws":      Alt(SP, Cat(CR, LF), Ref("comment")),
+	"comment": Cat(Lit('#'), Star(Alt(SP, VCHAR))),
+})
+
+func TestTrivia(t *testing.T) {
+	input := "  ab #first\r\n, cd\r\n  #last"
+	p := New(listRules, WithTrivia("ws"))
+	root, err := p.Parse("list", input)
+	require.NoError(t, err)
+	require.Equal(t, input, FullText(root))
+	require.Empty(t, FindAll(root, "ws"))
+
+	texts := func(trivia []Trivia) (result []string) {
+		for _, t := range trivia {
+			result = append(result, t.Text)
+		}
+		return
+	}
+	items := FindAll(root, "item")
+	require.Len(t, items, 2)
+	a, b := items[0].Children()[0].Children()[0].Children()[0], items[0].Children()[0].Children()[0].Children()[1]
+	require.Equal(t, []string{" ", " "}, texts(LeadingTrivia(a)))
+	require.Nil(t, TrailingTrivia(a))
+	require.Equal(t, []string{" ", "#first", "\r\n"}, texts(TrailingTrivia(b)))
+
+	d := items[1].Children()[0].Children()[0].Children()[1]
+	require.Equal(t, []string{"\r\n", " ", " ", "#last"}, texts(TrailingTrivia(d)))
+	require.Equal(t, "d", Text(d))
+
+	comma := root.Children()[0].Children()[2].Children()[0].Children()[0]
+	require.Equal(t, []string{" "}, texts(TrailingTrivia(comma)))
+
+	last := TrailingTrivia(d)
+	require.Nil(t, last[3].Atom.Parent())
+	require.Equal(t, "#last", Text(last[3].Atom))
+	require.Equal(t, Span{Start: 21, End: 26}, last[3].Span)
+
+	plain, err := New(listRules).Parse("list", input)
+	require.NoError(t, err)
+	require.Len(t, FindAll(plain, "ws"), 10)
+
+	_, err = p.Parse("list", "ab,")
+	require.Error(t, err)
+}
@@ -8,127 +8,271 @@ import (
 
 type Atom interface {
 	Parent() Atom
+	Children() []Atom
+	Index() int
+	NextSibling() Atom
+	PrevSibling() Atom
+	Path() []string
+	Span() Span
 	Value() interface{}
 	Kind() AtomKind
 }
 
-type Alpha struct {
-	value  string
+// Span is the range of runes [Start, End) of the input matched by an atom.
+type Span struct {
+	Start int
+	End   int
+}
+
+func (s Span) Len() int { return s.End - s.Start }
+
+// link holds the position of an atom within its tree and its input. Atoms are
+// value types that get copied around freely, so the parent pointer lives in a
+// shared link that is only filled once the parent itself has been fully built.
+type link struct {
 	parent Atom
+	index  int
+	span   Span
+	// source is only set on the root of a tree returned by KickoffParser.
+	source *[]rune
+	// memo is only set on the root of a tree returned by a Parser, holding
+	// the results used by Reparse.
+	memo *memoTable
+	// leading and trailing hold the trivia attached by a lossless Parser.
+	leading  []Trivia
+	trailing []Trivia
+}
+
+// newLink returns a link for a composite atom starting at the current cursor
+// position. Its end is updated through finish once the atom is complete.
+func newLink(c *Cursor) *link {
+	return &link{index: -1, span: Span{Start: c.pos + 1, End: c.pos + 1}}
+}
+
+// terminalLink returns a link for the terminal the cursor has just consumed.
+func terminalLink(c *Cursor) *link {
+	return &link{index: -1, span: Span{Start: c.pos, End: c.pos + 1}}
+}
+
+func (l *link) finish(c *Cursor) { l.span.End = c.pos + 1 }
+
+func (l *link) Span() Span {
+	if l == nil {
+		return Span{}
+	}
+	return l.span
+}
+
+func (l *link) meta() *link { return l }
+
+func (l *link) Parent() Atom {
+	if l == nil {
+		return nil
+	}
+	return l.parent
+}
+
+func (l *link) Children() []Atom { return nil }
+
+func (l *link) Index() int {
+	if l == nil {
+		return -1
+	}
+	return l.index
+}
+
+func (l *link) sibling(offset int) Atom {
+	if l == nil || l.parent == nil {
+		return nil
+	}
+	children := l.parent.Children()
+	i := l.index + offset
+	if i < 0 || i >= len(children) {
+		return nil
+	}
+	return children[i]
+}
+
+func (l *link) NextSibling() Atom { return l.sibling(1) }
+func (l *link) PrevSibling() Atom { return l.sibling(-1) }
+
+func (l *link) Path() []string {
+	var path []string
+	for p := l.Parent(); p != nil; p = p.Parent() {
+		if ref, ok := p.(RefResult); ok {
+			path = append(path, ref.Name)
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Source returns the input the tree containing a was parsed from, or an empty
+// string when it is unknown. Decoded trees only know the input they cover, and
+// only when they start at its beginning.
+func Source(a Atom) string {
+	if src := sourceOf(a); src != nil {
+		return string(src)
+	}
+	return ""
+}
+
+func sourceOf(a Atom) []rune {
+	for a != nil {
+		if l := linkOf(a); l != nil && l.source != nil {
+			return *l.source
+		}
+		a = a.Parent()
+	}
+	return nil
+}
+
+type linked interface {
+	meta() *link
+}
+
+func linkOf(a Atom) *link {
+	if l, ok := a.(linked); ok {
+		return l.meta()
+	}
+	return nil
+}
+
+// adopt links each child in children to parent. It must be called once parent
+// holds its final value, as children keep a copy of it.
+func adopt(parent Atom, children []Atom) {
+	for i, c := range children {
+		if l, ok := c.(linked); ok && l.meta() != nil {
+			l.meta().parent = parent
+			l.meta().index = i
+		}
+	}
+}
+
+type Alpha struct {
+	value string
+	*link
 }
 
-func (a Alpha) Parent() Atom       { return a.parent }
 func (a Alpha) Value() interface{} { return a.value }
 func (a Alpha) Kind() AtomKind     { return KindAlpha }
 
 type Bit struct {
-	value  string
-	parent Atom
+	value string
+	*link
 }
 
-func (b Bit) Parent() Atom       { return b.parent }
 func (b Bit) Value() interface{} { return b.value }
 func (b Bit) Kind() AtomKind     { return KindBit }
 
 type Char struct {
-	value  string
-	parent Atom
+	value string
+	*link
 }
 
-func (c Char) Parent() Atom       { return c.parent }
 func (c Char) Value() interface{} { return c.value }
 func (c Char) Kind() AtomKind     { return KindChar }
 
-type CRVal struct{ parent Atom }
+type CRVal struct{ *link }
 
-func (c CRVal) Parent() Atom       { return c.parent }
 func (c CRVal) Value() interface{} { return "\r" }
 func (c CRVal) Kind() AtomKind     { return KindCR }
 
-type LFVal struct{ parent Atom }
+type LFVal struct{ *link }
 
-func (l LFVal) Parent() Atom       { return l.parent }
 func (l LFVal) Value() interface{} { return "\n" }
 func (l LFVal) Kind() AtomKind     { return KindLF }
 
 type Ctl struct {
-	parent Atom
-	value  string
+	*link
+	value string
 }
 
-func (c Ctl) Parent() Atom       { return c.parent }
 func (c Ctl) Value() interface{} { return c.value }
 func (c Ctl) Kind() AtomKind     { return KindCtl }
 
 type Digit struct {
-	parent Atom
-	value  string
+	*link
+	value string
 }
 
-func (d Digit) Parent() Atom       { return d.parent }
 func (d Digit) Value() interface{} { return d.value }
 func (d Digit) Kind() AtomKind     { return KindDigit }
 
-type DQuote struct{ parent Atom }
+type DQuote struct{ *link }
 
-func (d DQuote) Parent() Atom       { return d.parent }
 func (d DQuote) Value() interface{} { return "\"" }
 func (d DQuote) Kind() AtomKind     { return KindDQuote }
 
-type HTab struct{ parent Atom }
+type HTab struct{ *link }
 
-func (h HTab) Parent() Atom       { return h.parent }
 func (h HTab) Value() interface{} { return "\t" }
 func (h HTab) Kind() AtomKind     { return KindHTab }
 
 type Octet struct {
-	parent Atom
-	value  rune
+	*link
+	value rune
 }
 
-func (o Octet) Parent() Atom       { return o.parent }
 func (o Octet) Value() interface{} { return o.value }
 func (o Octet) Kind() AtomKind     { return KindOctet }
 
-type SPVal struct{ parent Atom }
+type SPVal struct{ *link }
 
-func (s SPVal) Parent() Atom       { return s.parent }
 func (s SPVal) Value() interface{} { return " " }
 func (s SPVal) Kind() AtomKind     { return KindSP }
 
 type VChar struct {
-	parent Atom
-	value  string
+	*link
+	value string
 }
 
-func (v VChar) Parent() Atom       { return v.parent }
 func (v VChar) Value() interface{} { return v.value }
 func (v VChar) Kind() AtomKind     { return KindVChar }
 
+// TokenVal holds the whole text matched by a rule shaped as a token.
+type TokenVal struct {
+	*link
+	value string
+}
+
+func (t TokenVal) Value() interface{} { return t.value }
+func (t TokenVal) Kind() AtomKind     { return KindToken }
+
 type OptionVal struct {
-	parent Atom
-	Valid  bool
-	value  Atom
+	*link
+	Valid bool
+	value Atom
 }
 
-func (o OptionVal) Parent() Atom       { return o.parent }
 func (o OptionVal) Value() interface{} { return o.value }
-func (o OptionVal) Kind() AtomKind     { return KindOption }
+func (o OptionVal) Children() []Atom {
+	if !o.Valid || o.value == nil {
+		return nil
+	}
+	return []Atom{o.value}
+}
+func (o OptionVal) Kind() AtomKind { return KindOption }
 
 type AtomList struct {
-	parent Atom
-	value  []Atom
+	*link
+	value []Atom
+	// splice is set on lists produced by inlined rules, which are spliced
+	// into the lists holding them.
+	splice bool
 }
 
-func (a AtomList) Len() int     { return len(a.value) }
-func (a AtomList) Parent() Atom { return a.parent }
+func (a AtomList) Len() int { return len(a.value) }
 func (a AtomList) Value() interface{} {
 	if a.value == nil {
 		return nil
 	}
 	return a.value
 }
-func (a AtomList) Kind() AtomKind { return KindAtomList }
+func (a AtomList) Kind() AtomKind   { return KindAtomList }
+func (a AtomList) Children() []Atom { return a.value }
 func (a AtomList) AllTerminals() bool {
 	for _, v := range a.value {
 		switch i := v.(type) {
@@ -145,6 +289,16 @@ func (a AtomList) AllTerminals() bool {
 	return true
 }
 func (a AtomList) ReduceAsString() string {
+	str, err := a.ReduceAsStringE()
+	if err != nil {
+		panic(err.Error())
+	}
+	return str
+}
+
+// ReduceAsStringE is like ReduceAsString, but returns an error instead of
+// panicking when a holds atoms without a textual value.
+func (a AtomList) ReduceAsStringE() (string, error) {
 	str := strings.Builder{}
 	for _, v := range a.value {
 		switch inst := v.(type) {
@@ -162,35 +316,61 @@ func (a AtomList) ReduceAsString() string {
 			str.WriteString("\n")
 		case VChar:
 			str.WriteString(inst.value)
+		case TokenVal:
+			str.WriteString(inst.value)
 		case AtomList:
-			str.WriteString(inst.ReduceAsString())
+			inner, err := inst.ReduceAsStringE()
+			if err != nil {
+				return "", err
+			}
+			str.WriteString(inner)
 		default:
-			panic(fmt.Sprintf("Cannot reduce %T as string", v))
+			return "", fmt.Errorf("Cannot reduce %T as string", v)
 		}
 	}
-	return str.String()
+	return str.String(), nil
 }
 func (a AtomList) ReduceAsInt() (bool, int) {
-	str := a.ReduceAsString()
-	if str == "" {
-		return false, 0
+	ok, i, err := a.ReduceAsIntE()
+	if err != nil {
+		panic(err.Error())
+	}
+	return ok, i
+}
+
+// ReduceAsIntE is like ReduceAsInt, but returns an error instead of panicking
+// when a does not hold a decimal number.
+func (a AtomList) ReduceAsIntE() (bool, int, error) {
+	str, err := a.ReduceAsStringE()
+	if err != nil || str == "" {
+		return false, 0, err
 	}
 	i, err := strconv.Atoi(str)
 	if err != nil {
-		panic(fmt.Sprintf("ReduceAsInt failed: %s", err))
+		return false, 0, fmt.Errorf("ReduceAsInt failed: %s", err)
 	}
-	return true, i
+	return true, i, nil
 }
 func (a AtomList) ReduceAsHex() (bool, int) {
-	str := a.ReduceAsString()
-	if str == "" {
-		return false, 0
+	ok, i, err := a.ReduceAsHexE()
+	if err != nil {
+		panic(err.Error())
+	}
+	return ok, i
+}
+
+// ReduceAsHexE is like ReduceAsHex, but returns an error instead of panicking
+// when a does not hold a hexadecimal byte.
+func (a AtomList) ReduceAsHexE() (bool, int, error) {
+	str, err := a.ReduceAsStringE()
+	if err != nil || str == "" {
+		return false, 0, err
 	}
 	i, err := strconv.ParseUint(str, 16, 8)
 	if err != nil {
-		panic(fmt.Sprintf("ReduceAsHex failed: %s", err))
+		return false, 0, fmt.Errorf("ReduceAsHex failed: %s", err)
 	}
-	return true, int(i)
+	return true, int(i), nil
 }
 func (a AtomList) First() Atom {
 	return a.Nth(0)
@@ -200,11 +380,17 @@ func (a AtomList) Nth(i int) Atom {
 }
 
 type RefResult struct {
-	Name   string
-	value  Atom
-	parent Atom
+	Name  string
+	value Atom
+	*link
 }
 
-func (r RefResult) Parent() Atom       { return r.parent }
 func (r RefResult) Value() interface{} { return r.value }
 func (r RefResult) Kind() AtomKind     { return KindRefResult }
+func (r RefResult) Children() []Atom {
+	if r.value == nil {
+		return nil
+	}
+	return []Atom{r.value}
+}
+func (r RefResult) Path() []string { return append(r.link.Path(), r.Name) }
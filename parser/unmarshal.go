@@ -0,0 +1,298 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TypeRegistry maps rule names to the concrete Go types used when
+// unmarshalling a rule into an interface, which is how alternations are
+// usually represented.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string][]reflect.Type
+}
+
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: map[string][]reflect.Type{}}
+}
+
+// Register associates the type of prototype with rule. A rule may have more
+// than one type registered, as long as they implement different interfaces.
+func (t *TypeRegistry) Register(rule string, prototype interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rule = strings.ToLower(rule)
+	t.types[rule] = append(t.types[rule], reflect.TypeOf(prototype))
+}
+
+func (t *TypeRegistry) lookup(rule string, iface reflect.Type) reflect.Type {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, typ := range t.types[rule] {
+		if typ.Implements(iface) {
+			return typ
+		}
+	}
+	return nil
+}
+
+// DefaultTypes is the registry used by Unmarshal.
+var DefaultTypes = NewTypeRegistry()
+
+// RegisterType registers prototype for rule in DefaultTypes.
+func RegisterType(rule string, prototype interface{}) {
+	DefaultTypes.Register(rule, prototype)
+}
+
+// Unmarshal fills target, which must be a pointer, from the tree rooted at
+// root using DefaultTypes. See TypeRegistry.Unmarshal.
+func Unmarshal(root Atom, target interface{}) error {
+	return DefaultTypes.Unmarshal(root, target)
+}
+
+// Unmarshal fills target, which must be a pointer, from the tree rooted at
+// root. Values are filled according to their types:
+//
+//   - strings receive the text matched by the atom;
+//   - integers and floats are parsed from that text;
+//   - bools are set when the atom is present;
+//   - Span receives the span of the atom, and Atom or RefResult the atom
+//     itself;
+//   - pointers are allocated and filled, or left nil when a rule is absent;
+//   - slices receive every occurrence of a rule;
+//   - interfaces receive a value of the type registered for the matched rule,
+//     or for the closest rule below it;
+//   - structs have each field tagged with `goparse:"name[,options]"` filled
+//     from the rule with that name found below the atom, looking through
+//     lists and options but not other rules. The name "." refers to the atom
+//     itself. Options are "optional", allowing the rule to be absent,
+//     "repeat", requiring a slice to hold at least one item unless also
+//     optional, and "flatten", which also looks for the rule within nested
+//     rules.
+//
+// Errors are returned as a *ReduceError holding the path of the rule that
+// could not be unmarshalled.
+func (t *TypeRegistry) Unmarshal(root Atom, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("unmarshal target must be a non-nil pointer, found %T", target)
+	}
+	return t.decode(root, v.Elem())
+}
+
+var (
+	atomType      = reflect.TypeOf((*Atom)(nil)).Elem()
+	refResultType = reflect.TypeOf(RefResult{})
+	spanType      = reflect.TypeOf(Span{})
+)
+
+type fieldTag struct {
+	name     string
+	optional bool
+	repeat   bool
+	flatten  bool
+}
+
+func parseFieldTag(tag string) (fieldTag, error) {
+	parts := strings.Split(tag, ",")
+	result := fieldTag{name: strings.ToLower(parts[0])}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "optional":
+			result.optional = true
+		case "repeat":
+			result.repeat = true
+		case "flatten":
+			result.flatten = true
+		default:
+			return result, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return result, nil
+}
+
+func unmarshalError(a Atom, format string, args ...interface{}) *ReduceError {
+	err := &ReduceError{Span: a.Span(), Err: fmt.Errorf(format, args...)}
+	if ref, ok := a.(RefResult); ok {
+		err.Rule = ref.Name
+		err.Path = ref.Path()
+	} else {
+		err.Path = a.Path()
+		if len(err.Path) > 0 {
+			err.Rule = err.Path[len(err.Path)-1]
+		}
+	}
+	return err
+}
+
+func (t *TypeRegistry) decode(a Atom, v reflect.Value) error {
+	typ := v.Type()
+	switch {
+	case typ == atomType:
+		v.Set(reflect.ValueOf(a))
+		return nil
+	case typ == refResultType:
+		ref, ok := a.(RefResult)
+		if !ok {
+			return unmarshalError(a, "cannot unmarshal %s into RefResult", a.Kind())
+		}
+		v.Set(reflect.ValueOf(ref))
+		return nil
+	case typ == spanType:
+		v.Set(reflect.ValueOf(a.Span()))
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		v.SetString(Text(a))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(Text(a), 10, typ.Bits())
+		if err != nil {
+			return unmarshalError(a, "cannot unmarshal %q into %s", Text(a), typ)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(Text(a), 10, typ.Bits())
+		if err != nil {
+			return unmarshalError(a, "cannot unmarshal %q into %s", Text(a), typ)
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(Text(a), typ.Bits())
+		if err != nil {
+			return unmarshalError(a, "cannot unmarshal %q into %s", Text(a), typ)
+		}
+		v.SetFloat(f)
+	case reflect.Ptr:
+		ptr := reflect.New(typ.Elem())
+		if err := t.decode(a, ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+	case reflect.Slice:
+		children := refChildren(a)
+		slice := reflect.MakeSlice(typ, len(children), len(children))
+		for i, c := range children {
+			if err := t.decode(c, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Interface:
+		return t.decodeInterface(a, v)
+	case reflect.Struct:
+		return t.decodeStruct(a, v)
+	default:
+		return unmarshalError(a, "cannot unmarshal into %s", typ)
+	}
+	return nil
+}
+
+func (t *TypeRegistry) decodeInterface(a Atom, v reflect.Value) error {
+	candidates := []Atom{a}
+	for _, c := range refChildren(a) {
+		candidates = append(candidates, c)
+	}
+	for _, c := range candidates {
+		ref, ok := c.(RefResult)
+		if !ok {
+			continue
+		}
+		if typ := t.lookup(ref.Name, v.Type()); typ != nil {
+			concrete := reflect.New(typ).Elem()
+			if err := t.decode(ref, concrete); err != nil {
+				return err
+			}
+			v.Set(concrete)
+			return nil
+		}
+	}
+	return unmarshalError(a, "no type registered implementing %s", v.Type())
+}
+
+func (t *TypeRegistry) decodeStruct(a Atom, v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		raw, ok := field.Tag.Lookup("goparse")
+		if !ok || raw == "-" || field.PkgPath != "" {
+			continue
+		}
+		tag, err := parseFieldTag(raw)
+		if err != nil {
+			return unmarshalError(a, "field %s: %s", field.Name, err)
+		}
+		if err := t.decodeField(a, field, tag, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TypeRegistry) decodeField(a Atom, field reflect.StructField, tag fieldTag, v reflect.Value) error {
+	var matches []Atom
+	if tag.name == "." {
+		matches = []Atom{a}
+	} else {
+		for _, ref := range findRules(a, tag.name, tag.flatten) {
+			matches = append(matches, ref)
+		}
+	}
+
+	if tag.repeat && field.Type.Kind() != reflect.Slice {
+		return unmarshalError(a, "field %s: repeat requires a slice, found %s", field.Name, field.Type)
+	}
+
+	switch {
+	case field.Type.Kind() == reflect.Slice && field.Type != reflect.TypeOf([]byte(nil)) && tag.name != ".":
+		if len(matches) == 0 && tag.repeat && !tag.optional {
+			return unmarshalError(a, "field %s: expected at least one %s", field.Name, tag.name)
+		}
+		slice := reflect.MakeSlice(field.Type, len(matches), len(matches))
+		for i, m := range matches {
+			if err := t.decode(m, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case len(matches) == 0:
+		if tag.optional || field.Type.Kind() == reflect.Ptr || field.Type.Kind() == reflect.Bool {
+			return nil
+		}
+		return unmarshalError(a, "field %s: missing %s", field.Name, tag.name)
+	case len(matches) > 1:
+		return unmarshalError(a, "field %s: expected a single %s, found %d", field.Name, tag.name, len(matches))
+	}
+	return t.decode(matches[0], v)
+}
+
+// findRules returns the rules named name below a. When deep is set, nested
+// rules are searched too, except for the ones matching name.
+func findRules(a Atom, name string, deep bool) []RefResult {
+	var result []RefResult
+	for _, c := range a.Children() {
+		Walk(c, func(c Atom, enter bool) WalkAction {
+			ref, ok := c.(RefResult)
+			if !ok || !enter {
+				return WalkContinue
+			}
+			if ref.Name == name {
+				result = append(result, ref)
+				return WalkSkipChildren
+			}
+			if deep {
+				return WalkContinue
+			}
+			return WalkSkipChildren
+		})
+	}
+	return result
+}
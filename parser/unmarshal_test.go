@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var assignmentRules = MakeRules(map[string]Consumer{
+	"assignments": Cat(Ref("assignment"), Star(Cat(Lit(';'), Ref("assignment")))),
+	"assignment":  Cat(Ref("name"), Lit('='), Ref("value"), Opt(Ref("comment"))),
+	"name":        Plus(ALPHA),
+	"value":       Alt(Ref("number"), Ref("word")),
+	"number":      Plus(DIGIT),
+	"word":        Cat(DQUOTE, Star(ALPHA), DQUOTE),
+	"comment":     Cat(Lit('#'), Plus(ALPHA)),
+})
+
+type testValue interface{ isValue() }
+
+type testNumber struct {
+	Value int `goparse:"."`
+}
+
+func (testNumber) isValue() {}
+
+type testWord struct {
+	Quoted string `goparse:"."`
+}
+
+func (testWord) isValue() {}
+
+type testAssignment struct {
+	Name    string    `goparse:"name"`
+	Value   testValue `goparse:"value"`
+	Comment *string   `goparse:"comment"`
+	Span    Span      `goparse:"."`
+}
+
+type testAssignments struct {
+	Items []testAssignment `goparse:"assignment,repeat"`
+	Names []string         `goparse:"name,flatten"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	c := CursorFromString(`a=12;b="xy"#note`)
+	root, err := KickoffParser(&c, assignmentRules, "assignments")
+	require.NoError(t, err)
+
+	types := NewTypeRegistry()
+	types.Register("number", testNumber{})
+	types.Register("word", testWord{})
+
+	var v testAssignments
+	require.NoError(t, types.Unmarshal(root, &v))
+	note := "#note"
+	require.Equal(t, testAssignments{
+		Items: []testAssignment{
+			{Name: "a", Value: testNumber{Value: 12}, Span: Span{Start: 0, End: 4}},
+			{Name: "b", Value: testWord{Quoted: `"xy"`}, Comment: &note, Span: Span{Start: 5, End: 16}},
+		},
+		Names: []string{"a", "b"},
+	}, v)
+
+	var names struct {
+		Name  string `goparse:"name"`
+		Items []testAssignment
+	}
+	err = types.Unmarshal(root, &names)
+	require.EqualError(t, err, "assignments: field Name: missing name at position 0")
+
+	err = NewTypeRegistry().Unmarshal(root, &v)
+	require.EqualError(t, err, "assignments > assignment > value: no type registered implementing parser.testValue at position 2")
+
+	var bad struct {
+		Items []struct {
+			Name int `goparse:"name"`
+		} `goparse:"assignment"`
+	}
+	err = types.Unmarshal(root, &bad)
+	require.EqualError(t, err, `assignments > assignment > name: cannot unmarshal "a" into int at position 0`)
+	require.Error(t, types.Unmarshal(root, v))
+}
@@ -0,0 +1,481 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxUnparseDepth bounds how deep rules may nest while unparsing, so that
+// recursive rules without values to drive them fail instead of looping.
+const maxUnparseDepth = 512
+
+// Unparse renders v as text matching rule, using DefaultTypes. See
+// TypeRegistry.Unparse.
+func Unparse(rules map[string]Consumer, rule string, v interface{}) (string, error) {
+	return DefaultTypes.Unparse(rules, rule, v)
+}
+
+// Unparse renders v as text matching rule. v is either an Atom, whose
+// terminals are written back as long as the result re-parses to the same
+// tree, or a value shaped as expected by Unmarshal, in which case the grammar
+// drives the output: tagged fields provide the text of their rules, while
+// everything else is filled with canonical choices, such as the first
+// alternative of an alternation, a single SP for WSP, or nothing at all for
+// repetitions and options that hold no values.
+func (t *TypeRegistry) Unparse(rules map[string]Consumer, rule string, v interface{}) (string, error) {
+	rule = strings.ToLower(rule)
+	if a, ok := v.(Atom); ok {
+		text := renderText(a)
+		tree, err := parseFully(rules, rule, text)
+		if err != nil {
+			return "", err
+		}
+		if !sameTree(tree, a) {
+			return "", fmt.Errorf("%s: text %q does not reproduce the given tree", rule, text)
+		}
+		return text, nil
+	}
+
+	u := &unparser{rules: rules, types: t}
+	sb := strings.Builder{}
+	con, ok := rules[rule]
+	if !ok {
+		return "", fmt.Errorf("unknown rule %s", rule)
+	}
+	u.path = []string{rule}
+	if err := u.value(rule, con, reflect.ValueOf(v), &sb); err != nil {
+		return "", err
+	}
+	if _, err := parseFully(rules, rule, sb.String()); err != nil {
+		return "", fmt.Errorf("%s: produced text %q does not parse: %w", rule, sb.String(), err)
+	}
+	return sb.String(), nil
+}
+
+// sameTree reports whether a and b hold atoms of the same kinds, rules and
+// text, arranged in the same way.
+func sameTree(a, b Atom) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Kind() != b.Kind() || terminalText(a) != terminalText(b) {
+		return false
+	}
+	switch v := a.(type) {
+	case RefResult:
+		if w, ok := b.(RefResult); !ok || v.Name != w.Name {
+			return false
+		}
+	case OptionVal:
+		if w, ok := b.(OptionVal); !ok || v.Valid != w.Valid {
+			return false
+		}
+	}
+	ac, bc := a.Children(), b.Children()
+	if len(ac) != len(bc) {
+		return false
+	}
+	for i := range ac {
+		if !sameTree(ac[i], bc[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnparseError reports a value that could not be rendered by a rule.
+type UnparseError struct {
+	Path []string
+	Err  error
+	// missing indicates the error was caused by a value not being available,
+	// which options and repetitions treat as the end of their input.
+	missing bool
+}
+
+func (u *UnparseError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(u.Path, " > "), u.Err)
+}
+
+func (u *UnparseError) Unwrap() error { return u.Err }
+
+type unparser struct {
+	rules    map[string]Consumer
+	types    *TypeRegistry
+	path     []string
+	consumed int
+}
+
+// unparseFrame tracks which fields of a struct value were already rendered.
+// Frames created for interface values are pending until a rule registered
+// for their concrete type is reached.
+type unparseFrame struct {
+	value   reflect.Value
+	used    map[int]int
+	pending bool
+}
+
+type frameState struct {
+	used     map[int]int
+	pending  bool
+	consumed int
+}
+
+func (u *unparser) save(f *unparseFrame) frameState {
+	s := frameState{consumed: u.consumed}
+	if f != nil {
+		s.used = make(map[int]int, len(f.used))
+		for k, v := range f.used {
+			s.used[k] = v
+		}
+		s.pending = f.pending
+	}
+	return s
+}
+
+func (u *unparser) restore(f *unparseFrame, s frameState) {
+	u.consumed = s.consumed
+	if f != nil {
+		f.used = s.used
+		f.pending = s.pending
+	}
+}
+
+func (u *unparser) error(missing bool, format string, args ...interface{}) *UnparseError {
+	return &UnparseError{
+		Path:    append([]string(nil), u.path...),
+		Err:     fmt.Errorf(format, args...),
+		missing: missing,
+	}
+}
+
+func (u *unparser) gen(con Consumer, f *unparseFrame, sb *strings.Builder) error {
+	switch c := con.(type) {
+	case *RefConsumer:
+		return u.ref(c.name, f, sb)
+	case *ConcatenationConsumer:
+		for _, inner := range c.cons {
+			if err := u.gen(inner, f, sb); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *AlternationConsumer:
+		return u.alternation(c, f, sb)
+	case *OptionalConsumer:
+		state := u.save(f)
+		inner := strings.Builder{}
+		if err := u.gen(c.con, f, &inner); err == nil && u.consumed > state.consumed {
+			sb.WriteString(inner.String())
+		} else if uerr, ok := err.(*UnparseError); ok && !uerr.missing {
+			return err
+		} else {
+			u.restore(f, state)
+		}
+		return nil
+	case *RepetitionConsumer:
+		return u.repetition(c, f, sb)
+	case *BlankConsumer:
+		return u.gen(c.con, f, sb)
+	case *ShapedConsumer:
+		return u.gen(c.con, f, sb)
+	case *LabelConsumer:
+		return u.gen(c.con, f, sb)
+	}
+
+	r, ok := canonicalRune(con)
+	if !ok {
+		return u.error(false, "cannot unparse %s", con.Name())
+	}
+	sb.WriteRune(r)
+	return nil
+}
+
+// canonicalRune returns the rune written for a terminal consumer.
+func canonicalRune(con Consumer) (rune, bool) {
+	switch c := con.(type) {
+	case *LitConsumer:
+		return c.lit, true
+	case *HexRangeConsumer:
+		return c.from, true
+	case *CharClassConsumer:
+		if len(c.ranges) > 0 {
+			return c.ranges[0].From, true
+		}
+	case *DecimalConsumer:
+		return rune(c.v), true
+	case *DecRangeConsumer:
+		return rune(c.from), true
+	case *AlphaConsumer:
+		return 'a', true
+	case *BitConsumer:
+		return '0', true
+	case *CharConsumer:
+		return 0x01, true
+	case *CRConsumer:
+		return '\r', true
+	case *LFConsumer:
+		return '\n', true
+	case *CtlConsumer:
+		return 0x00, true
+	case *DigitConsumer:
+		return '0', true
+	case *DQuoteConsumer:
+		return '"', true
+	case *HTabConsumer:
+		return '\t', true
+	case *OctetConsumer:
+		return 0x00, true
+	case *SPConsumer:
+		return ' ', true
+	case *VCharConsumer:
+		return '!', true
+	}
+	return 0, false
+}
+
+// alternation renders the first alternative consuming values, or the first
+// one succeeding when none of them does.
+func (u *unparser) alternation(c *AlternationConsumer, f *unparseFrame, sb *strings.Builder) error {
+	state := u.save(f)
+	var fallback *string
+	var lastErr error
+	for _, alt := range c.cons {
+		inner := strings.Builder{}
+		err := u.gen(alt, f, &inner)
+		if err == nil && u.consumed > state.consumed {
+			sb.WriteString(inner.String())
+			return nil
+		}
+		if err == nil && fallback == nil {
+			text := inner.String()
+			fallback = &text
+		}
+		if err != nil && (lastErr == nil || len(err.(*UnparseError).Path) >= len(lastErr.(*UnparseError).Path)) {
+			lastErr = err
+		}
+		u.restore(f, state)
+	}
+	if fallback != nil {
+		sb.WriteString(*fallback)
+		return nil
+	}
+	return lastErr
+}
+
+func (u *unparser) repetition(c *RepetitionConsumer, f *unparseFrame, sb *strings.Builder) error {
+	min, max := c.bounds()
+
+	count := 0
+	for max == 0 || count < max {
+		state := u.save(f)
+		inner := strings.Builder{}
+		err := u.gen(c.con, f, &inner)
+		if uerr, ok := err.(*UnparseError); ok && !uerr.missing {
+			return err
+		}
+		if err != nil || u.consumed == state.consumed {
+			u.restore(f, state)
+			break
+		}
+		sb.WriteString(inner.String())
+		count++
+	}
+	for ; count < min; count++ {
+		if err := u.gen(c.con, f, sb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unparser) ref(name string, f *unparseFrame, sb *strings.Builder) error {
+	con, ok := u.rules[name]
+	if !ok {
+		return u.error(false, "unknown rule %s", name)
+	}
+	if len(u.path) >= maxUnparseDepth {
+		return u.error(false, "rules nest too deeply")
+	}
+	u.path = append(u.path, name)
+	defer func() { u.path = u.path[:len(u.path)-1] }()
+
+	if f == nil {
+		return u.gen(con, f, sb)
+	}
+
+	if f.pending {
+		if u.types.registered(name, f.value.Type()) {
+			f.pending = false
+			u.consumed++
+			return u.value(name, con, f.value, sb)
+		}
+		return u.gen(con, f, sb)
+	}
+
+	idx, field, ok := fieldForRule(f.value.Type(), name)
+	if !ok {
+		return u.gen(con, f, sb)
+	}
+	fv := f.value.Field(idx)
+	if field.Type.Kind() == reflect.Slice && field.Type != reflect.TypeOf([]byte(nil)) {
+		n := f.used[idx]
+		if n >= fv.Len() {
+			return u.error(true, "no values left in field %s", field.Name)
+		}
+		f.used[idx]++
+		u.consumed++
+		return u.value(name, con, fv.Index(n), sb)
+	}
+	if f.used[idx] > 0 {
+		return u.error(true, "field %s was already used", field.Name)
+	}
+	if isAbsent(fv) {
+		return u.error(true, "field %s holds no value", field.Name)
+	}
+	f.used[idx] = 1
+	u.consumed++
+	return u.value(name, con, fv, sb)
+}
+
+// value renders v as the contents of the rule name, whose consumer is con.
+func (u *unparser) value(name string, con Consumer, v reflect.Value, sb *strings.Builder) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return u.error(true, "nil value")
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(atomType) || v.Type() == refResultType {
+		return u.leaf(name, renderText(v.Interface().(Atom)), sb)
+	}
+	if text, ok := leafText(v); ok {
+		return u.leaf(name, text, sb)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return u.gen(con, nil, sb)
+	case reflect.Interface:
+		if v.IsNil() {
+			return u.error(true, "nil value")
+		}
+		concrete := v.Elem()
+		if u.types.registered(name, concrete.Type()) {
+			return u.value(name, con, concrete, sb)
+		}
+		f := &unparseFrame{value: concrete, used: map[int]int{}, pending: true}
+		if err := u.gen(con, f, sb); err != nil {
+			return err
+		}
+		if f.pending {
+			return u.error(false, "no rule registered for %s", concrete.Type())
+		}
+		return nil
+	case reflect.Struct:
+		if idx, _, ok := fieldForRule(v.Type(), "."); ok {
+			if text, ok := leafText(v.Field(idx)); ok {
+				return u.leaf(name, text, sb)
+			}
+		}
+		f := &unparseFrame{value: v, used: map[int]int{}}
+		if err := u.gen(con, f, sb); err != nil {
+			return err
+		}
+		return u.checkUsed(f)
+	}
+	return u.error(false, "cannot unparse %s", v.Type())
+}
+
+// leaf writes text after making sure it matches the rule name.
+func (u *unparser) leaf(name, text string, sb *strings.Builder) error {
+	if _, err := parseFully(u.rules, name, text); err != nil {
+		return u.error(false, "value %q cannot be produced by rule %s: %s", text, name, err)
+	}
+	sb.WriteString(text)
+	return nil
+}
+
+// checkUsed ensures every value held by the frame was rendered.
+func (u *unparser) checkUsed(f *unparseFrame) error {
+	typ := f.value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := unparseTag(field)
+		if !ok || tag.name == "." || tag.flatten {
+			continue
+		}
+		fv := f.value.Field(i)
+		if field.Type.Kind() == reflect.Slice && field.Type != reflect.TypeOf([]byte(nil)) {
+			if f.used[i] < fv.Len() {
+				return u.error(false, "field %s holds %d values, but only %d could be used", field.Name, fv.Len(), f.used[i])
+			}
+		} else if f.used[i] == 0 && !isAbsent(fv) {
+			return u.error(false, "field %s could not be used", field.Name)
+		}
+	}
+	return nil
+}
+
+func (t *TypeRegistry) registered(rule string, typ reflect.Type) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, v := range t.types[rule] {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func unparseTag(field reflect.StructField) (fieldTag, bool) {
+	raw, ok := field.Tag.Lookup("goparse")
+	if !ok || raw == "-" || field.PkgPath != "" || field.Type == spanType {
+		return fieldTag{}, false
+	}
+	tag, err := parseFieldTag(raw)
+	return tag, err == nil
+}
+
+func fieldForRule(typ reflect.Type, name string) (int, reflect.StructField, bool) {
+	if typ.Kind() != reflect.Struct {
+		return 0, reflect.StructField{}, false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if tag, ok := unparseTag(field); ok && tag.name == name && !tag.flatten {
+			return i, field, true
+		}
+	}
+	return 0, reflect.StructField{}, false
+}
+
+func isAbsent(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	}
+	return false
+}
+
+func leafText(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), true
+	case reflect.Slice:
+		if v.Type() == reflect.TypeOf([]byte(nil)) {
+			return string(v.Bytes()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnparse(t *testing.T) {
+	types := NewTypeRegistry()
+	types.Register("number", testNumber{})
+	types.Register("word", testWord{})
+
+	note := "#note"
+	v := testAssignments{
+		Items: []testAssignment{
+			{Name: "a", Value: testNumber{Value: 12}},
+			{Name: "b", Value: testWord{Quoted: `"xy"`}, Comment: &note},
+		},
+	}
+	text, err := types.Unparse(assignmentRules, "assignments", v)
+	require.NoError(t, err)
+	require.Equal(t, `a=12;b="xy"#note`, text)
+
+	c := CursorFromString(text)
+	root, err := KickoffParser(&c, assignmentRules, "assignments")
+	require.NoError(t, err)
+	var back testAssignments
+	require.NoError(t, types.Unmarshal(root, &back))
+	require.Equal(t, v.Items[1].Value, back.Items[1].Value)
+
+	text, err = types.Unparse(assignmentRules, "assignments", root)
+	require.NoError(t, err)
+	require.Equal(t, `a=12;b="xy"#note`, text)
+
+	_, err = types.Unparse(assignmentRules, "assignments", testAssignments{
+		Items: []testAssignment{{Name: "a1", Value: testNumber{Value: 1}}},
+	})
+	require.EqualError(t, err, `assignments > assignment > name: value "a1" cannot be produced by rule name: Expected end of input at position 1`)
+
+	_, err = types.Unparse(assignmentRules, "assignments", testAssignments{})
+	require.Error(t, err)
+
+	_, err = NewTypeRegistry().Unparse(assignmentRules, "assignment", testAssignment{Name: "a", Value: testNumber{Value: 1}})
+	require.Error(t, err)
+}
+
+func TestUnparseTree(t *testing.T) {
+	pair := parsePairs(t, "pair", "ab=12")
+	text, err := Unparse(pairRules, "pair", pair)
+	require.NoError(t, err)
+	require.Equal(t, "ab=12", text)
+
+	_, err = Unparse(pairRules, "pairs", pair)
+	require.EqualError(t, err, `pairs: text "ab=12" does not reproduce the given tree`)
+
+	require.True(t, sameTree(pair, parsePairs(t, "pair", "ab=12")))
+	require.False(t, sameTree(pair, parsePairs(t, "pair", "ab=13")))
+	require.False(t, sameTree(pair, parsePairs(t, "pair", "abc=12")))
+	require.False(t, sameTree(pair, nil))
+	require.True(t, sameTree(nil, nil))
+}
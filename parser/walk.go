@@ -0,0 +1,193 @@
+package parser
+
+import "strings"
+
+type WalkAction int
+
+const (
+	// WalkContinue proceeds to the next atom, descending into children.
+	WalkContinue WalkAction = iota
+	// WalkSkipChildren proceeds to the next atom without descending into the
+	// children of the current one. It only has effect when entering an atom.
+	WalkSkipChildren
+	// WalkStop aborts the walk.
+	WalkStop
+)
+
+type WalkFunc func(a Atom, enter bool) WalkAction
+
+// Walk traverses the tree rooted at root in depth-first order, calling fn
+// once when entering each atom and once again when leaving it. Nil atoms are
+// not visited.
+func Walk(root Atom, fn WalkFunc) {
+	walk(root, fn)
+}
+
+func walk(a Atom, fn WalkFunc) bool {
+	if a == nil {
+		return true
+	}
+	switch fn(a, true) {
+	case WalkStop:
+		return false
+	case WalkSkipChildren:
+	default:
+		for _, c := range a.Children() {
+			if !walk(c, fn) {
+				return false
+			}
+		}
+	}
+	return fn(a, false) != WalkStop
+}
+
+type Visitor interface {
+	VisitAlpha(Alpha) WalkAction
+	VisitBit(Bit) WalkAction
+	VisitChar(Char) WalkAction
+	VisitCR(CRVal) WalkAction
+	VisitLF(LFVal) WalkAction
+	VisitCtl(Ctl) WalkAction
+	VisitDigit(Digit) WalkAction
+	VisitDQuote(DQuote) WalkAction
+	VisitHTab(HTab) WalkAction
+	VisitOctet(Octet) WalkAction
+	VisitSP(SPVal) WalkAction
+	VisitVChar(VChar) WalkAction
+	VisitToken(TokenVal) WalkAction
+	VisitOption(OptionVal) WalkAction
+	VisitAtomList(AtomList) WalkAction
+	VisitRefResult(RefResult) WalkAction
+}
+
+// BaseVisitor implements Visitor by continuing on every atom. It is meant to
+// be embedded by visitors that only care about a few kinds.
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitAlpha(Alpha) WalkAction         { return WalkContinue }
+func (BaseVisitor) VisitBit(Bit) WalkAction             { return WalkContinue }
+func (BaseVisitor) VisitChar(Char) WalkAction           { return WalkContinue }
+func (BaseVisitor) VisitCR(CRVal) WalkAction            { return WalkContinue }
+func (BaseVisitor) VisitLF(LFVal) WalkAction            { return WalkContinue }
+func (BaseVisitor) VisitCtl(Ctl) WalkAction             { return WalkContinue }
+func (BaseVisitor) VisitDigit(Digit) WalkAction         { return WalkContinue }
+func (BaseVisitor) VisitDQuote(DQuote) WalkAction       { return WalkContinue }
+func (BaseVisitor) VisitHTab(HTab) WalkAction           { return WalkContinue }
+func (BaseVisitor) VisitOctet(Octet) WalkAction         { return WalkContinue }
+func (BaseVisitor) VisitSP(SPVal) WalkAction            { return WalkContinue }
+func (BaseVisitor) VisitVChar(VChar) WalkAction         { return WalkContinue }
+func (BaseVisitor) VisitToken(TokenVal) WalkAction      { return WalkContinue }
+func (BaseVisitor) VisitOption(OptionVal) WalkAction    { return WalkContinue }
+func (BaseVisitor) VisitAtomList(AtomList) WalkAction   { return WalkContinue }
+func (BaseVisitor) VisitRefResult(RefResult) WalkAction { return WalkContinue }
+
+// Visit walks the tree rooted at root, dispatching each atom to the callback
+// of v matching its kind.
+func Visit(root Atom, v Visitor) {
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if !enter {
+			return WalkContinue
+		}
+		switch i := a.(type) {
+		case Alpha:
+			return v.VisitAlpha(i)
+		case Bit:
+			return v.VisitBit(i)
+		case Char:
+			return v.VisitChar(i)
+		case CRVal:
+			return v.VisitCR(i)
+		case LFVal:
+			return v.VisitLF(i)
+		case Ctl:
+			return v.VisitCtl(i)
+		case Digit:
+			return v.VisitDigit(i)
+		case DQuote:
+			return v.VisitDQuote(i)
+		case HTab:
+			return v.VisitHTab(i)
+		case Octet:
+			return v.VisitOctet(i)
+		case SPVal:
+			return v.VisitSP(i)
+		case VChar:
+			return v.VisitVChar(i)
+		case TokenVal:
+			return v.VisitToken(i)
+		case OptionVal:
+			return v.VisitOption(i)
+		case AtomList:
+			return v.VisitAtomList(i)
+		case RefResult:
+			return v.VisitRefResult(i)
+		}
+		return WalkContinue
+	})
+}
+
+// FindAll returns every RefResult named ruleName within root, including root
+// itself, in document order.
+func FindAll(root Atom, ruleName string) []RefResult {
+	var result []RefResult
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if ref, ok := a.(RefResult); ok && enter && ref.Name == ruleName {
+			result = append(result, ref)
+		}
+		return WalkContinue
+	})
+	return result
+}
+
+// FindFirst returns the first RefResult named ruleName within root, in
+// document order.
+func FindFirst(root Atom, ruleName string) (RefResult, bool) {
+	var result RefResult
+	found := false
+	Walk(root, func(a Atom, enter bool) WalkAction {
+		if ref, ok := a.(RefResult); ok && enter && ref.Name == ruleName {
+			result, found = ref, true
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	return result, found
+}
+
+// Text returns the source text matched by the subtree rooted at a.
+func Text(a Atom) string {
+	if a == nil {
+		return ""
+	}
+	if src := sourceOf(a); src != nil {
+		span := a.Span()
+		return string(src[span.Start:span.End])
+	}
+	return renderText(a)
+}
+
+// renderText rebuilds the text of a from its terminals, without looking at
+// the input it was parsed from.
+func renderText(a Atom) string {
+	str := strings.Builder{}
+	Walk(a, func(a Atom, enter bool) WalkAction {
+		if enter {
+			str.WriteString(terminalText(a))
+		}
+		return WalkContinue
+	})
+	return str.String()
+}
+
+func terminalText(a Atom) string {
+	switch v := a.(type) {
+	case Octet:
+		return string(v.value)
+	case OptionVal, AtomList, RefResult:
+		return ""
+	}
+	if s, ok := a.Value().(string); ok {
+		return s
+	}
+	return ""
+}
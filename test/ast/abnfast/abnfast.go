@@ -0,0 +1,846 @@
+// Code generated by goparse. DO NOT EDIT.
+
+package abnfast
+
+import (
+	"fmt"
+	"sync"
+
+	p "github.com/heyvito/goparse/parser"
+)
+
+var parser = map[string]p.Consumer{
+	"rulelist":      p.Plus(p.Alt(p.Ref("rule"), p.Cat(p.Star(p.Ref("c-wsp")), p.Ref("c-nl")))),
+	"rule":          p.Cat(p.Ref("rulename"), p.Ref("defined-as"), p.Ref("elements"), p.Ref("c-nl")),
+	"rulename":      p.Cat(p.ALPHA, p.Star(p.Alt(p.ALPHA, p.DIGIT, p.Lit('-')))),
+	"defined-as":    p.Cat(p.Star(p.Ref("c-wsp")), p.Alt(p.Lit('='), p.Str("=/")), p.Star(p.Ref("c-wsp"))),
+	"elements":      p.Cat(p.Ref("alternation"), p.Star(p.Ref("c-wsp"))),
+	"c-wsp":         p.Alt(p.WSP, p.Cat(p.Ref("c-nl"), p.WSP)),
+	"c-nl":          p.Alt(p.Ref("comment"), p.CRLF),
+	"comment":       p.Cat(p.Lit(';'), p.Star(p.Alt(p.WSP, p.VCHAR)), p.CRLF),
+	"alternation":   p.Cat(p.Ref("concatenation"), p.Star(p.Cat(p.Star(p.Ref("c-wsp")), p.Lit('/'), p.Star(p.Ref("c-wsp")), p.Ref("concatenation")))),
+	"concatenation": p.Cat(p.Ref("repetition"), p.Star(p.Cat(p.Plus(p.Ref("c-wsp")), p.Ref("repetition")))),
+	"repetition":    p.Cat(p.Opt(p.Ref("repeat")), p.Ref("element")),
+	"repeat":        p.Alt(p.Plus(p.DIGIT), p.Cat(p.Star(p.DIGIT), p.Lit('*'), p.Star(p.DIGIT))),
+	"element":       p.Alt(p.Ref("rulename"), p.Ref("group"), p.Ref("option"), p.Ref("char-val"), p.Ref("num-val"), p.Ref("prose-val")),
+	"group":         p.Cat(p.Lit('('), p.Star(p.Ref("c-wsp")), p.Ref("alternation"), p.Star(p.Ref("c-wsp")), p.Lit(')')),
+	"option":        p.Cat(p.Lit('['), p.Star(p.Ref("c-wsp")), p.Ref("alternation"), p.Star(p.Ref("c-wsp")), p.Lit(']')),
+	"char-val":      p.Cat(p.DQUOTE, p.Star(p.CharClass(p.CharRange{From: 0x20, To: 0x21}, p.CharRange{From: 0x23, To: 0x7e})), p.DQUOTE),
+	"num-val":       p.Cat(p.Lit('%'), p.Alt(p.Ref("bin-val"), p.Ref("dec-val"), p.Ref("hex-val"))),
+	"bin-val":       p.Cat(p.Lit('b'), p.Plus(p.BIT), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.BIT))), p.Cat(p.Lit('-'), p.Plus(p.BIT))))),
+	"dec-val":       p.Cat(p.Lit('d'), p.Plus(p.DIGIT), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.DIGIT))), p.Cat(p.Lit('-'), p.Plus(p.DIGIT))))),
+	"hex-val":       p.Cat(p.Lit('x'), p.Plus(p.HEXDIG), p.Opt(p.Alt(p.Plus(p.Cat(p.Lit('.'), p.Plus(p.HEXDIG))), p.Cat(p.Lit('-'), p.Plus(p.HEXDIG))))),
+	"prose-val":     p.Cat(p.Lit('<'), p.Star(p.CharClass(p.CharRange{From: 0x20, To: 0x3d}, p.CharRange{From: 0x3f, To: 0x7e})), p.Lit('>')),
+}
+
+// Names of the rules of the grammar.
+const (
+	RuleRulelist      = "rulelist"
+	RuleRule          = "rule"
+	RuleRulename      = "rulename"
+	RuleDefinedAs     = "defined-as"
+	RuleElements      = "elements"
+	RuleCWsp          = "c-wsp"
+	RuleCNl           = "c-nl"
+	RuleComment       = "comment"
+	RuleAlternation   = "alternation"
+	RuleConcatenation = "concatenation"
+	RuleRepetition    = "repetition"
+	RuleRepeat        = "repeat"
+	RuleElement       = "element"
+	RuleGroup         = "group"
+	RuleOption        = "option"
+	RuleCharVal       = "char-val"
+	RuleNumVal        = "num-val"
+	RuleBinVal        = "bin-val"
+	RuleDecVal        = "dec-val"
+	RuleHexVal        = "hex-val"
+	RuleProseVal      = "prose-val"
+)
+
+// Reducers holds a method reducing each rule of the grammar.
+type Reducers interface {
+	Rulelist(ctx *p.ReducerContext) interface{}
+	Rule(ctx *p.ReducerContext) interface{}
+	Rulename(ctx *p.ReducerContext) interface{}
+	DefinedAs(ctx *p.ReducerContext) interface{}
+	Elements(ctx *p.ReducerContext) interface{}
+	CWsp(ctx *p.ReducerContext) interface{}
+	CNl(ctx *p.ReducerContext) interface{}
+	Comment(ctx *p.ReducerContext) interface{}
+	Alternation(ctx *p.ReducerContext) interface{}
+	Concatenation(ctx *p.ReducerContext) interface{}
+	Repetition(ctx *p.ReducerContext) interface{}
+	Repeat(ctx *p.ReducerContext) interface{}
+	Element(ctx *p.ReducerContext) interface{}
+	Group(ctx *p.ReducerContext) interface{}
+	Option(ctx *p.ReducerContext) interface{}
+	CharVal(ctx *p.ReducerContext) interface{}
+	NumVal(ctx *p.ReducerContext) interface{}
+	BinVal(ctx *p.ReducerContext) interface{}
+	DecVal(ctx *p.ReducerContext) interface{}
+	HexVal(ctx *p.ReducerContext) interface{}
+	ProseVal(ctx *p.ReducerContext) interface{}
+}
+
+// ReducerMap returns the methods of r keyed by the rules they reduce.
+func ReducerMap(r Reducers) map[string]p.Reducer {
+	return map[string]p.Reducer{
+		RuleRulelist:      r.Rulelist,
+		RuleRule:          r.Rule,
+		RuleRulename:      r.Rulename,
+		RuleDefinedAs:     r.DefinedAs,
+		RuleElements:      r.Elements,
+		RuleCWsp:          r.CWsp,
+		RuleCNl:           r.CNl,
+		RuleComment:       r.Comment,
+		RuleAlternation:   r.Alternation,
+		RuleConcatenation: r.Concatenation,
+		RuleRepetition:    r.Repetition,
+		RuleRepeat:        r.Repeat,
+		RuleElement:       r.Element,
+		RuleGroup:         r.Group,
+		RuleOption:        r.Option,
+		RuleCharVal:       r.CharVal,
+		RuleNumVal:        r.NumVal,
+		RuleBinVal:        r.BinVal,
+		RuleDecVal:        r.DecVal,
+		RuleHexVal:        r.HexVal,
+		RuleProseVal:      r.ProseVal,
+	}
+}
+
+// Rules returns the rules of the grammar along with the core rules, for use
+// with p.New or by other grammars.
+func Rules() map[string]p.Consumer {
+	return p.MakeRules(parser)
+}
+
+var (
+	parserOnce   sync.Once
+	sharedParser *p.Parser
+)
+
+// rulesParser returns the parser used by the Parse and Match functions.
+func rulesParser() *p.Parser {
+	parserOnce.Do(func() {
+		sharedParser = p.New(Rules())
+	})
+	return sharedParser
+}
+
+// ParseRulelist parses input as rulelist, the whole of which must match.
+func ParseRulelist(input string) (p.Atom, error) {
+	return rulesParser().Parse(RuleRulelist, input)
+}
+
+// MatchRulelist reports whether the whole of input matches rulelist.
+func MatchRulelist(input string) bool {
+	return rulesParser().Match(RuleRulelist, input)
+}
+
+// Rulelist is the value of rulelist.
+type Rulelist []RulelistItem
+
+type RulelistItem interface {
+	isRulelistItem()
+}
+
+type RulelistItem2 struct {
+	CWsp []CWsp
+	CNl  CNl
+}
+
+// Rule is the value of rule.
+type Rule struct {
+	Rulename  Rulename
+	DefinedAs DefinedAs
+	Elements  Elements
+	CNl       CNl
+}
+
+// Rulename is the text matched by rulename.
+type Rulename string
+
+// DefinedAs is the text matched by defined-as.
+type DefinedAs string
+
+// Elements is the value of elements.
+type Elements struct {
+	Alternation Alternation
+	CWsp        []CWsp
+}
+
+// CWsp is the text matched by c-wsp.
+type CWsp string
+
+// CNl is the text matched by c-nl.
+type CNl string
+
+// Comment is the text matched by comment.
+type Comment string
+
+// Alternation is the value of alternation.
+type Alternation struct {
+	Concatenation Concatenation
+	Item2         []AlternationItem2
+}
+
+type AlternationItem2 struct {
+	CWsp          []CWsp
+	CWsp2         []CWsp
+	Concatenation Concatenation
+}
+
+// Concatenation is the value of concatenation.
+type Concatenation struct {
+	Repetition Repetition
+	Item2      []ConcatenationItem2
+}
+
+type ConcatenationItem2 struct {
+	CWsp       []CWsp
+	Repetition Repetition
+}
+
+// Repetition is the value of repetition.
+type Repetition struct {
+	Repeat  *Repeat
+	Element Element
+}
+
+// Repeat is the text matched by repeat.
+type Repeat string
+
+// Element is the value of element.
+type Element interface {
+	isElement()
+}
+
+// Group is the value of group.
+type Group struct {
+	CWsp        []CWsp
+	Alternation Alternation
+	CWsp2       []CWsp
+}
+
+// Option is the value of option.
+type Option struct {
+	CWsp        []CWsp
+	Alternation Alternation
+	CWsp2       []CWsp
+}
+
+// CharVal is the text matched by char-val.
+type CharVal string
+
+// NumVal is the text matched by num-val.
+type NumVal string
+
+// BinVal is the text matched by bin-val.
+type BinVal string
+
+// DecVal is the text matched by dec-val.
+type DecVal string
+
+// HexVal is the text matched by hex-val.
+type HexVal string
+
+// ProseVal is the text matched by prose-val.
+type ProseVal string
+
+func (Rulename) isElement() {}
+func (Group) isElement()    {}
+func (Option) isElement()   {}
+func (CharVal) isElement()  {}
+func (NumVal) isElement()   {}
+func (ProseVal) isElement() {}
+
+func (Rule) isRulelistItem()          {}
+func (RulelistItem2) isRulelistItem() {}
+
+// ASTReducers builds the types above from the trees of their rules.
+var ASTReducers = map[string]p.ReducerE{
+	"rulelist": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildRulelist(ctx, a)
+		return v, r.err
+	},
+	"rule": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildRule(ctx, a)
+		return v, r.err
+	},
+	"rulename": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildRulename(ctx, a)
+		return v, r.err
+	},
+	"defined-as": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildDefinedAs(ctx, a)
+		return v, r.err
+	},
+	"elements": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildElements(ctx, a)
+		return v, r.err
+	},
+	"c-wsp": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildCWsp(ctx, a)
+		return v, r.err
+	},
+	"c-nl": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildCNl(ctx, a)
+		return v, r.err
+	},
+	"comment": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildComment(ctx, a)
+		return v, r.err
+	},
+	"alternation": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildAlternation(ctx, a)
+		return v, r.err
+	},
+	"concatenation": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildConcatenation(ctx, a)
+		return v, r.err
+	},
+	"repetition": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildRepetition(ctx, a)
+		return v, r.err
+	},
+	"repeat": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildRepeat(ctx, a)
+		return v, r.err
+	},
+	"element": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildElement(ctx, a)
+		return v, r.err
+	},
+	"group": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildGroup(ctx, a)
+		return v, r.err
+	},
+	"option": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildOption(ctx, a)
+		return v, r.err
+	},
+	"char-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildCharVal(ctx, a)
+		return v, r.err
+	},
+	"num-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildNumVal(ctx, a)
+		return v, r.err
+	},
+	"bin-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildBinVal(ctx, a)
+		return v, r.err
+	},
+	"dec-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildDecVal(ctx, a)
+		return v, r.err
+	},
+	"hex-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildHexVal(ctx, a)
+		return v, r.err
+	},
+	"prose-val": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildProseVal(ctx, a)
+		return v, r.err
+	},
+}
+
+var astAlternations = []*p.AlternationConsumer{
+	p.Alt(p.Ref("rule"), p.Cat(p.Star(p.Ref("c-wsp")), p.Ref("c-nl"))),
+	p.Alt(p.Ref("rulename"), p.Ref("group"), p.Ref("option"), p.Ref("char-val"), p.Ref("num-val"), p.Ref("prose-val")),
+}
+
+// astReduction holds the state of a reducer of ASTReducers.
+type astReduction struct {
+	ctx *p.ReducerContext
+	err error
+}
+
+func (r *astReduction) reduce(a p.Atom) interface{} {
+	v, err := r.ctx.ReduceE(a)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *astReduction) mismatch(a p.Atom) {
+	if a == nil {
+		r.err = fmt.Errorf("missing value")
+	} else if r.err == nil {
+		r.err = fmt.Errorf("unexpected %s at position %d", a.Kind(), a.Span().Start)
+	}
+}
+
+func (r *astReduction) asRule(a p.Atom) Rule {
+	v, _ := r.reduce(a).(Rule)
+	return v
+}
+
+func (r *astReduction) asRulelistItem(a p.Atom) RulelistItem {
+	v, _ := r.reduce(a).(RulelistItem)
+	return v
+}
+
+func (r *astReduction) asCWsp(a p.Atom) CWsp {
+	v, _ := r.reduce(a).(CWsp)
+	return v
+}
+
+func (r *astReduction) buildRulelist_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) asCNl(a p.Atom) CNl {
+	v, _ := r.reduce(a).(CNl)
+	return v
+}
+
+func (r *astReduction) buildRulelist_2(a p.Atom) RulelistItem2 {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return RulelistItem2{}
+	}
+	return RulelistItem2{
+		CWsp: r.buildRulelist_1(l[0]),
+		CNl:  r.asCNl(l[1]),
+	}
+}
+
+func (r *astReduction) buildRulelist_3(a p.Atom) RulelistItem {
+	switch p.Alternative(parser, astAlternations[0], a) {
+	case 0:
+		return r.asRulelistItem(a)
+	case 1:
+		return r.buildRulelist_2(a)
+	}
+	r.mismatch(a)
+	return nil
+}
+
+func (r *astReduction) buildRulelist_4(a p.Atom) Rulelist {
+	var v Rulelist
+	for _, c := range a.Children() {
+		v = append(v, r.buildRulelist_3(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildRulelist(ctx *p.ReducerContext, a p.Atom) Rulelist {
+	return r.buildRulelist_4(a)
+}
+
+func (r *astReduction) asRulename(a p.Atom) Rulename {
+	v, _ := r.reduce(a).(Rulename)
+	return v
+}
+
+func (r *astReduction) asDefinedAs(a p.Atom) DefinedAs {
+	v, _ := r.reduce(a).(DefinedAs)
+	return v
+}
+
+func (r *astReduction) asElements(a p.Atom) Elements {
+	v, _ := r.reduce(a).(Elements)
+	return v
+}
+
+func (r *astReduction) buildRule_1(a p.Atom) Rule {
+	l := a.Children()
+	if len(l) != 4 {
+		r.mismatch(a)
+		return Rule{}
+	}
+	return Rule{
+		Rulename:  r.asRulename(l[0]),
+		DefinedAs: r.asDefinedAs(l[1]),
+		Elements:  r.asElements(l[2]),
+		CNl:       r.asCNl(l[3]),
+	}
+}
+
+func (r *astReduction) buildRule(ctx *p.ReducerContext, a p.Atom) Rule {
+	return r.buildRule_1(a)
+}
+
+func (r *astReduction) buildRulename(ctx *p.ReducerContext, a p.Atom) Rulename {
+	return Rulename(ctx.Text())
+}
+
+func (r *astReduction) buildDefinedAs(ctx *p.ReducerContext, a p.Atom) DefinedAs {
+	return DefinedAs(ctx.Text())
+}
+
+func (r *astReduction) asAlternation(a p.Atom) Alternation {
+	v, _ := r.reduce(a).(Alternation)
+	return v
+}
+
+func (r *astReduction) buildElements_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildElements_2(a p.Atom) Elements {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return Elements{}
+	}
+	return Elements{
+		Alternation: r.asAlternation(l[0]),
+		CWsp:        r.buildElements_1(l[1]),
+	}
+}
+
+func (r *astReduction) buildElements(ctx *p.ReducerContext, a p.Atom) Elements {
+	return r.buildElements_2(a)
+}
+
+func (r *astReduction) buildCWsp(ctx *p.ReducerContext, a p.Atom) CWsp {
+	return CWsp(ctx.Text())
+}
+
+func (r *astReduction) buildCNl(ctx *p.ReducerContext, a p.Atom) CNl {
+	return CNl(ctx.Text())
+}
+
+func (r *astReduction) buildComment(ctx *p.ReducerContext, a p.Atom) Comment {
+	return Comment(ctx.Text())
+}
+
+func (r *astReduction) asConcatenation(a p.Atom) Concatenation {
+	v, _ := r.reduce(a).(Concatenation)
+	return v
+}
+
+func (r *astReduction) buildAlternation_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildAlternation_2(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildAlternation_3(a p.Atom) AlternationItem2 {
+	l := a.Children()
+	if len(l) != 4 {
+		r.mismatch(a)
+		return AlternationItem2{}
+	}
+	return AlternationItem2{
+		CWsp:          r.buildAlternation_1(l[0]),
+		CWsp2:         r.buildAlternation_2(l[2]),
+		Concatenation: r.asConcatenation(l[3]),
+	}
+}
+
+func (r *astReduction) buildAlternation_4(a p.Atom) []AlternationItem2 {
+	var v []AlternationItem2
+	for _, c := range a.Children() {
+		v = append(v, r.buildAlternation_3(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildAlternation_5(a p.Atom) Alternation {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return Alternation{}
+	}
+	return Alternation{
+		Concatenation: r.asConcatenation(l[0]),
+		Item2:         r.buildAlternation_4(l[1]),
+	}
+}
+
+func (r *astReduction) buildAlternation(ctx *p.ReducerContext, a p.Atom) Alternation {
+	return r.buildAlternation_5(a)
+}
+
+func (r *astReduction) asRepetition(a p.Atom) Repetition {
+	v, _ := r.reduce(a).(Repetition)
+	return v
+}
+
+func (r *astReduction) buildConcatenation_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildConcatenation_2(a p.Atom) ConcatenationItem2 {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return ConcatenationItem2{}
+	}
+	return ConcatenationItem2{
+		CWsp:       r.buildConcatenation_1(l[0]),
+		Repetition: r.asRepetition(l[1]),
+	}
+}
+
+func (r *astReduction) buildConcatenation_3(a p.Atom) []ConcatenationItem2 {
+	var v []ConcatenationItem2
+	for _, c := range a.Children() {
+		v = append(v, r.buildConcatenation_2(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildConcatenation_4(a p.Atom) Concatenation {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return Concatenation{}
+	}
+	return Concatenation{
+		Repetition: r.asRepetition(l[0]),
+		Item2:      r.buildConcatenation_3(l[1]),
+	}
+}
+
+func (r *astReduction) buildConcatenation(ctx *p.ReducerContext, a p.Atom) Concatenation {
+	return r.buildConcatenation_4(a)
+}
+
+func (r *astReduction) asRepeat(a p.Atom) Repeat {
+	v, _ := r.reduce(a).(Repeat)
+	return v
+}
+
+func (r *astReduction) buildRepetition_1(a p.Atom) *Repeat {
+	o, _ := a.(p.OptionVal)
+	if !o.Valid {
+		return nil
+	}
+	v := r.asRepeat(o.Children()[0])
+	return &v
+}
+
+func (r *astReduction) asElement(a p.Atom) Element {
+	v, _ := r.reduce(a).(Element)
+	return v
+}
+
+func (r *astReduction) buildRepetition_2(a p.Atom) Repetition {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return Repetition{}
+	}
+	return Repetition{
+		Repeat:  r.buildRepetition_1(l[0]),
+		Element: r.asElement(l[1]),
+	}
+}
+
+func (r *astReduction) buildRepetition(ctx *p.ReducerContext, a p.Atom) Repetition {
+	return r.buildRepetition_2(a)
+}
+
+func (r *astReduction) buildRepeat(ctx *p.ReducerContext, a p.Atom) Repeat {
+	return Repeat(ctx.Text())
+}
+
+func (r *astReduction) asGroup(a p.Atom) Group {
+	v, _ := r.reduce(a).(Group)
+	return v
+}
+
+func (r *astReduction) asOption(a p.Atom) Option {
+	v, _ := r.reduce(a).(Option)
+	return v
+}
+
+func (r *astReduction) asCharVal(a p.Atom) CharVal {
+	v, _ := r.reduce(a).(CharVal)
+	return v
+}
+
+func (r *astReduction) asNumVal(a p.Atom) NumVal {
+	v, _ := r.reduce(a).(NumVal)
+	return v
+}
+
+func (r *astReduction) asProseVal(a p.Atom) ProseVal {
+	v, _ := r.reduce(a).(ProseVal)
+	return v
+}
+
+func (r *astReduction) buildElement_1(a p.Atom) Element {
+	switch p.Alternative(parser, astAlternations[1], a) {
+	case 0:
+		return r.asElement(a)
+	case 1:
+		return r.asElement(a)
+	case 2:
+		return r.asElement(a)
+	case 3:
+		return r.asElement(a)
+	case 4:
+		return r.asElement(a)
+	case 5:
+		return r.asElement(a)
+	}
+	r.mismatch(a)
+	return nil
+}
+
+func (r *astReduction) buildElement(ctx *p.ReducerContext, a p.Atom) Element {
+	return r.buildElement_1(a)
+}
+
+func (r *astReduction) buildGroup_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildGroup_2(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildGroup_3(a p.Atom) Group {
+	l := a.Children()
+	if len(l) != 5 {
+		r.mismatch(a)
+		return Group{}
+	}
+	return Group{
+		CWsp:        r.buildGroup_1(l[1]),
+		Alternation: r.asAlternation(l[2]),
+		CWsp2:       r.buildGroup_2(l[3]),
+	}
+}
+
+func (r *astReduction) buildGroup(ctx *p.ReducerContext, a p.Atom) Group {
+	return r.buildGroup_3(a)
+}
+
+func (r *astReduction) buildOption_1(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildOption_2(a p.Atom) []CWsp {
+	var v []CWsp
+	for _, c := range a.Children() {
+		v = append(v, r.asCWsp(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildOption_3(a p.Atom) Option {
+	l := a.Children()
+	if len(l) != 5 {
+		r.mismatch(a)
+		return Option{}
+	}
+	return Option{
+		CWsp:        r.buildOption_1(l[1]),
+		Alternation: r.asAlternation(l[2]),
+		CWsp2:       r.buildOption_2(l[3]),
+	}
+}
+
+func (r *astReduction) buildOption(ctx *p.ReducerContext, a p.Atom) Option {
+	return r.buildOption_3(a)
+}
+
+func (r *astReduction) buildCharVal(ctx *p.ReducerContext, a p.Atom) CharVal {
+	return CharVal(ctx.Text())
+}
+
+func (r *astReduction) buildNumVal(ctx *p.ReducerContext, a p.Atom) NumVal {
+	return NumVal(ctx.Text())
+}
+
+func (r *astReduction) buildBinVal(ctx *p.ReducerContext, a p.Atom) BinVal {
+	return BinVal(ctx.Text())
+}
+
+func (r *astReduction) buildDecVal(ctx *p.ReducerContext, a p.Atom) DecVal {
+	return DecVal(ctx.Text())
+}
+
+func (r *astReduction) buildHexVal(ctx *p.ReducerContext, a p.Atom) HexVal {
+	return HexVal(ctx.Text())
+}
+
+func (r *astReduction) buildProseVal(ctx *p.ReducerContext, a p.Atom) ProseVal {
+	return ProseVal(ctx.Text())
+}
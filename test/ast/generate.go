@@ -0,0 +1,6 @@
+// Package ast holds types and reducers generated by goparse gen --ast, which
+// tests reduce parse trees into.
+package ast
+
+//go:generate go run ../../cmd gen --ast -p abnfast ../../grammars/abnf.abnf abnfast/abnfast.go
+//go:generate go run ../../cmd gen --ast -p sample sample/sample.abnf sample/sample.go
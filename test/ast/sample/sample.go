@@ -0,0 +1,454 @@
+// Code generated by goparse. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"sync"
+
+	p "github.com/heyvito/goparse/parser"
+)
+
+var parser = map[string]p.Consumer{
+	// @start
+	"config": p.Star(p.Alt(p.Ref("entry"), p.Ref("blank"))),
+	// @field Key Name
+	"entry": p.Cat(p.Ref("key"), p.Ref("eq"), p.Ref("value"), p.Star(p.Cat(p.Lit(','), p.Star(p.WSP), p.Ref("value"))), p.CRLF),
+	// @token
+	"key": p.Token(p.Cat(p.ALPHA, p.Star(p.Alt(p.ALPHA, p.DIGIT, p.Lit('-'))))),
+	// @suppress
+	"eq": p.Suppress(p.Cat(p.Star(p.WSP), p.Lit('='), p.Star(p.WSP))),
+	// @type Datum
+	// @entry
+	// @field Datum3 Pair
+	"value":  p.Alt(p.Ref("number"), p.Ref("string"), p.Cat(p.Lit('('), p.Ref("value"), p.Lit(','), p.Ref("value"), p.Lit(')')), p.Ref("list"), p.Alt(p.Str("yes"), p.Str("no"))),
+	"number": p.Cat(p.Opt(p.Lit('-')), p.Plus(p.DIGIT)),
+	"string": p.Cat(p.DQUOTE, p.Star(p.CharClass(p.CharRange{From: 0x20, To: 0x21}, p.CharRange{From: 0x23, To: 0x7e})), p.DQUOTE),
+	// @field Value Head
+	"list":  p.Cat(p.Lit('['), p.Opt(p.Cat(p.Ref("value"), p.Star(p.Cat(p.Lit(','), p.Ref("value"))))), p.Lit(']')),
+	"blank": p.Cat(p.Star(p.WSP), p.CRLF),
+}
+
+const StartRule = "config"
+
+// Names of the rules of the grammar.
+const (
+	RuleConfig = "config"
+	RuleEntry  = "entry"
+	RuleKey    = "key"
+	RuleEq     = "eq"
+	RuleValue  = "value"
+	RuleNumber = "number"
+	RuleString = "string"
+	RuleList   = "list"
+	RuleBlank  = "blank"
+)
+
+// Reducers holds a method reducing each rule of the grammar.
+type Reducers interface {
+	Config(ctx *p.ReducerContext) interface{}
+	Entry(ctx *p.ReducerContext) interface{}
+	Key(ctx *p.ReducerContext) interface{}
+	Value(ctx *p.ReducerContext) interface{}
+	Number(ctx *p.ReducerContext) interface{}
+	String(ctx *p.ReducerContext) interface{}
+	List(ctx *p.ReducerContext) interface{}
+	Blank(ctx *p.ReducerContext) interface{}
+}
+
+// ReducerMap returns the methods of r keyed by the rules they reduce.
+func ReducerMap(r Reducers) map[string]p.Reducer {
+	return map[string]p.Reducer{
+		RuleConfig: r.Config,
+		RuleEntry:  r.Entry,
+		RuleKey:    r.Key,
+		RuleValue:  r.Value,
+		RuleNumber: r.Number,
+		RuleString: r.String,
+		RuleList:   r.List,
+		RuleBlank:  r.Blank,
+	}
+}
+
+// Rules returns the rules of the grammar along with the core rules, for use
+// with p.New or by other grammars.
+func Rules() map[string]p.Consumer {
+	return p.MakeRules(parser)
+}
+
+var (
+	parserOnce   sync.Once
+	sharedParser *p.Parser
+)
+
+// rulesParser returns the parser used by the Parse and Match functions.
+func rulesParser() *p.Parser {
+	parserOnce.Do(func() {
+		sharedParser = p.New(Rules())
+	})
+	return sharedParser
+}
+
+// ParseConfig parses input as config, the whole of which must match.
+func ParseConfig(input string) (p.Atom, error) {
+	return rulesParser().Parse(RuleConfig, input)
+}
+
+// MatchConfig reports whether the whole of input matches config.
+func MatchConfig(input string) bool {
+	return rulesParser().Match(RuleConfig, input)
+}
+
+// ParseValue parses input as value, the whole of which must match.
+func ParseValue(input string) (p.Atom, error) {
+	return rulesParser().Parse(RuleValue, input)
+}
+
+// MatchValue reports whether the whole of input matches value.
+func MatchValue(input string) bool {
+	return rulesParser().Match(RuleValue, input)
+}
+
+// Config is the value of config.
+type Config []ConfigItem
+
+type ConfigItem interface {
+	isConfigItem()
+}
+
+// Entry is the value of entry.
+type Entry struct {
+	Name  Key
+	Value Datum
+	Item4 []EntryItem4
+}
+
+type EntryItem4 struct {
+	Item2 string
+	Value Datum
+}
+
+// Key is the text matched by key.
+type Key string
+
+// Datum is the value of value.
+type Datum interface {
+	isDatum()
+}
+
+type Pair struct {
+	Value  Datum
+	Value2 Datum
+}
+
+type Datum5 string
+
+// Number is the text matched by number.
+type Number string
+
+// String is the text matched by string.
+type String string
+
+// List is the value of list.
+type List struct {
+	Item2 *ListItem2
+}
+
+type ListItem2 struct {
+	Head  Datum
+	Item2 []Datum
+}
+
+// Blank is the text matched by blank.
+type Blank string
+
+func (Entry) isConfigItem() {}
+func (Blank) isConfigItem() {}
+
+func (Number) isDatum() {}
+func (String) isDatum() {}
+func (Pair) isDatum()   {}
+func (List) isDatum()   {}
+func (Datum5) isDatum() {}
+
+// ASTReducers builds the types above from the trees of their rules.
+var ASTReducers = map[string]p.ReducerE{
+	"config": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildConfig(ctx, a)
+		return v, r.err
+	},
+	"entry": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildEntry(ctx, a)
+		return v, r.err
+	},
+	"key": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildKey(ctx, a)
+		return v, r.err
+	},
+	"value": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildDatum(ctx, a)
+		return v, r.err
+	},
+	"number": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildNumber(ctx, a)
+		return v, r.err
+	},
+	"string": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildString(ctx, a)
+		return v, r.err
+	},
+	"list": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildList(ctx, a)
+		return v, r.err
+	},
+	"blank": func(ctx *p.ReducerContext) (interface{}, error) {
+		r := &astReduction{ctx: ctx}
+		a, _ := ctx.Value.(p.Atom)
+		v := r.buildBlank(ctx, a)
+		return v, r.err
+	},
+}
+
+var astAlternations = []*p.AlternationConsumer{
+	p.Alt(p.Ref("entry"), p.Ref("blank")),
+	p.Alt(p.Ref("number"), p.Ref("string"), p.Cat(p.Lit('('), p.Ref("value"), p.Lit(','), p.Ref("value"), p.Lit(')')), p.Ref("list"), p.Alt(p.Str("yes"), p.Str("no"))),
+}
+
+// astReduction holds the state of a reducer of ASTReducers.
+type astReduction struct {
+	ctx *p.ReducerContext
+	err error
+}
+
+func (r *astReduction) reduce(a p.Atom) interface{} {
+	v, err := r.ctx.ReduceE(a)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *astReduction) mismatch(a p.Atom) {
+	if a == nil {
+		r.err = fmt.Errorf("missing value")
+	} else if r.err == nil {
+		r.err = fmt.Errorf("unexpected %s at position %d", a.Kind(), a.Span().Start)
+	}
+}
+
+func (r *astReduction) asEntry(a p.Atom) Entry {
+	v, _ := r.reduce(a).(Entry)
+	return v
+}
+
+func (r *astReduction) asConfigItem(a p.Atom) ConfigItem {
+	v, _ := r.reduce(a).(ConfigItem)
+	return v
+}
+
+func (r *astReduction) asBlank(a p.Atom) Blank {
+	v, _ := r.reduce(a).(Blank)
+	return v
+}
+
+func (r *astReduction) buildConfig_1(a p.Atom) ConfigItem {
+	switch p.Alternative(parser, astAlternations[0], a) {
+	case 0:
+		return r.asConfigItem(a)
+	case 1:
+		return r.asConfigItem(a)
+	}
+	r.mismatch(a)
+	return nil
+}
+
+func (r *astReduction) buildConfig_2(a p.Atom) Config {
+	var v Config
+	for _, c := range a.Children() {
+		v = append(v, r.buildConfig_1(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildConfig(ctx *p.ReducerContext, a p.Atom) Config {
+	return r.buildConfig_2(a)
+}
+
+func (r *astReduction) asKey(a p.Atom) Key {
+	v, _ := r.reduce(a).(Key)
+	return v
+}
+
+func (r *astReduction) asDatum(a p.Atom) Datum {
+	v, _ := r.reduce(a).(Datum)
+	return v
+}
+
+func (r *astReduction) buildEntry_1(a p.Atom) EntryItem4 {
+	l := a.Children()
+	if len(l) != 3 {
+		r.mismatch(a)
+		return EntryItem4{}
+	}
+	return EntryItem4{
+		Item2: p.Text(l[1]),
+		Value: r.asDatum(l[2]),
+	}
+}
+
+func (r *astReduction) buildEntry_2(a p.Atom) []EntryItem4 {
+	var v []EntryItem4
+	for _, c := range a.Children() {
+		v = append(v, r.buildEntry_1(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildEntry_3(a p.Atom) Entry {
+	l := a.Children()
+	if len(l) != 4 {
+		r.mismatch(a)
+		return Entry{}
+	}
+	return Entry{
+		Name:  r.asKey(l[0]),
+		Value: r.asDatum(l[1]),
+		Item4: r.buildEntry_2(l[2]),
+	}
+}
+
+func (r *astReduction) buildEntry(ctx *p.ReducerContext, a p.Atom) Entry {
+	return r.buildEntry_3(a)
+}
+
+func (r *astReduction) buildKey(ctx *p.ReducerContext, a p.Atom) Key {
+	return Key(ctx.Text())
+}
+
+func (r *astReduction) asNumber(a p.Atom) Number {
+	v, _ := r.reduce(a).(Number)
+	return v
+}
+
+func (r *astReduction) asString(a p.Atom) String {
+	v, _ := r.reduce(a).(String)
+	return v
+}
+
+func (r *astReduction) buildDatum_1(a p.Atom) Pair {
+	l := a.Children()
+	if len(l) != 5 {
+		r.mismatch(a)
+		return Pair{}
+	}
+	return Pair{
+		Value:  r.asDatum(l[1]),
+		Value2: r.asDatum(l[3]),
+	}
+}
+
+func (r *astReduction) asList(a p.Atom) List {
+	v, _ := r.reduce(a).(List)
+	return v
+}
+
+func (r *astReduction) buildDatum_2(a p.Atom) Datum {
+	switch p.Alternative(parser, astAlternations[1], a) {
+	case 0:
+		return r.asDatum(a)
+	case 1:
+		return r.asDatum(a)
+	case 2:
+		return r.buildDatum_1(a)
+	case 3:
+		return r.asDatum(a)
+	case 4:
+		return Datum5(p.Text(a))
+	}
+	r.mismatch(a)
+	return nil
+}
+
+func (r *astReduction) buildDatum(ctx *p.ReducerContext, a p.Atom) Datum {
+	return r.buildDatum_2(a)
+}
+
+func (r *astReduction) buildNumber(ctx *p.ReducerContext, a p.Atom) Number {
+	return Number(ctx.Text())
+}
+
+func (r *astReduction) buildString(ctx *p.ReducerContext, a p.Atom) String {
+	return String(ctx.Text())
+}
+
+func (r *astReduction) buildList_1(a p.Atom) Datum {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		var v Datum
+		return v
+	}
+	return r.asDatum(l[1])
+}
+
+func (r *astReduction) buildList_2(a p.Atom) []Datum {
+	var v []Datum
+	for _, c := range a.Children() {
+		v = append(v, r.buildList_1(c))
+	}
+	return v
+}
+
+func (r *astReduction) buildList_3(a p.Atom) ListItem2 {
+	l := a.Children()
+	if len(l) != 2 {
+		r.mismatch(a)
+		return ListItem2{}
+	}
+	return ListItem2{
+		Head:  r.asDatum(l[0]),
+		Item2: r.buildList_2(l[1]),
+	}
+}
+
+func (r *astReduction) buildList_4(a p.Atom) *ListItem2 {
+	o, _ := a.(p.OptionVal)
+	if !o.Valid {
+		return nil
+	}
+	v := r.buildList_3(o.Children()[0])
+	return &v
+}
+
+func (r *astReduction) buildList_5(a p.Atom) List {
+	l := a.Children()
+	if len(l) != 3 {
+		r.mismatch(a)
+		return List{}
+	}
+	return List{
+		Item2: r.buildList_4(l[1]),
+	}
+}
+
+func (r *astReduction) buildList(ctx *p.ReducerContext, a p.Atom) List {
+	return r.buildList_5(a)
+}
+
+func (r *astReduction) buildBlank(ctx *p.ReducerContext, a p.Atom) Blank {
+	return Blank(ctx.Text())
+}
@@ -0,0 +1,1846 @@
+// Code generated by goparse. DO NOT EDIT.
+
+package abnfparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeKind identifies what a Node stands for.
+type NodeKind int
+
+const (
+	NodeRule NodeKind = iota + 1
+	NodeList
+	NodeOption
+	NodeToken
+	NodeChar
+	NodeAlpha
+	NodeBit
+	NodeCR
+	NodeLF
+	NodeCtl
+	NodeDigit
+	NodeDQuote
+	NodeHTab
+	NodeOctet
+	NodeSP
+	NodeVChar
+)
+
+// Node is a node of the trees produced by Parse. Rules, lists and options
+// hold their children, while the other nodes hold the text they matched.
+type Node struct {
+	Kind NodeKind
+	// Rule names the rule matched by a NodeRule.
+	Rule string
+	// Text is the text matched by terminals and tokens.
+	Text string
+	// Valid reports whether a NodeOption matched.
+	Valid    bool
+	Children []*Node
+	// Start and End are the offsets, in runes, of the text matched.
+	Start, End int
+	// splice is set on lists produced by inlined rules, which are spliced
+	// into the lists holding them.
+	splice bool
+}
+
+// ParseError reports why an input could not be parsed.
+type ParseError struct {
+	Message  string
+	Position int
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("%s at position %d", e.Message, e.Position) }
+
+// Parse parses input as rule, requiring the whole input to be consumed.
+func Parse(rule, input string) (*Node, error) {
+	fn, ok := rules[strings.ToLower(rule)]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule %s", rule)
+	}
+	p := &parseState{in: []rune(input)}
+	n, ok := fn(p)
+	if !ok {
+		return nil, &ParseError{Message: "Expected " + strings.Join(p.expected, " or "), Position: p.furthest}
+	}
+	if p.pos != len(p.in) {
+		return nil, &ParseError{Message: "Expected end of input", Position: p.pos}
+	}
+	return n, nil
+}
+
+// PrintTree returns the tree rooted at n, one line per node, in the format
+// used by goparse.
+func PrintTree(n *Node) string {
+	if n == nil {
+		return "\n"
+	}
+	sb := strings.Builder{}
+	printNode(&sb, n, 0)
+	return sb.String()
+}
+
+func printNode(sb *strings.Builder, n *Node, depth int) {
+	label := n.label()
+	if n.Kind != NodeRule && len(n.Children) > 0 {
+		label += " "
+	}
+	sb.WriteString(strings.Repeat(" ", 2*depth) + "- " + label + "\n")
+	for _, c := range n.Children {
+		printNode(sb, c, depth+1)
+	}
+	if len(n.Children) > 0 {
+		sb.WriteString("\n")
+	}
+}
+
+func (n *Node) label() string {
+	switch n.Kind {
+	case NodeRule:
+		return "Rule " + n.Rule + ":"
+	case NodeList:
+		if len(n.Children) == 0 {
+			return "Empty List"
+		}
+		return "List:"
+	case NodeOption:
+		if !n.Valid {
+			return "Empty Opt"
+		}
+		return "Opt:"
+	case NodeToken:
+		return fmt.Sprintf("Token: %q", n.Text)
+	case NodeChar:
+		return "C: " + n.Text
+	case NodeAlpha:
+		return "A: " + n.Text
+	case NodeBit:
+		return "B: " + n.Text
+	case NodeCR:
+		return "CR"
+	case NodeLF:
+		return "LF"
+	case NodeCtl:
+		return fmt.Sprintf("T: 0x%02x", n.Text[0])
+	case NodeDigit:
+		return "D: " + n.Text
+	case NodeDQuote:
+		return "DQuote"
+	case NodeHTab:
+		return "HTab"
+	case NodeOctet:
+		return fmt.Sprintf("O: 0x%02x", []rune(n.Text)[0])
+	case NodeSP:
+		return "SP"
+	case NodeVChar:
+		return fmt.Sprintf("VChar: %q", n.Text)
+	}
+	return fmt.Sprintf("Node(%d)", n.Kind)
+}
+
+// parseState holds the state of a parse. The methods generated for rules are
+// named after them with a rule or core prefix, which the methods below must
+// not start with.
+type parseState struct {
+	in  []rune
+	pos int
+	// furthest is the furthest position where a match failed, and expected
+	// what was expected there.
+	furthest int
+	expected []string
+}
+
+func (p *parseState) peek() rune {
+	if p.pos >= len(p.in) {
+		return 0x00
+	}
+	return p.in[p.pos]
+}
+
+func (p *parseState) fail(expected string) { p.failAt(p.pos, expected) }
+
+func (p *parseState) failAt(pos int, expected string) {
+	if pos > p.furthest {
+		p.furthest, p.expected = pos, nil
+	}
+	if pos == p.furthest {
+		for _, e := range p.expected {
+			if e == expected {
+				return
+			}
+		}
+		p.expected = append(p.expected, expected)
+	}
+}
+
+func (p *parseState) leaf(kind NodeKind) *Node {
+	n := &Node{Kind: kind, Text: string(p.peek()), Start: p.pos, End: p.pos + 1}
+	p.pos++
+	return n
+}
+
+func (p *parseState) list(start int, children []*Node) *Node {
+	return &Node{Kind: NodeList, Children: children, Start: start, End: p.pos}
+}
+
+func (p *parseState) option(start int, n *Node, ok bool) *Node {
+	opt := &Node{Kind: NodeOption, Valid: ok, Start: start, End: p.pos}
+	if ok && n != nil {
+		opt.Children = []*Node{n}
+	}
+	return opt
+}
+
+func (p *parseState) token(start int) *Node {
+	return &Node{Kind: NodeToken, Text: string(p.in[start:p.pos]), Start: start, End: p.pos}
+}
+
+func (p *parseState) wrap(rule string, start int, n *Node) *Node {
+	r := &Node{Kind: NodeRule, Rule: rule, Start: start, End: p.pos}
+	if n != nil {
+		r.Children = []*Node{n}
+	}
+	return r
+}
+
+// appendNode appends n to list, skipping suppressed results and splicing
+// inlined ones.
+func appendNode(list []*Node, n *Node) []*Node {
+	switch {
+	case n == nil:
+		return list
+	case n.Kind == NodeList && n.splice:
+		return append(list, n.Children...)
+	}
+	return append(list, n)
+}
+
+func (p *parseState) coreAlpha() (*Node, bool) {
+	if r := p.peek(); r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+		return p.leaf(NodeAlpha), true
+	}
+	p.fail("ALPHA")
+	return nil, false
+}
+
+func (p *parseState) coreBit() (*Node, bool) {
+	if r := p.peek(); r == '0' || r == '1' {
+		return p.leaf(NodeBit), true
+	}
+	p.fail("BIT")
+	return nil, false
+}
+
+func (p *parseState) coreCr() (*Node, bool) {
+	if r := p.peek(); r == 0x0d {
+		return p.leaf(NodeCR), true
+	}
+	p.fail("CR")
+	return nil, false
+}
+
+func (p *parseState) coreCrlf() (*Node, bool) {
+	start := p.pos
+	cr, ok := p.coreCr()
+	if !ok {
+		return nil, false
+	}
+	lf, ok := p.coreLf()
+	if !ok {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, []*Node{cr, lf}), true
+}
+
+func (p *parseState) coreDigit() (*Node, bool) {
+	if r := p.peek(); r >= '0' && r <= '9' {
+		return p.leaf(NodeDigit), true
+	}
+	p.fail("DIGIT")
+	return nil, false
+}
+
+func (p *parseState) coreDquote() (*Node, bool) {
+	if r := p.peek(); r == '"' {
+		return p.leaf(NodeDQuote), true
+	}
+	p.fail("DQUOTE")
+	return nil, false
+}
+
+func (p *parseState) coreHexdig() (*Node, bool) {
+	if n, ok := p.coreDigit(); ok {
+		return n, true
+	}
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= 'A' && r <= 'F' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("HEXDIG")
+	return nil, false
+}
+
+func (p *parseState) coreHtab() (*Node, bool) {
+	if r := p.peek(); r == 0x09 {
+		return p.leaf(NodeHTab), true
+	}
+	p.fail("HTAB")
+	return nil, false
+}
+
+func (p *parseState) coreLf() (*Node, bool) {
+	if r := p.peek(); r == 0x0a {
+		return p.leaf(NodeLF), true
+	}
+	p.fail("LF")
+	return nil, false
+}
+
+func (p *parseState) coreSp() (*Node, bool) {
+	if r := p.peek(); r == ' ' {
+		return p.leaf(NodeSP), true
+	}
+	p.fail("SP")
+	return nil, false
+}
+
+func (p *parseState) coreVchar() (*Node, bool) {
+	if r := p.peek(); r >= 0x21 && r <= 0x7e {
+		return p.leaf(NodeVChar), true
+	}
+	p.fail("VCHAR")
+	return nil, false
+}
+
+func (p *parseState) coreWsp() (*Node, bool) {
+	if n, ok := p.coreSp(); ok {
+		return n, true
+	}
+	return p.coreHtab()
+}
+
+func (p *parseState) ruleRulelist_1() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleCWsp()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRulelist_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleCNl(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRulelist_3() (*Node, bool) {
+	if n, ok := p.ruleRulelist_2(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleRule(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleRulelist_4() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.ruleRulelist_3()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+// ruleRulelist matches rulelist.
+func (p *parseState) ruleRulelist() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRulelist_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("rulelist", start, n), true
+}
+
+func (p *parseState) ruleRule_1() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 4)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulename(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleDefinedAs(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleElements(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleCNl(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleRule matches rule.
+func (p *parseState) ruleRule() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRule_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("rule", start, n), true
+}
+
+func (p *parseState) ruleRulename_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '-' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2D")
+	return nil, false
+}
+
+func (p *parseState) ruleRulename_2() (*Node, bool) {
+	if n, ok := p.coreAlpha(); ok {
+		return n, true
+	}
+	if n, ok := p.coreDigit(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleRulename_1(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleRulename_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleRulename_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRulename_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.coreAlpha(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulename_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleRulename matches rulename.
+func (p *parseState) ruleRulename() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRulename_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("rulename", start, n), true
+}
+
+func (p *parseState) ruleDefinedAs_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '=' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x3D")
+	return nil, false
+}
+
+func (p *parseState) ruleDefinedAs_2() (*Node, bool) {
+	if p.pos+2 <= len(p.in) && p.in[p.pos+0] == '=' && p.in[p.pos+1] == '/' {
+		start := p.pos
+		children := make([]*Node, 2)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"=/\"")
+	return nil, false
+}
+
+func (p *parseState) ruleDefinedAs_3() (*Node, bool) {
+	if n, ok := p.ruleDefinedAs_2(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleDefinedAs_1(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleDefinedAs_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleDefinedAs_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleDefinedAs matches defined-as.
+func (p *parseState) ruleDefinedAs() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleDefinedAs_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("defined-as", start, n), true
+}
+
+func (p *parseState) ruleElements_1() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleAlternation(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleElements matches elements.
+func (p *parseState) ruleElements() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleElements_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("elements", start, n), true
+}
+
+func (p *parseState) ruleCWsp_1() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleCNl(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreWsp(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleCWsp_2() (*Node, bool) {
+	if n, ok := p.coreWsp(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleCWsp_1(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleCWsp matches c-wsp.
+func (p *parseState) ruleCWsp() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleCWsp_2()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("c-wsp", start, n), true
+}
+
+func (p *parseState) ruleCNl_1() (*Node, bool) {
+	if n, ok := p.coreCrlf(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleComment(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleCNl matches c-nl.
+func (p *parseState) ruleCNl() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleCNl_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("c-nl", start, n), true
+}
+
+func (p *parseState) ruleComment_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ';' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x3B")
+	return nil, false
+}
+
+func (p *parseState) ruleComment_2() (*Node, bool) {
+	if n, ok := p.coreWsp(); ok {
+		return n, true
+	}
+	if n, ok := p.coreVchar(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleComment_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleComment_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleComment_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleComment_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleComment_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreCrlf(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleComment matches comment.
+func (p *parseState) ruleComment() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleComment_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("comment", start, n), true
+}
+
+func (p *parseState) ruleAlternation_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '/' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2F")
+	return nil, false
+}
+
+func (p *parseState) ruleAlternation_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 4)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleAlternation_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleConcatenation(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleAlternation_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleAlternation_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleAlternation_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleConcatenation(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleAlternation_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleAlternation matches alternation.
+func (p *parseState) ruleAlternation() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleAlternation_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("alternation", start, n), true
+}
+
+func (p *parseState) ruleConcatenation_1() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.ruleCWsp()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleConcatenation_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleConcatenation_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepetition(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleConcatenation_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleConcatenation_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleConcatenation_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRepetition(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleConcatenation_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleConcatenation matches concatenation.
+func (p *parseState) ruleConcatenation() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleConcatenation_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("concatenation", start, n), true
+}
+
+func (p *parseState) ruleRepetition_1() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRepeat()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleRepetition_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRepetition_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleElement(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleRepetition matches repetition.
+func (p *parseState) ruleRepetition() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRepetition_2()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("repetition", start, n), true
+}
+
+func (p *parseState) ruleRepeat_1() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreDigit()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRepeat_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.coreDigit()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRepeat_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '*' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2A")
+	return nil, false
+}
+
+func (p *parseState) ruleRepeat_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRepeat_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepeat_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepeat_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleRepeat_5() (*Node, bool) {
+	if n, ok := p.ruleRepeat_4(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleRepeat_1(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleRepeat matches repeat.
+func (p *parseState) ruleRepeat() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleRepeat_5()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("repeat", start, n), true
+}
+
+func (p *parseState) ruleElement_1() (*Node, bool) {
+	if n, ok := p.ruleRulename(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleGroup(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleOption(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleCharVal(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleNumVal(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleProseVal(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleElement matches element.
+func (p *parseState) ruleElement() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleElement_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("element", start, n), true
+}
+
+func (p *parseState) ruleGroup_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '(' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x28")
+	return nil, false
+}
+
+func (p *parseState) ruleGroup_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ')' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x29")
+	return nil, false
+}
+
+func (p *parseState) ruleGroup_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 5)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleGroup_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleAlternation(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleGroup_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleGroup matches group.
+func (p *parseState) ruleGroup() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleGroup_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("group", start, n), true
+}
+
+func (p *parseState) ruleOption_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '[' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5B")
+	return nil, false
+}
+
+func (p *parseState) ruleOption_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ']' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5D")
+	return nil, false
+}
+
+func (p *parseState) ruleOption_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 5)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleOption_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleAlternation(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRulelist_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleOption_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleOption matches option.
+func (p *parseState) ruleOption() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleOption_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("option", start, n), true
+}
+
+func (p *parseState) ruleCharVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= ' ' && r <= '!' || r >= '#' && r <= '~' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x20-21 / %x23-7E")
+	return nil, false
+}
+
+func (p *parseState) ruleCharVal_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleCharVal_1()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleCharVal_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.coreDquote(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleCharVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreDquote(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleCharVal matches char-val.
+func (p *parseState) ruleCharVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleCharVal_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("char-val", start, n), true
+}
+
+func (p *parseState) ruleNumVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '%' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x25")
+	return nil, false
+}
+
+func (p *parseState) ruleNumVal_2() (*Node, bool) {
+	if n, ok := p.ruleBinVal(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleDecVal(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleHexVal(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleNumVal_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleNumVal_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNumVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleNumVal matches num-val.
+func (p *parseState) ruleNumVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleNumVal_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("num-val", start, n), true
+}
+
+func (p *parseState) ruleBinVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 'b' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x62")
+	return nil, false
+}
+
+func (p *parseState) ruleBinVal_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreBit()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleBinVal_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '.' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2E")
+	return nil, false
+}
+
+func (p *parseState) ruleBinVal_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleBinVal_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleBinVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleBinVal_5() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.ruleBinVal_4()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleBinVal_6() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulename_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleBinVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleBinVal_7() (*Node, bool) {
+	if n, ok := p.ruleBinVal_6(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBinVal_5(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleBinVal_8() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleBinVal_7()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleBinVal_9() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleBinVal_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleBinVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleBinVal_8(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleBinVal matches bin-val.
+func (p *parseState) ruleBinVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleBinVal_9()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("bin-val", start, n), true
+}
+
+func (p *parseState) ruleDecVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 'd' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x64")
+	return nil, false
+}
+
+func (p *parseState) ruleDecVal_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleBinVal_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepeat_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleDecVal_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.ruleDecVal_2()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleDecVal_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulename_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepeat_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleDecVal_5() (*Node, bool) {
+	if n, ok := p.ruleDecVal_4(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleDecVal_3(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleDecVal_6() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleDecVal_5()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleDecVal_7() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleDecVal_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleRepeat_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleDecVal_6(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleDecVal matches dec-val.
+func (p *parseState) ruleDecVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleDecVal_7()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("dec-val", start, n), true
+}
+
+func (p *parseState) ruleHexVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 'x' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x78")
+	return nil, false
+}
+
+func (p *parseState) ruleHexVal_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreHexdig()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleHexVal_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleBinVal_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHexVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleHexVal_4() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.ruleHexVal_3()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleHexVal_5() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleRulename_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHexVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleHexVal_6() (*Node, bool) {
+	if n, ok := p.ruleHexVal_5(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleHexVal_4(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleHexVal_7() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleHexVal_6()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleHexVal_8() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleHexVal_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHexVal_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHexVal_7(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleHexVal matches hex-val.
+func (p *parseState) ruleHexVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleHexVal_8()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("hex-val", start, n), true
+}
+
+func (p *parseState) ruleProseVal_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '<' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x3C")
+	return nil, false
+}
+
+func (p *parseState) ruleProseVal_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= ' ' && r <= '=' || r >= '?' && r <= '~' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x20-3D / %x3F-7E")
+	return nil, false
+}
+
+func (p *parseState) ruleProseVal_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleProseVal_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleProseVal_4() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '>' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x3E")
+	return nil, false
+}
+
+func (p *parseState) ruleProseVal_5() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleProseVal_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleProseVal_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleProseVal_4(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleProseVal matches prose-val.
+func (p *parseState) ruleProseVal() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleProseVal_5()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("prose-val", start, n), true
+}
+
+var rules = map[string]func(*parseState) (*Node, bool){
+	"alpha":         (*parseState).coreAlpha,
+	"bit":           (*parseState).coreBit,
+	"cr":            (*parseState).coreCr,
+	"crlf":          (*parseState).coreCrlf,
+	"digit":         (*parseState).coreDigit,
+	"dquote":        (*parseState).coreDquote,
+	"hexdig":        (*parseState).coreHexdig,
+	"htab":          (*parseState).coreHtab,
+	"lf":            (*parseState).coreLf,
+	"sp":            (*parseState).coreSp,
+	"vchar":         (*parseState).coreVchar,
+	"wsp":           (*parseState).coreWsp,
+	"rulelist":      (*parseState).ruleRulelist,
+	"rule":          (*parseState).ruleRule,
+	"rulename":      (*parseState).ruleRulename,
+	"defined-as":    (*parseState).ruleDefinedAs,
+	"elements":      (*parseState).ruleElements,
+	"c-wsp":         (*parseState).ruleCWsp,
+	"c-nl":          (*parseState).ruleCNl,
+	"comment":       (*parseState).ruleComment,
+	"alternation":   (*parseState).ruleAlternation,
+	"concatenation": (*parseState).ruleConcatenation,
+	"repetition":    (*parseState).ruleRepetition,
+	"repeat":        (*parseState).ruleRepeat,
+	"element":       (*parseState).ruleElement,
+	"group":         (*parseState).ruleGroup,
+	"option":        (*parseState).ruleOption,
+	"char-val":      (*parseState).ruleCharVal,
+	"num-val":       (*parseState).ruleNumVal,
+	"bin-val":       (*parseState).ruleBinVal,
+	"dec-val":       (*parseState).ruleDecVal,
+	"hex-val":       (*parseState).ruleHexVal,
+	"prose-val":     (*parseState).ruleProseVal,
+}
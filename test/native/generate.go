@@ -0,0 +1,6 @@
+// Package native holds parsers generated by goparse gen --native, which
+// tests compare against the interpreted parsers.
+package native
+
+//go:generate go run ../../cmd gen --native -p abnfparser ../../grammars/abnf.abnf abnfparser/abnfparser.go
+//go:generate go run ../../cmd gen --native -p sample sample/sample.abnf sample/sample.go
@@ -0,0 +1,1659 @@
+// Code generated by goparse. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeKind identifies what a Node stands for.
+type NodeKind int
+
+const (
+	NodeRule NodeKind = iota + 1
+	NodeList
+	NodeOption
+	NodeToken
+	NodeChar
+	NodeAlpha
+	NodeBit
+	NodeCR
+	NodeLF
+	NodeCtl
+	NodeDigit
+	NodeDQuote
+	NodeHTab
+	NodeOctet
+	NodeSP
+	NodeVChar
+)
+
+// Node is a node of the trees produced by Parse. Rules, lists and options
+// hold their children, while the other nodes hold the text they matched.
+type Node struct {
+	Kind NodeKind
+	// Rule names the rule matched by a NodeRule.
+	Rule string
+	// Text is the text matched by terminals and tokens.
+	Text string
+	// Valid reports whether a NodeOption matched.
+	Valid    bool
+	Children []*Node
+	// Start and End are the offsets, in runes, of the text matched.
+	Start, End int
+	// splice is set on lists produced by inlined rules, which are spliced
+	// into the lists holding them.
+	splice bool
+}
+
+// ParseError reports why an input could not be parsed.
+type ParseError struct {
+	Message  string
+	Position int
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("%s at position %d", e.Message, e.Position) }
+
+// Parse parses input as rule, requiring the whole input to be consumed.
+func Parse(rule, input string) (*Node, error) {
+	fn, ok := rules[strings.ToLower(rule)]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule %s", rule)
+	}
+	p := &parseState{in: []rune(input)}
+	n, ok := fn(p)
+	if !ok {
+		return nil, &ParseError{Message: "Expected " + strings.Join(p.expected, " or "), Position: p.furthest}
+	}
+	if p.pos != len(p.in) {
+		return nil, &ParseError{Message: "Expected end of input", Position: p.pos}
+	}
+	return n, nil
+}
+
+// PrintTree returns the tree rooted at n, one line per node, in the format
+// used by goparse.
+func PrintTree(n *Node) string {
+	if n == nil {
+		return "\n"
+	}
+	sb := strings.Builder{}
+	printNode(&sb, n, 0)
+	return sb.String()
+}
+
+func printNode(sb *strings.Builder, n *Node, depth int) {
+	label := n.label()
+	if n.Kind != NodeRule && len(n.Children) > 0 {
+		label += " "
+	}
+	sb.WriteString(strings.Repeat(" ", 2*depth) + "- " + label + "\n")
+	for _, c := range n.Children {
+		printNode(sb, c, depth+1)
+	}
+	if len(n.Children) > 0 {
+		sb.WriteString("\n")
+	}
+}
+
+func (n *Node) label() string {
+	switch n.Kind {
+	case NodeRule:
+		return "Rule " + n.Rule + ":"
+	case NodeList:
+		if len(n.Children) == 0 {
+			return "Empty List"
+		}
+		return "List:"
+	case NodeOption:
+		if !n.Valid {
+			return "Empty Opt"
+		}
+		return "Opt:"
+	case NodeToken:
+		return fmt.Sprintf("Token: %q", n.Text)
+	case NodeChar:
+		return "C: " + n.Text
+	case NodeAlpha:
+		return "A: " + n.Text
+	case NodeBit:
+		return "B: " + n.Text
+	case NodeCR:
+		return "CR"
+	case NodeLF:
+		return "LF"
+	case NodeCtl:
+		return fmt.Sprintf("T: 0x%02x", n.Text[0])
+	case NodeDigit:
+		return "D: " + n.Text
+	case NodeDQuote:
+		return "DQuote"
+	case NodeHTab:
+		return "HTab"
+	case NodeOctet:
+		return fmt.Sprintf("O: 0x%02x", []rune(n.Text)[0])
+	case NodeSP:
+		return "SP"
+	case NodeVChar:
+		return fmt.Sprintf("VChar: %q", n.Text)
+	}
+	return fmt.Sprintf("Node(%d)", n.Kind)
+}
+
+// parseState holds the state of a parse. The methods generated for rules are
+// named after them with a rule or core prefix, which the methods below must
+// not start with.
+type parseState struct {
+	in  []rune
+	pos int
+	// furthest is the furthest position where a match failed, and expected
+	// what was expected there.
+	furthest int
+	expected []string
+}
+
+func (p *parseState) peek() rune {
+	if p.pos >= len(p.in) {
+		return 0x00
+	}
+	return p.in[p.pos]
+}
+
+func (p *parseState) fail(expected string) { p.failAt(p.pos, expected) }
+
+func (p *parseState) failAt(pos int, expected string) {
+	if pos > p.furthest {
+		p.furthest, p.expected = pos, nil
+	}
+	if pos == p.furthest {
+		for _, e := range p.expected {
+			if e == expected {
+				return
+			}
+		}
+		p.expected = append(p.expected, expected)
+	}
+}
+
+func (p *parseState) leaf(kind NodeKind) *Node {
+	n := &Node{Kind: kind, Text: string(p.peek()), Start: p.pos, End: p.pos + 1}
+	p.pos++
+	return n
+}
+
+func (p *parseState) list(start int, children []*Node) *Node {
+	return &Node{Kind: NodeList, Children: children, Start: start, End: p.pos}
+}
+
+func (p *parseState) option(start int, n *Node, ok bool) *Node {
+	opt := &Node{Kind: NodeOption, Valid: ok, Start: start, End: p.pos}
+	if ok && n != nil {
+		opt.Children = []*Node{n}
+	}
+	return opt
+}
+
+func (p *parseState) token(start int) *Node {
+	return &Node{Kind: NodeToken, Text: string(p.in[start:p.pos]), Start: start, End: p.pos}
+}
+
+func (p *parseState) wrap(rule string, start int, n *Node) *Node {
+	r := &Node{Kind: NodeRule, Rule: rule, Start: start, End: p.pos}
+	if n != nil {
+		r.Children = []*Node{n}
+	}
+	return r
+}
+
+// appendNode appends n to list, skipping suppressed results and splicing
+// inlined ones.
+func appendNode(list []*Node, n *Node) []*Node {
+	switch {
+	case n == nil:
+		return list
+	case n.Kind == NodeList && n.splice:
+		return append(list, n.Children...)
+	}
+	return append(list, n)
+}
+
+func (p *parseState) coreAlpha() (*Node, bool) {
+	if r := p.peek(); r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+		return p.leaf(NodeAlpha), true
+	}
+	p.fail("ALPHA")
+	return nil, false
+}
+
+func (p *parseState) coreBit() (*Node, bool) {
+	if r := p.peek(); r == '0' || r == '1' {
+		return p.leaf(NodeBit), true
+	}
+	p.fail("BIT")
+	return nil, false
+}
+
+func (p *parseState) coreChar() (*Node, bool) {
+	if r := p.peek(); r == 0x01 || r >= 0x7f {
+		return p.leaf(NodeChar), true
+	}
+	p.fail("CHAR")
+	return nil, false
+}
+
+func (p *parseState) coreCr() (*Node, bool) {
+	if r := p.peek(); r == 0x0d {
+		return p.leaf(NodeCR), true
+	}
+	p.fail("CR")
+	return nil, false
+}
+
+func (p *parseState) coreCrlf() (*Node, bool) {
+	start := p.pos
+	cr, ok := p.coreCr()
+	if !ok {
+		return nil, false
+	}
+	lf, ok := p.coreLf()
+	if !ok {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, []*Node{cr, lf}), true
+}
+
+func (p *parseState) coreCtl() (*Node, bool) {
+	if r := p.peek(); r <= 0x1f || r == 0x7f {
+		return p.leaf(NodeCtl), true
+	}
+	p.fail("CTL")
+	return nil, false
+}
+
+func (p *parseState) coreDigit() (*Node, bool) {
+	if r := p.peek(); r >= '0' && r <= '9' {
+		return p.leaf(NodeDigit), true
+	}
+	p.fail("DIGIT")
+	return nil, false
+}
+
+func (p *parseState) coreDquote() (*Node, bool) {
+	if r := p.peek(); r == '"' {
+		return p.leaf(NodeDQuote), true
+	}
+	p.fail("DQUOTE")
+	return nil, false
+}
+
+func (p *parseState) coreHexdig() (*Node, bool) {
+	if n, ok := p.coreDigit(); ok {
+		return n, true
+	}
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= 'A' && r <= 'F' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("HEXDIG")
+	return nil, false
+}
+
+func (p *parseState) coreHtab() (*Node, bool) {
+	if r := p.peek(); r == 0x09 {
+		return p.leaf(NodeHTab), true
+	}
+	p.fail("HTAB")
+	return nil, false
+}
+
+func (p *parseState) coreLf() (*Node, bool) {
+	if r := p.peek(); r == 0x0a {
+		return p.leaf(NodeLF), true
+	}
+	p.fail("LF")
+	return nil, false
+}
+
+func (p *parseState) coreLwsp() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for p.pos < len(p.in) {
+		if n, ok := p.coreWsp(); ok {
+			children = append(children, n)
+			continue
+		}
+		pos := p.pos
+		crlf, ok := p.coreCrlf()
+		if !ok {
+			break
+		}
+		wsp, ok := p.coreWsp()
+		if !ok {
+			p.pos = pos
+			break
+		}
+		children = append(children, p.list(pos, []*Node{crlf, wsp}))
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) coreOctet() (*Node, bool) {
+	if p.pos < len(p.in) {
+		return p.leaf(NodeOctet), true
+	}
+	p.fail("OCTET")
+	return nil, false
+}
+
+func (p *parseState) coreSp() (*Node, bool) {
+	if r := p.peek(); r == ' ' {
+		return p.leaf(NodeSP), true
+	}
+	p.fail("SP")
+	return nil, false
+}
+
+func (p *parseState) coreVchar() (*Node, bool) {
+	if r := p.peek(); r >= 0x21 && r <= 0x7e {
+		return p.leaf(NodeVChar), true
+	}
+	p.fail("VCHAR")
+	return nil, false
+}
+
+func (p *parseState) coreWsp() (*Node, bool) {
+	if n, ok := p.coreSp(); ok {
+		return n, true
+	}
+	return p.coreHtab()
+}
+
+func (p *parseState) ruleDocument_1() (*Node, bool) {
+	if n, ok := p.ruleEntry(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBlank(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleDocument_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleDocument_1()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleDocument_3() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleTrailer()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleDocument_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleDocument_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleDocument_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleDocument matches document.
+func (p *parseState) ruleDocument() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleDocument_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("document", start, n), true
+}
+
+func (p *parseState) ruleEntry_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ',' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2C")
+	return nil, false
+}
+
+func (p *parseState) ruleEntry_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.coreWsp()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleEntry_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEntry_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleValue(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleEntry_4() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleEntry_3()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleEntry_5() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleComment()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleEntry_6() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 6)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleKey(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEq(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleValue(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_4(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_5(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreCrlf(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleEntry matches entry.
+func (p *parseState) ruleEntry() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleEntry_6()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("entry", start, n), true
+}
+
+func (p *parseState) ruleKey_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '-' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2D")
+	return nil, false
+}
+
+func (p *parseState) ruleKey_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '_' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5F")
+	return nil, false
+}
+
+func (p *parseState) ruleKey_3() (*Node, bool) {
+	if n, ok := p.coreAlpha(); ok {
+		return n, true
+	}
+	if n, ok := p.coreDigit(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleKey_1(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleKey_2(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleKey_4() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleKey_3()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleKey_5() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.coreAlpha(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleKey_4(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleKey matches key.
+func (p *parseState) ruleKey() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleKey_5()
+	if !ok {
+		return nil, false
+	}
+	n = p.token(start)
+	return p.wrap("key", start, n), true
+}
+
+func (p *parseState) ruleEq_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '=' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x3D")
+	return nil, false
+}
+
+func (p *parseState) ruleEq_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEq_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleEq matches eq.
+func (p *parseState) ruleEq() (*Node, bool) {
+	if _, ok := p.ruleEq_2(); !ok {
+		return nil, false
+	}
+	return nil, true
+}
+
+func (p *parseState) ruleValue_1() (*Node, bool) {
+	if n, ok := p.ruleNumber(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleHex(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBool(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleString(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleList(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEmpty(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleValue matches value.
+func (p *parseState) ruleValue() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleValue_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("value", start, n), true
+}
+
+func (p *parseState) ruleNumber_1() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleKey_1()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleNumber_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreDigit()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleNumber_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '.' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x2E")
+	return nil, false
+}
+
+func (p *parseState) ruleNumber_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleNumber_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNumber_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleNumber_5() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleNumber_4()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleNumber_6() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleNumber_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNumber_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNumber_5(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleNumber matches number.
+func (p *parseState) ruleNumber() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleNumber_6()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("number", start, n), true
+}
+
+func (p *parseState) ruleHex_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '0' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x30")
+	return nil, false
+}
+
+func (p *parseState) ruleHex_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 'x' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x78")
+	return nil, false
+}
+
+func (p *parseState) ruleHex_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreHexdig()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			if count < 2 {
+				count = 2
+			}
+			break
+		}
+	}
+	if count < 2 || count > 8 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleHex_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleHex_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHex_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleHex_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleHex matches hex.
+func (p *parseState) ruleHex() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleHex_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("hex", start, n), true
+}
+
+func (p *parseState) ruleBool_1() (*Node, bool) {
+	if p.pos+4 <= len(p.in) && (p.in[p.pos+0] == 't' || p.in[p.pos+0] == 'T') && (p.in[p.pos+1] == 'r' || p.in[p.pos+1] == 'R') && (p.in[p.pos+2] == 'u' || p.in[p.pos+2] == 'U') && (p.in[p.pos+3] == 'e' || p.in[p.pos+3] == 'E') {
+		start := p.pos
+		children := make([]*Node, 4)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"true\"")
+	return nil, false
+}
+
+func (p *parseState) ruleBool_2() (*Node, bool) {
+	if p.pos+5 <= len(p.in) && (p.in[p.pos+0] == 'f' || p.in[p.pos+0] == 'F') && (p.in[p.pos+1] == 'a' || p.in[p.pos+1] == 'A') && (p.in[p.pos+2] == 'l' || p.in[p.pos+2] == 'L') && (p.in[p.pos+3] == 's' || p.in[p.pos+3] == 'S') && (p.in[p.pos+4] == 'e' || p.in[p.pos+4] == 'E') {
+		start := p.pos
+		children := make([]*Node, 5)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"false\"")
+	return nil, false
+}
+
+func (p *parseState) ruleBool_3() (*Node, bool) {
+	if p.pos+3 <= len(p.in) && (p.in[p.pos+0] == 'y' || p.in[p.pos+0] == 'Y') && (p.in[p.pos+1] == 'e' || p.in[p.pos+1] == 'E') && (p.in[p.pos+2] == 's' || p.in[p.pos+2] == 'S') {
+		start := p.pos
+		children := make([]*Node, 3)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"yes\"")
+	return nil, false
+}
+
+func (p *parseState) ruleBool_4() (*Node, bool) {
+	if p.pos+2 <= len(p.in) && (p.in[p.pos+0] == 'n' || p.in[p.pos+0] == 'N') && (p.in[p.pos+1] == 'o' || p.in[p.pos+1] == 'O') {
+		start := p.pos
+		children := make([]*Node, 2)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"no\"")
+	return nil, false
+}
+
+func (p *parseState) ruleBool_5() (*Node, bool) {
+	if n, ok := p.ruleBool_1(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBool_2(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBool_3(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleBool_4(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleBool matches bool.
+func (p *parseState) ruleBool() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleBool_5()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("bool", start, n), true
+}
+
+func (p *parseState) ruleString_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= ' ' && r <= '!' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x20-21")
+	return nil, false
+}
+
+func (p *parseState) ruleString_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= '#' && r <= '[' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x23-5B")
+	return nil, false
+}
+
+func (p *parseState) ruleString_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r >= ']' && r <= '~' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5D-7E")
+	return nil, false
+}
+
+func (p *parseState) ruleString_4() (*Node, bool) {
+	if n, ok := p.ruleString_1(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleString_2(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleString_3(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEscape(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleString_5() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleString_4()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleString_6() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.coreDquote(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleString_5(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreDquote(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleString matches string.
+func (p *parseState) ruleString() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleString_6()
+	if !ok {
+		p.failAt(start, "a string")
+		return nil, false
+	}
+	return p.wrap("string", start, n), true
+}
+
+func (p *parseState) ruleEscape_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 0x5c {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5C")
+	return nil, false
+}
+
+func (p *parseState) ruleEscape_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 'n' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x6E")
+	return nil, false
+}
+
+func (p *parseState) ruleEscape_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == 't' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x74")
+	return nil, false
+}
+
+func (p *parseState) ruleEscape_4() (*Node, bool) {
+	if n, ok := p.coreDquote(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEscape_1(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEscape_2(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEscape_3(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleEscape_5() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEscape_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEscape_4(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleEscape matches escape.
+func (p *parseState) ruleEscape() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleEscape_5()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("escape", start, n), true
+}
+
+func (p *parseState) ruleList_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '[' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5B")
+	return nil, false
+}
+
+func (p *parseState) ruleList_2() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 4)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleValue(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleList_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleList_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleList_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleValue(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleList_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleList_5() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleList_4()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleList_6() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ']' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x5D")
+	return nil, false
+}
+
+func (p *parseState) ruleList_7() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 5)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleList_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleList_5(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleList_6(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleList matches list.
+func (p *parseState) ruleList() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleList_7()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("list", start, n), true
+}
+
+func (p *parseState) ruleEmpty_1() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleKey_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleKey_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleEmpty_2() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '~' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x7E")
+	return nil, false
+}
+
+func (p *parseState) ruleEmpty_3() (*Node, bool) {
+	if n, ok := p.ruleEmpty_1(); ok {
+		return n, true
+	}
+	if n, ok := p.ruleEmpty_2(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleEmpty matches empty.
+func (p *parseState) ruleEmpty() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleEmpty_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("empty", start, n), true
+}
+
+func (p *parseState) ruleComment_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '#' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x23")
+	return nil, false
+}
+
+func (p *parseState) ruleComment_2() (*Node, bool) {
+	if n, ok := p.coreWsp(); ok {
+		return n, true
+	}
+	if n, ok := p.coreVchar(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+func (p *parseState) ruleComment_3() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.ruleComment_2()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleComment_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleComment_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleComment_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleComment matches comment.
+func (p *parseState) ruleComment() (*Node, bool) {
+	n, ok := p.ruleComment_4()
+	if !ok {
+		return nil, false
+	}
+	if n != nil && n.Kind == NodeList {
+		n.splice = true
+	}
+	return n, true
+}
+
+func (p *parseState) ruleBlank_1() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 2)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleEntry_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreCrlf(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleBlank matches blank.
+func (p *parseState) ruleBlank() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleBlank_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("blank", start, n), true
+}
+
+func (p *parseState) ruleTrailer_1() (*Node, bool) {
+	if p.pos+7 <= len(p.in) && p.in[p.pos+0] == '_' && p.in[p.pos+1] == '_' && p.in[p.pos+2] == 'E' && p.in[p.pos+3] == 'N' && p.in[p.pos+4] == 'D' && p.in[p.pos+5] == '_' && p.in[p.pos+6] == '_' {
+		start := p.pos
+		children := make([]*Node, 7)
+		for i := range children {
+			children[i] = p.leaf(NodeChar)
+		}
+		return p.list(start, children), true
+	}
+	p.fail("\"__END__\"")
+	return nil, false
+}
+
+func (p *parseState) ruleTrailer_2() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	for {
+		if p.pos >= len(p.in) {
+			break
+		}
+		pos := p.pos
+		n, ok := p.coreOctet()
+		if !ok {
+			break
+		}
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	return p.list(start, children), true
+}
+
+func (p *parseState) ruleTrailer_3() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleTrailer_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.coreLwsp(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleTrailer_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleTrailer matches trailer.
+func (p *parseState) ruleTrailer() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleTrailer_3()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("trailer", start, n), true
+}
+
+func (p *parseState) ruleBits_1() (*Node, bool) {
+	start := p.pos
+	var children []*Node
+	count := 0
+	for {
+		pos := p.pos
+		n, ok := p.coreBit()
+		if !ok {
+			break
+		}
+		count++
+		children = appendNode(children, n)
+		if p.pos == pos {
+			break
+		}
+	}
+	if count == 0 {
+		p.pos = start
+		return nil, false
+	}
+	return p.list(start, children), true
+}
+
+// ruleBits matches bits.
+func (p *parseState) ruleBits() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleBits_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("bits", start, n), true
+}
+
+func (p *parseState) ruleCtl_1() (*Node, bool) {
+	if n, ok := p.coreChar(); ok {
+		return n, true
+	}
+	if n, ok := p.coreCtl(); ok {
+		return n, true
+	}
+	return nil, false
+}
+
+// ruleCtl matches ctl.
+func (p *parseState) ruleCtl() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleCtl_1()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("ctl", start, n), true
+}
+
+// ruleLws matches lws.
+func (p *parseState) ruleLws() (*Node, bool) {
+	start := p.pos
+	n, ok := p.coreLwsp()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("lws", start, n), true
+}
+
+func (p *parseState) ruleNode_1() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == '(' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x28")
+	return nil, false
+}
+
+func (p *parseState) ruleNode_2() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleNode()
+	return p.option(start, n, ok), true
+}
+
+func (p *parseState) ruleNode_3() (*Node, bool) {
+	if p.pos < len(p.in) {
+		if r := p.in[p.pos]; r == ')' {
+			return p.leaf(NodeChar), true
+		}
+	}
+	p.fail("%x29")
+	return nil, false
+}
+
+func (p *parseState) ruleNode_4() (*Node, bool) {
+	start := p.pos
+	children := make([]*Node, 0, 3)
+	var n *Node
+	var ok bool
+	if n, ok = p.ruleNode_1(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNode_2(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	if n, ok = p.ruleNode_3(); !ok {
+		p.pos = start
+		return nil, false
+	}
+	children = appendNode(children, n)
+	return p.list(start, children), true
+}
+
+// ruleNode matches node.
+func (p *parseState) ruleNode() (*Node, bool) {
+	start := p.pos
+	n, ok := p.ruleNode_4()
+	if !ok {
+		return nil, false
+	}
+	return p.wrap("node", start, n), true
+}
+
+var rules = map[string]func(*parseState) (*Node, bool){
+	"alpha":    (*parseState).coreAlpha,
+	"bit":      (*parseState).coreBit,
+	"char":     (*parseState).coreChar,
+	"cr":       (*parseState).coreCr,
+	"crlf":     (*parseState).coreCrlf,
+	"digit":    (*parseState).coreDigit,
+	"dquote":   (*parseState).coreDquote,
+	"hexdig":   (*parseState).coreHexdig,
+	"htab":     (*parseState).coreHtab,
+	"lf":       (*parseState).coreLf,
+	"lwsp":     (*parseState).coreLwsp,
+	"octet":    (*parseState).coreOctet,
+	"sp":       (*parseState).coreSp,
+	"vchar":    (*parseState).coreVchar,
+	"wsp":      (*parseState).coreWsp,
+	"document": (*parseState).ruleDocument,
+	"entry":    (*parseState).ruleEntry,
+	"key":      (*parseState).ruleKey,
+	"eq":       (*parseState).ruleEq,
+	"value":    (*parseState).ruleValue,
+	"number":   (*parseState).ruleNumber,
+	"hex":      (*parseState).ruleHex,
+	"bool":     (*parseState).ruleBool,
+	"string":   (*parseState).ruleString,
+	"escape":   (*parseState).ruleEscape,
+	"list":     (*parseState).ruleList,
+	"empty":    (*parseState).ruleEmpty,
+	"comment":  (*parseState).ruleComment,
+	"blank":    (*parseState).ruleBlank,
+	"trailer":  (*parseState).ruleTrailer,
+	"bits":     (*parseState).ruleBits,
+	"ctl":      (*parseState).ruleCtl,
+	"lws":      (*parseState).ruleLws,
+	"node":     (*parseState).ruleNode,
+}
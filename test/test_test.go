@@ -2,7 +2,9 @@ package test
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +12,13 @@ import (
 
 	"github.com/heyvito/goparse/abnf"
 	"github.com/heyvito/goparse/abnf1"
+	"github.com/heyvito/goparse/abnf2"
+	"github.com/heyvito/goparse/analysis"
+	"github.com/heyvito/goparse/parser"
+	"github.com/heyvito/goparse/test/ast/abnfast"
+	astsample "github.com/heyvito/goparse/test/ast/sample"
+	"github.com/heyvito/goparse/test/native/abnfparser"
+	"github.com/heyvito/goparse/test/native/sample"
 )
 
 func TestParseProgressive(t *testing.T) {
@@ -22,3 +31,956 @@ func TestParseProgressive(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(abnf.Generate(v))
 }
+
+func TestCompile(t *testing.T) {
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	expected, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+
+	rules, err := abnf.Compile(expected)
+	require.NoError(t, err)
+	cur := parser.CursorFromString(string(data))
+	tree, err := parser.KickoffParser(&cur, rules, "rulelist")
+	require.NoError(t, err)
+	list, err := parser.ReduceIntoE(tree, abnf.ReducerE)
+	require.NoError(t, err)
+	require.Equal(t, expected, list)
+
+	require.Equal(t, expected, parser.ReduceInto(tree, abnf.Reducer))
+
+	// Trees not shaped as the reducers expect are reported, rather than
+	// panicking.
+	tree, err = parser.New(rules).Parse("rule", "a = b\r\n")
+	require.NoError(t, err)
+	_, err = parser.ReduceIntoE(tree, map[string]parser.ReducerE{
+		"rule":     abnf.ReducerE["rule"],
+		"rulename": parser.ReduceAsStringE,
+	})
+	require.EqualError(t, err, "rule: expected abnf.RuleName, found string at position 0")
+}
+
+func TestReduceGrammarEdgeCases(t *testing.T) {
+	list, err := abnf2.Parse("a = 12ALPHA / 2*3DIGIT\r\na =/ <some prose>\r\n")
+	require.NoError(t, err)
+	require.Len(t, list.Rules, 2)
+	require.Equal(t, "=/", list.Rules[1].DefinedAs.Value)
+
+	alts := list.Rules[0].Elements.Alternation.Elements
+	require.Equal(t, &abnf.Repeat{Min: 12, Max: 12}, alts[0].Elements[0].Meta)
+	require.Equal(t, &abnf.Repeat{Min: 2, Max: 3}, alts[1].Elements[0].Meta)
+	require.Equal(t, abnf.ProseVal{Value: "some prose"},
+		list.Rules[1].Elements.Alternation.Elements[0].Elements[0].Element.Inner)
+
+	_, err = abnf.Compile(list)
+	require.EqualError(t, err, "rule a: prose value <some prose> cannot be compiled")
+
+	list, err = abnf2.Parse("a = 3*2DIGIT\r\n")
+	require.NoError(t, err)
+	_, err = abnf.Compile(list)
+	require.EqualError(t, err, "rule a: repetition 3*2 cannot match, as its minimum exceeds its maximum")
+
+	list, err = abnf2.Parse("a = %x41.42\r\n")
+	require.NoError(t, err)
+	_, err = abnf.Compile(list)
+	require.EqualError(t, err, "rule a: numeric sequence without values cannot be compiled")
+}
+
+func TestRulePositions(t *testing.T) {
+	list, err := abnf2.Parse("a = b\r\nlonger = a\r\n")
+	require.NoError(t, err)
+	require.Equal(t, parser.Span{Start: 7, End: 19}, list.Rules[1].Span)
+	require.Equal(t, parser.Span{Start: 7, End: 13}, list.Rules[1].Name.Span)
+
+	ref := list.Rules[1].Elements.Alternation.Elements[0].Elements[0].Element.Inner
+	require.Equal(t, abnf.RuleName{Name: "a", Span: parser.Span{Start: 16, End: 17}}, ref)
+}
+
+func TestLosslessGrammar(t *testing.T) {
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+	rules, err := abnf.Compile(list)
+	require.NoError(t, err)
+
+	tree, err := parser.New(rules, parser.WithTrivia(abnf.TriviaRules...)).Parse("rulelist", string(data))
+	require.NoError(t, err)
+	require.Equal(t, string(data), parser.FullText(tree))
+	require.Empty(t, parser.FindAll(tree, "c-nl"))
+
+	var comments []string
+	parser.Walk(tree, func(a parser.Atom, enter bool) parser.WalkAction {
+		for _, t := range append(parser.LeadingTrivia(a), parser.TrailingTrivia(a)...) {
+			if enter && parser.FindAll(t.Atom, "comment") != nil {
+				comments = append(comments, t.Text)
+			}
+		}
+		return parser.WalkContinue
+	})
+	require.NotEmpty(t, comments)
+}
+
+func TestEditGrammar(t *testing.T) {
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+	rules, err := abnf.Compile(list)
+	require.NoError(t, err)
+
+	grammar := "a = b  c ; uses b\r\nb = \"x\" / \"y\"\r\nc = b\r\n"
+	tree, err := parser.New(rules).Parse("rulelist", grammar)
+	require.NoError(t, err)
+	edit := parser.Edit(tree)
+	for _, n := range edit.FindAll("rulename") {
+		if n.Text() == "b" {
+			n.SetText("bee")
+		}
+	}
+	require.Equal(t, "a = bee  c ; uses b\r\nbee = \"x\" / \"y\"\r\nc = bee\r\n", edit.Text())
+
+	alt := edit.FindAll("alternation")[1]
+	cats := alt.FindAll("concatenation")
+	require.NoError(t, cats[0].Replace(parser.EditText(`"z"`)))
+	require.NoError(t, cats[1].Parent().Remove())
+	require.Equal(t, "a = bee  c ; uses b\r\nbee = \"z\"\r\nc = bee\r\n", edit.Text())
+
+	_, err = parser.New(rules).Parse("rulelist", edit.Text())
+	require.NoError(t, err)
+}
+
+func TestShapeAnnotations(t *testing.T) {
+	grammar := "; @token\r\nname = 1*ALPHA\r\n\r\n; unrelated\r\n\r\npair = name eq name\r\neq = \"=\" ; @suppress\r\n"
+	list, err := abnf2.Parse(grammar)
+	require.NoError(t, err)
+	require.Equal(t, parser.ShapeToken, list.Rules[0].Shape)
+	require.Equal(t, parser.ShapeDefault, list.Rules[1].Shape)
+	require.Equal(t, parser.ShapeSuppress, list.Rules[2].Shape)
+
+	rules, err := abnf.Compile(list)
+	require.NoError(t, err)
+	tree, err := parser.New(rules).Parse("pair", "ab=cd")
+	require.NoError(t, err)
+	str := strings.Builder{}
+	require.NoError(t, parser.PrintTreeTo(&str, tree, parser.PrintOptions{Compact: true}))
+	require.Equal(t, "- Rule pair:\n  - List:\n    - Rule name:\n      - Token: \"ab\"\n    - Rule name:\n      - Token: \"cd\"\n", str.String())
+
+	require.Contains(t, abnf.Generate(list), `"name": p.Token(p.Plus(p.ALPHA)),`)
+	require.Contains(t, abnf.Generate(list), `"eq": p.Suppress(p.Lit('=')),`)
+
+	_, err = abnf2.Parse("; @token\r\na = \"x\" ; @inline\r\n")
+	require.Error(t, err)
+}
+
+func TestAnnotations(t *testing.T) {
+	grammar := "; A tiny language\r\n\r\n" +
+		"; @start\r\n; @type Program\r\nprogram = 1*stmt\r\n" +
+		"stmt = kw SP name \";\" ; @label \"a statement\"\r\n" +
+		"kw = \"let\" ; @case-insensitive\r\n" +
+		"; @doc \"a name\" extra\r\nname = 1*ALPHA\r\n"
+	list, err := abnf2.Parse(grammar)
+	require.NoError(t, err)
+
+	require.Len(t, list.Comments, 6)
+	require.Equal(t, " A tiny language", list.Comments[0].Value)
+	require.Equal(t, parser.Span{Start: 0, End: 19}, list.Comments[0].Span)
+	require.Len(t, list.Rules[0].Comments, 2)
+	require.Equal(t, []abnf.Annotation{
+		{Name: "start", Span: list.Comments[1].Span},
+		{Name: "type", Args: []string{"Program"}, Span: list.Comments[2].Span},
+	}, list.Rules[0].Annotations)
+	require.Equal(t, []string{"a name", "extra"}, list.Rules[3].Annotations[0].Args)
+	require.Equal(t, `@doc "a name" extra`, list.Rules[3].Annotations[0].String())
+	start, err := list.StartRule()
+	require.NoError(t, err)
+	require.Equal(t, "program", start)
+
+	rules, err := abnf.Compile(list)
+	require.NoError(t, err)
+	p := parser.New(rules)
+	_, err = p.Parse(start, "LET a;lEt b;")
+	require.NoError(t, err)
+	_, err = p.Parse("stmt", "let 1;")
+	require.EqualError(t, err, "Expected a statement at position 0")
+
+	gen := abnf.Generate(list)
+	require.Contains(t, gen, "// @start\n// @type Program\n\"program\": p.Plus(p.Ref(\"stmt\")),")
+	require.Contains(t, gen, `"stmt": p.Label("a statement", p.Cat(`)
+	require.Contains(t, gen, `"kw": p.StrFold("let"),`)
+	require.Contains(t, gen, `const StartRule = "program"`)
+
+	for _, invalid := range []string{
+		"a = \"x\" ; @type\r\n",
+		"a = \"x\" ; @type not-an-identifier\r\n",
+		"a = \"x\" ; @label \"unterminated\r\n",
+		"a = \"x\" ; @start now\r\n",
+	} {
+		_, err := abnf2.Parse(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	list, err = abnf2.Parse("; @start\r\na = \"x\"\r\n; @start\r\nb = \"y\"\r\n")
+	require.NoError(t, err)
+	_, err = list.StartRule()
+	require.Error(t, err)
+
+	list, err = abnf2.Parse("a = \"x\" ; @label \"one\"\r\na =/ \"y\" ; @label \"two\"\r\n")
+	require.NoError(t, err)
+	_, err = abnf.Compile(list)
+	require.EqualError(t, err, `rule a: conflicting labels "one" and "two"`)
+	_, err = abnf.GenerateE(list)
+	require.EqualError(t, err, `rule a: conflicting labels "one" and "two"`)
+	require.Empty(t, abnf.Generate(list))
+
+	list, err = abnf2.Parse("a = \"x\"\r\na =/ \"y\" ; @label \"an a\"\r\n")
+	require.NoError(t, err)
+	gen, err = abnf.GenerateE(list)
+	require.NoError(t, err)
+	require.Contains(t, gen, "// @label \"an a\"\n\"a\": p.Label(\"an a\", p.CharClass(")
+}
+
+func TestAnalyze(t *testing.T) {
+	source := "a = b / undefined\r\n" +
+		"b = \"x\" DIGIT\r\n" +
+		"c =/ \"y\"\r\n" +
+		"b = \"z\"\r\n" +
+		"orphan = a\r\n" +
+		"DIGIT = \"0\"\r\n"
+	list, err := abnf2.Parse(source)
+	require.NoError(t, err)
+
+	findings := abnf.Analyze(list, "a")
+	kinds := make([]abnf.FindingKind, len(findings))
+	for i, f := range findings {
+		kinds[i] = f.Kind
+	}
+	require.Equal(t, []abnf.FindingKind{
+		abnf.FindingUndefined,
+		abnf.FindingIncremental,
+		abnf.FindingUnreachable,
+		abnf.FindingDuplicate,
+		abnf.FindingUnreachable,
+		abnf.FindingShadowedCore,
+	}, kinds)
+	require.Equal(t, "error: rule a references undefined rule undefined [undefined]", findings[0].String())
+	line, col := parser.LineCol(source, findings[0].Span.Start)
+	require.Equal(t, [2]int{1, 9}, [2]int{line, col})
+	line, col = parser.LineCol(source, findings[3].Span.Start)
+	require.Equal(t, [2]int{4, 1}, [2]int{line, col})
+	require.Equal(t, abnf.SeverityWarning, findings[5].Severity)
+
+	findings = abnf.Analyze(list, "missing")
+	require.Equal(t, "start rule missing is not defined", findings[0].Message)
+	require.Equal(t, parser.Span{}, findings[0].Span)
+
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list, err = abnf2.Parse(string(data))
+	require.NoError(t, err)
+	require.Empty(t, abnf.Analyze(list, "rulelist"))
+}
+
+func TestAnalysisSets(t *testing.T) {
+	list, err := abnf2.Parse("list = item *( \",\" item ) [ \";\" ]\r\n" +
+		"item = [ sign ] 1*DIGIT / name\r\n" +
+		"sign = \"-\" / \"+\"\r\n" +
+		"name = %x41-5A *( ALPHA / \"_\" ) ; @case-insensitive\r\n" +
+		"name =/ \"id\"\r\n" +
+		"empty = [ \"x\" ]\r\n")
+	require.NoError(t, err)
+	g, err := analysis.New(list, "")
+	require.NoError(t, err)
+	require.Equal(t, "list", g.Start())
+	require.Equal(t, []string{"list", "item", "sign", "name", "empty"}, g.Rules())
+
+	require.False(t, g.Nullable("list"))
+	require.True(t, g.Nullable("empty"))
+	require.Equal(t, "%x2B / %x2D / %x30-39 / %x41-5A / %x69", g.First("item").String())
+	require.Equal(t, "%x41-5A / %x69", g.First("name").String())
+	require.Equal(t, "%x2C / %x3B / EOF", g.Follow("item").String())
+	require.Equal(t, "%x30-39", g.Follow("sign").String())
+	require.Equal(t, "%x2C / %x30-39 / %x3B / EOF", g.Follow("DIGIT").String())
+	require.True(t, g.First("alpha").Contains('q'))
+
+	var follows []string
+	g.Inspect("list", func(node interface{}, info analysis.Info) bool {
+		if _, ok := node.(abnf.Repetition); ok {
+			follows = append(follows, info.Follow.String())
+		}
+		return true
+	})
+	require.Equal(t, []string{
+		"%x2C / %x3B / EOF",                      // item
+		"%x3B / EOF",                             // *( "," item )
+		"%x2B / %x2D / %x30-39 / %x41-5A / %x69", // ","
+		"%x2C / %x3B / EOF",                      // item
+		"EOF",                                    // [ ";" ]
+		"EOF",                                    // ";"
+	}, follows)
+
+	_, err = analysis.New(list, "missing")
+	require.EqualError(t, err, "start rule missing is not defined")
+
+	s := analysis.NewSet(analysis.Range{Lo: 'a', Hi: 'z'}, analysis.Range{Lo: '0', Hi: '9'})
+	require.Equal(t, 36, s.Len())
+	require.Equal(t, "%x30-39 / %x61-7A", s.String())
+	require.Equal(t, "%x61-7A", s.Union(analysis.Runes('b')).Without(analysis.NewSet(analysis.Range{Lo: '0', Hi: '9'})).String())
+	require.Equal(t, "%x30-34 / %x61", s.Intersect(analysis.NewSet(analysis.Range{Lo: '!', Hi: '4'}, analysis.Range{Lo: '_', Hi: 'a'})).String())
+	require.Equal(t, "%x61-6C / %x6E-7A", s.Without(analysis.NewSet(analysis.Range{Lo: '0', Hi: '9'}, analysis.Range{Lo: 'm', Hi: 'm'})).String())
+	require.True(t, s.Union(analysis.EOF).Intersect(analysis.EOF).Equal(analysis.EOF))
+}
+
+func TestConflicts(t *testing.T) {
+	list, err := abnf2.Parse("a = \"=\" / \"=/\"\r\n" +
+		"b = \"ab\" c / \"ab\" d / DIGIT\r\n" +
+		"c = \"x\"\r\n" +
+		"d = \"y\"\r\n" +
+		"e = *ALPHA ALPHA\r\n" +
+		"f = [ \"x\" ] / \"y\"\r\n" +
+		"g = \"x\" / \"y\" / %x30-39\r\n")
+	require.NoError(t, err)
+	g, err := analysis.New(list, "a")
+	require.NoError(t, err)
+
+	var found []string
+	for _, c := range g.Conflicts() {
+		found = append(found, c.String())
+	}
+	require.Equal(t, []string{
+		`rule a: alternative "=/" is never chosen under ordered choice, as "=" always matches a prefix of it [shadowed]; place "=/" before "=", or factor them as "=" [ "/" ]`,
+		`rule b: alternatives "ab" c and "ab" d may both start with %x61 [first-first]; factor them as "ab" ( c / d )`,
+		`rule e: repetition *ALPHA may consume %x41-5A / %x61-7A, which may also follow it [greedy-repetition]; bound the repetition, or change what follows it so it starts differently`,
+		`rule f: alternative [ "x" ] matches the empty string, so the ones after it are never chosen under ordered choice [shadowed]; place [ "x" ] last, or make it non-empty and wrap the alternation in [ ]`,
+	}, found)
+
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list, err = abnf2.Parse(string(data))
+	require.NoError(t, err)
+	g, err = analysis.New(list, "rulelist")
+	require.NoError(t, err)
+	conflicts := g.Conflicts()
+	require.Equal(t, "defined-as", conflicts[1].Rule)
+	require.Equal(t, analysis.ConflictShadowed, conflicts[1].Kind)
+
+	text := abnf.Format(list)
+	reparsed, err := abnf2.Parse(text)
+	require.NoError(t, err)
+	require.Equal(t, text, abnf.Format(reparsed))
+	require.Equal(t, "1*( rule / ( *c-wsp c-nl ) )", abnf.Format(list.Rules[0].Elements))
+}
+
+func TestEliminateLeftRecursion(t *testing.T) {
+	list, err := abnf2.Parse("expr = expr \"+\" term / expr \"-\" term / term\r\n" +
+		"term = term \"*\" factor / factor\r\n" +
+		"factor = \"(\" expr \")\" / 1*DIGIT\r\n" +
+		"a = b \"x\" / \"y\"\r\n" +
+		"b = [ \"p\" ] a \"z\" / \"q\"\r\n" +
+		"c = *WSP c \"!\" / \".\"\r\n")
+	require.NoError(t, err)
+
+	var cycles []string
+	for _, rec := range abnf.FindLeftRecursion(list) {
+		cycles = append(cycles, rec.String())
+	}
+	require.Equal(t, []string{
+		"left recursion: expr -> expr",
+		"left recursion: term -> term",
+		"left recursion: a -> b -> a",
+		"left recursion: c -> c",
+	}, cycles)
+	require.Equal(t, abnf.FindingLeftRecursion, abnf.Analyze(list, "expr")[0].Kind)
+
+	rewritten, err := abnf.EliminateLeftRecursion(list, true)
+	require.NoError(t, err)
+	require.Equal(t, "; @fold-left\r\n"+
+		"expr = term *( \"+\" term / \"-\" term )\r\n"+
+		"; @fold-left\r\n"+
+		"term = factor *( \"*\" factor )\r\n"+
+		"factor = \"(\" expr \")\" / 1*DIGIT\r\n"+
+		"a = b \"x\" / \"y\"\r\n"+
+		"; @fold-left\r\n"+
+		"b = ( \"p\" a \"z\" / \"y\" \"z\" / \"q\" ) *( \"x\" \"z\" )\r\n"+
+		"; @fold-left\r\n"+
+		"c = ( 1*WSP c \"!\" / \".\" ) *\"!\"\r\n", abnf.Format(rewritten))
+	require.Empty(t, abnf.FindLeftRecursion(rewritten))
+	require.True(t, rewritten.Rules[0].HasAnnotation(abnf.AnnotationFoldLeft))
+
+	rules, err := abnf.Compile(rewritten)
+	require.NoError(t, err)
+	p := parser.New(rules)
+	tree, err := p.Parse("expr", "8-2-1+3*2*2")
+	require.NoError(t, err)
+	fold := func(ctx *parser.ReducerContext) (interface{}, error) {
+		return ctx.FoldLeft(func(acc interface{}, tail []interface{}) (interface{}, error) {
+			op := parser.Text(tail[0].(parser.Atom))
+			rhs := tail[1].(string)
+			return fmt.Sprintf("(%s%s%s)", acc, op, rhs), nil
+		})
+	}
+	reducers := map[string]parser.ReducerE{
+		"expr":   fold,
+		"term":   fold,
+		"factor": parser.ReduceAsStringE,
+	}
+	result, err := parser.ReduceIntoE(tree, reducers)
+	require.NoError(t, err)
+	require.Equal(t, "(((8-2)-1)+((3*2)*2))", result)
+
+	list, err = abnf2.Parse("a = [ b ] a \"x\" / \"y\"\r\nb = [ \"b\" ]\r\n")
+	require.NoError(t, err)
+	_, err = abnf.EliminateLeftRecursion(list, false)
+	require.EqualError(t, err, "rule a: cannot eliminate left recursion hidden behind [ b ], as it may match the empty string")
+}
+
+func TestOptimize(t *testing.T) {
+	grammar := "; @start\r\nstmt = kw SP name eq value \";\"\r\n" +
+		"kw = \"let\" / \"var\" / \"const\" / \"def\" / \"fn\" ; @case-insensitive\r\n" +
+		"name = ident ; @label \"a name\"\r\n" +
+		"ident = 1*( %x61-7A / \"_\" / \"-\" / %x30-39 )\r\n" +
+		"eq = *SP \"=\" *SP ; @suppress\r\n" +
+		"value = \"0\" \"x\" 1*HEXDIG / \"0\" \"b\" 1*BIT / \"0\" / %x31-39 *DIGIT / \"true\" / \"false\" / \"nil\" / \"none\"\r\n"
+	list, err := abnf2.Parse(grammar)
+	require.NoError(t, err)
+
+	format := func(pass abnf.Pass) string { return abnf.Format(abnf.Optimize(list, pass)) }
+	require.Contains(t, format(abnf.InlineSuppressed), "stmt = kw SP name ( *SP \"=\" *SP ) value \";\"\r\n")
+	require.Contains(t, format(abnf.HoistPrefixes), "value = \"0\" ( \"x\" 1*HEXDIG / \"b\" 1*BIT ) / \"0\" / ")
+	require.Contains(t, format(abnf.MergeCharSets), "ident = 1*( %x2D / %x30-39 / %x5F / %x61-7A )\r\n")
+	require.Contains(t, format(abnf.BuildTries), "kw = ( \"let\" / \"var\" / \"const\" / \"def\" / \"fn\" )\r\n")
+	require.Equal(t, "; @start\r\nstmt = kw SP name ( *SP \"=\" *SP ) value \";\"\r\n"+
+		"; @case-insensitive\r\nkw = ( \"let\" / \"var\" / \"const\" / \"def\" / \"fn\" )\r\n"+
+		"; @label \"a name\"\r\nname = ident\r\n"+
+		"ident = 1*( %x2D / %x30-39 / %x5F / %x61-7A )\r\n"+
+		"; @suppress\r\neq = *SP \"=\" *SP\r\n"+
+		"value = \"0\" ( \"x\" 1*HEXDIG / \"b\" 1*BIT ) / \"0\" / %x31-39 *DIGIT / \"true\" / \"false\" / \"nil\" / \"none\"\r\n",
+		abnf.Format(abnf.Optimize(list)))
+
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	abnfList, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		list   *abnf.RuleList
+		start  string
+		inputs []string
+	}{
+		{list, "stmt", []string{"let a = 0;", "CONST b_1=0x1F;", "fn x  = 0b101;", "var y =none;", "def z = 1234;", "let a = 01;", "let = 1;"}},
+		{abnfList, "rulelist", []string{string(data), "a = b\r\n", "a = %x41-5A / \"x\" [ c ]\r\n", "a = \r\n"}},
+	} {
+		want, err := abnf.Compile(c.list)
+		require.NoError(t, err)
+		got, err := abnf.Compile(abnf.Optimize(c.list))
+		require.NoError(t, err)
+		for _, input := range c.inputs {
+			wantTree, wantErr := parser.New(want).Parse(c.start, input)
+			gotTree, gotErr := parser.New(got).Parse(c.start, input)
+			require.Equal(t, wantErr == nil, gotErr == nil, input)
+			if wantErr == nil {
+				require.Equal(t, parser.PrintTree(wantTree), parser.PrintTree(gotTree), input)
+			}
+		}
+	}
+
+	gen := abnf.Generate(abnf.Optimize(list))
+	require.Contains(t, gen, `p.Trie(p.LiteralFold("let"),`)
+}
+
+func BenchmarkParseABNF(b *testing.B) {
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(b, err)
+	list, err := abnf2.Parse(string(data))
+	require.NoError(b, err)
+	rules, err := abnf.Compile(list)
+	require.NoError(b, err)
+	alts, err := abnf.Compile(withoutCharClasses(list))
+	require.NoError(b, err)
+	// The baseline matches char-val the way it was compiled before CharClass.
+	charVal := parser.Cat(parser.DQUOTE, parser.Star(parser.Alt(parser.HexRange(0x20, 0x21), parser.HexRange(0x23, 0x7E))), parser.DQUOTE)
+	require.Equal(b, charVal, alts["char-val"])
+	require.NotEqual(b, charVal, rules["char-val"])
+
+	for _, bench := range []struct {
+		name  string
+		rules map[string]parser.Consumer
+	}{{"alt", alts}, {"charclass", rules}} {
+		b.Run(bench.name, func(b *testing.B) {
+			p := parser.New(bench.rules)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.Parse("rulelist", string(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	b.Run("native", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := abnfparser.Parse("rulelist", string(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// withoutCharClasses returns a copy of list compiling to the consumers used
+// before CharClass: each alternative is wrapped in a group, which Compile
+// does not merge into a character class.
+func withoutCharClasses(list *abnf.RuleList) *abnf.RuleList {
+	var alternation func(alt abnf.Alternation) abnf.Alternation
+	element := func(node abnf.Node) abnf.Node {
+		switch n := node.(type) {
+		case abnf.Group:
+			return abnf.Group{Elements: alternation(n.Elements)}
+		case abnf.Option:
+			return abnf.Option{Elements: alternation(n.Elements)}
+		}
+		return node
+	}
+	alternation = func(alt abnf.Alternation) abnf.Alternation {
+		ret := abnf.Alternation{}
+		for _, c := range alt.Elements {
+			cat := abnf.Concatenation{}
+			for _, r := range c.Elements {
+				r.Element = abnf.Element{Inner: element(r.Element.Inner)}
+				cat.Elements = append(cat.Elements, r)
+			}
+			if len(alt.Elements) > 1 {
+				group := abnf.Group{Elements: abnf.Alternation{Elements: []abnf.Concatenation{cat}}}
+				cat = abnf.Concatenation{Elements: []abnf.Repetition{{Element: abnf.Element{Inner: group}}}}
+			}
+			ret.Elements = append(ret.Elements, cat)
+		}
+		return ret
+	}
+
+	ret := *list
+	ret.Rules = make([]abnf.Rule, len(list.Rules))
+	for i, r := range list.Rules {
+		r.Elements = abnf.Elements{Alternation: alternation(r.Elements.Alternation)}
+		ret.Rules[i] = r
+	}
+	return &ret
+}
+
+func TestDispatch(t *testing.T) {
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+	rules, err := abnf.Compile(list)
+	require.NoError(t, err)
+
+	// Parsers dispatch alternations on FIRST sets, while KickoffParser
+	// attempts every alternative.
+	p := parser.New(rules)
+	for _, input := range []string{string(data), "a = b / c\r\n", "a = %x41-\r\n", "= b\r\n", ""} {
+		cur := parser.CursorFromString(input)
+		want, wantErr := parser.KickoffParser(&cur, rules, "rulelist")
+		got, gotErr := p.Parse("rulelist", input)
+		if wantErr != nil {
+			require.EqualError(t, gotErr, wantErr.Error(), input)
+			continue
+		}
+		require.NoError(t, gotErr, input)
+		require.Equal(t, parser.PrintTree(want), parser.PrintTree(got), input)
+	}
+
+	// Match only recognises inputs, and must agree with Parse.
+	for _, rule := range []string{"rulelist", "rule", "elements", "repeat", "char-val", "num-val"} {
+		for _, input := range []string{string(data), "a = b / c\r\n", "a = %x41-\r\n", "b / c", "1*2", "*", "\"x\"", "%x41.42", "%b", ""} {
+			_, err := p.Parse(rule, input)
+			require.Equal(t, err == nil, p.Match(rule, input), "%s %q", rule, input)
+		}
+	}
+	for i := 0; i <= len(data); i += 31 {
+		_, err := p.Parse("rulelist", string(data[:i]))
+		require.Equal(t, err == nil, p.Match("rulelist", string(data[:i])), i)
+	}
+}
+
+func TestNative(t *testing.T) {
+	for _, c := range []struct {
+		grammar, pkg, output string
+		parse                func(rule, input string) (string, error)
+	}{
+		{"../grammars/abnf.abnf", "abnfparser", "native/abnfparser/abnfparser.go", func(rule, input string) (string, error) {
+			n, err := abnfparser.Parse(rule, input)
+			return abnfparser.PrintTree(n), err
+		}},
+		{"native/sample/sample.abnf", "sample", "native/sample/sample.go", func(rule, input string) (string, error) {
+			n, err := sample.Parse(rule, input)
+			return sample.PrintTree(n), err
+		}},
+	} {
+		data, err := os.ReadFile(c.grammar)
+		require.NoError(t, err)
+		list, err := abnf2.Parse(string(data))
+		require.NoError(t, err)
+		src, err := abnf.GenerateNative(c.pkg, list)
+		require.NoError(t, err)
+		generated, err := os.ReadFile(c.output)
+		require.NoError(t, err)
+		require.Equal(t, src, string(generated), "%s is out of date; run go generate ./test/native", c.output)
+		_, err = abnf.GenerateNative(c.pkg, abnf.Optimize(list))
+		require.NoError(t, err)
+
+		rules, err := abnf.Compile(list)
+		require.NoError(t, err)
+		p := parser.New(rules)
+		start := list.Rules[0].Name.Name
+		inputs := append([]string{string(data)}, randomCorpus(list, start, rand.New(rand.NewSource(1)), 300)...)
+		matched := 0
+		for _, input := range inputs {
+			want, wantErr := p.Parse(start, input)
+			got, gotErr := c.parse(start, input)
+			require.Equal(t, wantErr == nil, gotErr == nil, "%q: %v, %v", input, wantErr, gotErr)
+			if wantErr == nil {
+				require.Equal(t, parser.PrintTree(want), got, input)
+				matched++
+			}
+		}
+		require.Greater(t, matched, len(inputs)/3, c.grammar)
+	}
+
+	// Rules not reachable from the start rule of the sample.
+	rules, err := abnf.Compile(mustParseFile(t, "native/sample/sample.abnf"))
+	require.NoError(t, err)
+	for _, c := range [][2]string{{"bits", "0110"}, {"bits", ""}, {"ctl", "\x01"}, {"ctl", "\x7f"}, {"ctl", "a"}, {"lws", " \r\n\t"}, {"lws", "\r\n"}, {"node", "(())"}, {"node", "(()"}} {
+		want, wantErr := parser.New(rules).Parse(c[0], c[1])
+		got, gotErr := sample.Parse(c[0], c[1])
+		require.Equal(t, wantErr == nil, gotErr == nil, "%s %q", c[0], c[1])
+		if wantErr == nil {
+			require.Equal(t, parser.PrintTree(want), sample.PrintTree(got), "%s %q", c[0], c[1])
+		}
+	}
+}
+
+func TestAST(t *testing.T) {
+	for _, c := range [][3]string{
+		{"../grammars/abnf.abnf", "abnfast", "ast/abnfast/abnfast.go"},
+		{"ast/sample/sample.abnf", "sample", "ast/sample/sample.go"},
+	} {
+		src, err := abnf.GenerateAST(c[1], mustParseFile(t, c[0]))
+		require.NoError(t, err)
+		generated, err := os.ReadFile(c[2])
+		require.NoError(t, err)
+		require.Equal(t, src, string(generated), "%s is out of date; run go generate ./test/ast", c[2])
+	}
+
+	reduce := func(grammar, input string, reducers map[string]parser.ReducerE) interface{} {
+		rules, err := abnf.Compile(mustParseFile(t, grammar))
+		require.NoError(t, err)
+		tree, err := parser.New(rules).Parse(mustParseFile(t, grammar).Rules[0].Name.Name, input)
+		require.NoError(t, err)
+		v, err := parser.ReduceIntoE(tree, reducers)
+		require.NoError(t, err)
+		return v
+	}
+
+	v := reduce("ast/sample/sample.abnf", "name = 1, \"two\"\r\n\r\nlist=[(1,yes),-2]\r\nflag = no\r\nnone=[]\r\n", astsample.ASTReducers)
+	require.Equal(t, astsample.Config{
+		astsample.Entry{Name: "name", Value: astsample.Number("1"), Item4: []astsample.EntryItem4{{Item2: " ", Value: astsample.String(`"two"`)}}},
+		astsample.Blank("\r\n"),
+		astsample.Entry{Name: "list", Value: astsample.List{Item2: &astsample.ListItem2{
+			Head:  astsample.Pair{Value: astsample.Number("1"), Value2: astsample.Datum5("yes")},
+			Item2: []astsample.Datum{astsample.Number("-2")},
+		}}},
+		astsample.Entry{Name: "flag", Value: astsample.Datum5("no")},
+		astsample.Entry{Name: "none", Value: astsample.List{}},
+	}, v)
+
+	data, err := os.ReadFile("../grammars/abnf.abnf")
+	require.NoError(t, err)
+	list := reduce("../grammars/abnf.abnf", string(data), abnfast.ASTReducers).(abnfast.Rulelist)
+	var rules []abnfast.Rule
+	for _, item := range list {
+		if r, ok := item.(abnfast.Rule); ok {
+			rules = append(rules, r)
+		}
+	}
+	require.Len(t, rules, len(mustParseFile(t, "../grammars/abnf.abnf").Rules))
+	require.Equal(t, abnfast.Rulename("rulelist"), rules[0].Rulename)
+	first := rules[0].Elements.Alternation.Concatenation.Repetition
+	require.Equal(t, abnfast.Repeat("1*"), *first.Repeat)
+	group := first.Element.(abnfast.Group).Alternation
+	require.Equal(t, abnfast.Rulename("rule"), group.Concatenation.Repetition.Element)
+	require.Len(t, group.Item2, 1)
+	inner := group.Item2[0].Concatenation.Repetition.Element.(abnfast.Group).Alternation.Concatenation
+	require.Equal(t, abnfast.Repeat("*"), *inner.Repetition.Repeat)
+	require.Equal(t, abnfast.Rulename("c-nl"), inner.Item2[0].Repetition.Element)
+
+	for _, c := range []struct{ grammar, err string }{
+		{"a = b c\r\n; @field C D\r\nb = \"b\" / c\r\nc = \"c\" a\r\n", "rule b: @field: no field named C"},
+		{"; @type B\r\na = b \"a\" b\r\nb = \"b\" / a\r\n", "rule b: type B is declared more than once; rename it with @type or @field"},
+		{"a = b / \"a\" a\r\nb = \"b\" a ; @inline\r\n", "rule a: cannot refer to inlined rule b"},
+		{"a = \"a\" b a\r\nb = \"b\" \"c\" ; @inline\r\n", "rule a: cannot derive a type for b, as it is inlined"},
+		{"a = b \"x\" a\r\n; @field B\r\nb = \"b\"\r\n", "rule b: @field: expected 2 arguments, found 1"},
+		{"a = reducers\r\nreducers = \"x\"\r\n", "rule reducers: type Reducers clashes with the Reducers interface; rename it with @type or @field"},
+		{"a = 1*reducer-map\r\nreducer-map = \"y\" a\r\n", "rule reducer-map: type ReducerMap clashes with the ReducerMap function"},
+		{"a = rule-b \"x\"\r\nb = \"b\"\r\nrule-b = \"c\" b\r\n", "rule rule-b: type RuleB clashes with the constant naming rule b"},
+		{"; @type ASTReducers\r\na = \"a\"\r\n", "rule a: type ASTReducers clashes with the ASTReducers map"},
+		{"rules = 1*reducers\r\nreducers = \"x\" / reducer-map\r\nreducer-map = \"y\"\r\n", "rule rules: type Rules clashes with the Rules function"},
+		{"foo = \"f\" parse-foo\r\nparse-foo = \"x\"\r\n", "rule parse-foo: type ParseFoo clashes with the entry point of rule foo"},
+		{"a = \"a\" b\r\n; @entry\r\nb = \"b\" [ match-b ]\r\nmatch-b = \"c\"\r\n", "rule match-b: type MatchB clashes with the entry point of rule b"},
+		{"; @type rulesParser\r\na = \"a\"\r\n", "rule a: type rulesParser clashes with the shared parser"},
+	} {
+		list, err := abnf2.Parse(c.grammar)
+		if err == nil {
+			_, err = abnf.GenerateAST("test", list)
+		}
+		require.Error(t, err, c.grammar)
+		require.Contains(t, err.Error(), c.err)
+	}
+}
+
+// sampleReducers implements the Reducers of the AST sample.
+type sampleReducers struct{}
+
+func (sampleReducers) Config(ctx *parser.ReducerContext) interface{} {
+	return ctx.Reduce(ctx.Value.(parser.Atom))
+}
+func (sampleReducers) Entry(ctx *parser.ReducerContext) interface{} {
+	return ctx.Reduce(ctx.AtomList()[0])
+}
+func (sampleReducers) Key(ctx *parser.ReducerContext) interface{}    { return ctx.Text() }
+func (sampleReducers) Value(ctx *parser.ReducerContext) interface{}  { return ctx.Text() }
+func (sampleReducers) Number(ctx *parser.ReducerContext) interface{} { return ctx.Text() }
+func (sampleReducers) String(ctx *parser.ReducerContext) interface{} { return ctx.Text() }
+func (sampleReducers) List(ctx *parser.ReducerContext) interface{}   { return ctx.Text() }
+func (sampleReducers) Blank(ctx *parser.ReducerContext) interface{}  { return "blank" }
+
+func TestReducerMap(t *testing.T) {
+	gen := abnf.Generate(mustParseFile(t, "ast/sample/sample.abnf"))
+	require.Contains(t, gen, "RuleEq = \"eq\"")
+	require.NotContains(t, gen, "Eq(ctx *p.ReducerContext) interface{}")
+
+	reducers := astsample.ReducerMap(sampleReducers{})
+	var names []string
+	for name := range reducers {
+		names = append(names, name)
+	}
+	require.ElementsMatch(t, []string{"config", "entry", "key", "value", "number", "string", "list", "blank"}, names)
+
+	rules, err := abnf.Compile(mustParseFile(t, "ast/sample/sample.abnf"))
+	require.NoError(t, err)
+	tree, err := parser.New(rules).Parse(astsample.RuleConfig, "name = 1\r\n\r\nlist=[1,2]\r\n")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"name", "blank", "list"}, parser.ReduceInto(tree, reducers))
+
+	for _, grammar := range []string{"rule-1 = \"a\"\r\nrule1 = \"b\"\r\n", "a-b = \"a\"\r\na--b = \"b\"\r\n"} {
+		list, err := abnf2.Parse(grammar)
+		require.NoError(t, err)
+		_, err = abnf.GenerateE(list)
+		require.Error(t, err, grammar)
+		require.Contains(t, err.Error(), "both map to the Go name")
+		_, err = abnf.GenerateNative("test", list)
+		require.Error(t, err, grammar)
+	}
+	list, err := abnf2.Parse("rule-1 = \"a\"\r\nrule1 = \"b\"\r\n")
+	require.NoError(t, err)
+	_, err = abnf.GenerateE(list)
+	require.EqualError(t, err, "rules rule-1 and rule1 both map to the Go name Rule1")
+}
+
+func TestEntryPoints(t *testing.T) {
+	rules, err := abnf.Compile(mustParseFile(t, "ast/sample/sample.abnf"))
+	require.NoError(t, err)
+	interpreted := parser.New(rules)
+	for _, c := range []struct {
+		rule  string
+		parse func(string) (parser.Atom, error)
+		match func(string) bool
+	}{
+		{"config", astsample.ParseConfig, astsample.MatchConfig},
+		{"value", astsample.ParseValue, astsample.MatchValue},
+	} {
+		for _, input := range []string{"", "a = 1\r\n", "a = 1", "1", "(1,[yes])", "x", "\r\n \r\n"} {
+			want, wantErr := interpreted.Parse(c.rule, input)
+			got, gotErr := c.parse(input)
+			require.Equal(t, wantErr == nil, gotErr == nil, "%s %q", c.rule, input)
+			require.Equal(t, wantErr == nil, c.match(input), "%s %q", c.rule, input)
+			if wantErr == nil {
+				require.Equal(t, parser.PrintTree(want), parser.PrintTree(got))
+			} else {
+				require.EqualError(t, gotErr, wantErr.Error())
+			}
+		}
+	}
+
+	// Other grammars may refer to the rules of the sample.
+	extended := astsample.Rules()
+	extended["pairs"] = parser.Cat(parser.Ref("value"), parser.Star(parser.Cat(parser.Lit(';'), parser.Ref("value"))))
+	_, err = parser.New(extended).Parse("pairs", "1;[2];yes")
+	require.NoError(t, err)
+	require.NotContains(t, astsample.Rules(), "pairs")
+
+	gen := abnf.Generate(mustParseFile(t, "ast/sample/sample.abnf"))
+	require.Contains(t, gen, "func ParseValue(input string) (p.Atom, error)")
+	require.NotContains(t, gen, "func ParseList(")
+	list, err := abnf2.Parse("Greeting = \"hi\" Name\r\n; @entry\r\nNAME = 1*ALPHA\r\n")
+	require.NoError(t, err)
+	gen = abnf.Generate(list)
+	require.Contains(t, gen, `"greeting": p.Cat(p.Str("hi"),p.Ref("name"),),`)
+	require.Contains(t, gen, "func MatchGreeting(input string) bool")
+	require.Contains(t, gen, "func ParseName(input string) (p.Atom, error) {\nreturn rulesParser().Parse(RuleName, input)")
+	require.Contains(t, gen, "parserOnce.Do(func() {\nsharedParser = p.New(Rules())\n})")
+
+	list, err = abnf2.Parse("; @start\r\na = \"x\"\r\n; @start\r\nb = \"y\"\r\n")
+	require.NoError(t, err)
+	_, err = abnf.GenerateE(list)
+	require.EqualError(t, err, "more than one rule annotated with @start: a, b")
+}
+
+func mustParseFile(t *testing.T, path string) *abnf.RuleList {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	list, err := abnf2.Parse(string(data))
+	require.NoError(t, err)
+	return list
+}
+
+// randomCorpus returns inputs derived at random from rule, half of which are
+// then mutated by inserting, replacing or removing a rune.
+func randomCorpus(list *abnf.RuleList, rule string, rng *rand.Rand, n int) []string {
+	rules := map[string]abnf.Alternation{}
+	for _, r := range list.Rules {
+		name := strings.ToLower(r.Name.Name)
+		alt := rules[name]
+		alt.Elements = append(alt.Elements, r.Elements.Alternation.Elements...)
+		rules[name] = alt
+	}
+	var corpus []string
+	for len(corpus) < n {
+		sb := strings.Builder{}
+		if !derive(rules, abnf.RuleName{Name: rule}, rng, &sb, 0) {
+			continue
+		}
+		input := []rune(sb.String())
+		if len(corpus)%2 == 1 {
+			i := rng.Intn(len(input) + 1)
+			r := []rune(" =/;\"%x1a-(\r\n")[rng.Intn(13)]
+			switch rng.Intn(3) {
+			case 0:
+				input = append(input[:i], append([]rune{r}, input[i:]...)...)
+			case 1:
+				if i < len(input) {
+					input[i] = r
+				}
+			case 2:
+				if i < len(input) {
+					input = append(input[:i], input[i+1:]...)
+				}
+			}
+		}
+		corpus = append(corpus, string(input))
+	}
+	return corpus
+}
+
+// derive writes text matching node to sb, failing when rules nest too deep.
+func derive(rules map[string]abnf.Alternation, node interface{}, rng *rand.Rand, sb *strings.Builder, depth int) bool {
+	if depth > 24 {
+		return false
+	}
+	pick := func(s string) { sb.WriteByte(s[rng.Intn(len(s))]) }
+	between := func(from, to int) { sb.WriteRune(rune(from + rng.Intn(to-from+1))) }
+	switch n := node.(type) {
+	case abnf.Elements:
+		return derive(rules, n.Alternation, rng, sb, depth)
+	case abnf.Alternation:
+		return derive(rules, n.Elements[rng.Intn(len(n.Elements))], rng, sb, depth)
+	case abnf.Concatenation:
+		for _, r := range n.Elements {
+			if !derive(rules, r, rng, sb, depth) {
+				return false
+			}
+		}
+	case abnf.Repetition:
+		count := 1
+		if n.Meta != nil {
+			count = n.Meta.Min + rng.Intn(3)
+			if n.Meta.Max != 0 && count > n.Meta.Max {
+				count = n.Meta.Max
+			}
+		}
+		for i := 0; i < count; i++ {
+			if !derive(rules, n.Element, rng, sb, depth) {
+				return false
+			}
+		}
+	case abnf.Element:
+		return derive(rules, n.Inner, rng, sb, depth)
+	case abnf.Group:
+		return derive(rules, n.Elements, rng, sb, depth)
+	case abnf.Option:
+		if rng.Intn(2) == 0 {
+			return derive(rules, n.Elements, rng, sb, depth)
+		}
+	case abnf.CharVal:
+		for _, r := range n.Value {
+			if rng.Intn(4) == 0 {
+				r = []rune(strings.ToUpper(string(r)))[0]
+			}
+			sb.WriteRune(r)
+		}
+	case abnf.HexVal:
+		switch n.Mode {
+		case abnf.NumericModeSingle:
+			sb.WriteRune(rune(n.Single))
+		case abnf.NumericModeRange:
+			between(n.Range.From, n.Range.To)
+		case abnf.NumericModeSequence:
+			for _, v := range n.Sequence {
+				sb.WriteRune(rune(v))
+			}
+		}
+	case abnf.DecVal:
+		return derive(rules, abnf.HexVal{Numeric: n.Numeric}, rng, sb, depth)
+	case abnf.BinVal:
+		return derive(rules, abnf.HexVal{Numeric: n.Numeric}, rng, sb, depth)
+	case abnf.RuleName:
+		switch name := strings.ToLower(n.Name); name {
+		case "alpha":
+			pick("abcxyzABCXYZ")
+		case "bit":
+			pick("01")
+		case "char":
+			pick("\x01\x7f")
+		case "cr":
+			sb.WriteString("\r")
+		case "lf":
+			sb.WriteString("\n")
+		case "crlf":
+			sb.WriteString("\r\n")
+		case "ctl":
+			pick("\x00\x07\x1f\x7f")
+		case "digit":
+			between('0', '9')
+		case "dquote":
+			sb.WriteString(`"`)
+		case "hexdig":
+			pick("0123456789ABCDEF")
+		case "htab":
+			sb.WriteString("\t")
+		case "octet":
+			between(0, 0xFF)
+		case "sp":
+			sb.WriteString(" ")
+		case "vchar":
+			between(0x21, 0x7E)
+		case "wsp":
+			pick(" \t")
+		case "lwsp":
+			for i := rng.Intn(3); i > 0; i-- {
+				pick(" \t\n")
+				sb.WriteString(" ")
+			}
+		default:
+			return derive(rules, rules[name], rng, sb, depth+1)
+		}
+	}
+	return true
+}